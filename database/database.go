@@ -10,15 +10,34 @@ import (
 
 var Database *gorm.DB
 
+// Models 自动建表的模型
+var Models = []interface{}{&models.AlphaFoldQueue{}, &models.Annotation{}, &models.ESMQueue{}, &models.Folder{}, &models.ITasserQueue{}, &models.JobHistory{}, &models.Note{}, &models.NoteRevision{}, &models.Notification{}, &models.PredictionQueue{}, &models.ProteinInformation{}, &models.SearchDocument{}, &models.Share{}, &models.Tag{}, &models.Task{}, &models.TaskStage{}, &models.TaskTag{}, &models.User{}, &models.Webhook{}, &models.WebhookDelivery{}}
+
 // Connect to database
-func init() {
+// 只在服务器模式下调用，worker 模式只通过 HTTP 访问服务器，不连接数据库
+func Connect() error {
 	dsn := "root:TxMysql$100*!@tcp(101.35.87.147:3306)/protein_new?charset=utf8&parseTime=True&loc=Local"
 	database, err := gorm.Open(mysql.Open(dsn))
 	if err != nil {
-		logger.Error("数据库连接失败: %v", err)
-		return
+		return err
 	}
-	// Automatically build table
-	database.AutoMigrate(&models.AlphaFoldQueue{}, &models.ESMQueue{}, &models.ITasserQueue{}, &models.Note{}, &models.ProteinInformation{}, &models.Share{}, &models.Task{}, &models.User{})
+	Migrate(database)
 	Database = database
+	return nil
+}
+
+// Migrate 建表，并处理与新结构冲突的已有数据
+func Migrate(database *gorm.DB) {
+	// 建表之前处理与新索引冲突的已有数据
+	mergeDuplicateNotes(database)
+	dedupeJobHistories(database)
+	// Automatically build table
+	// 逐个建表，某个表失败时不影响其他表
+	for _, model := range Models {
+		if err := database.AutoMigrate(model); err != nil {
+			logger.Error("建表 %T 失败: %v", model, err)
+		}
+	}
+	// 建表之后补充新字段的数据
+	backfillProteinPredictors(database)
 }
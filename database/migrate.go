@@ -0,0 +1,177 @@
+package database
+
+import (
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// mergeDuplicateNotes 注释改为按任务共享之前，每个用户对同一任务各有一条注释
+// 建立 task_id 唯一索引之前，把同一任务的多条注释合并到最近修改的一条，历史版本归到合并后的注释
+func mergeDuplicateNotes(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.Note{}) || db.Migrator().HasIndex(&models.Note{}, "uni_notes_task_id") {
+		return
+	}
+
+	var taskIds []int64
+	if err := db.Unscoped().Model(&models.Note{}).Group("task_id").Having("COUNT(*) > 1").Pluck("task_id", &taskIds).Error; err != nil {
+		logger.Error("查询重复的注释失败: %v", err)
+		return
+	}
+	// 历史版本表和唯一索引在同一次升级中加入，从更早的版本升级时还没有该表
+	hasRevisions := db.Migrator().HasTable(&models.NoteRevision{})
+	for _, taskId := range taskIds {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var notes []models.Note
+			if err := tx.Unscoped().Where("task_id = ?", taskId).Order("deleted_at IS NOT NULL, updated_at DESC").Find(&notes).Error; err != nil {
+				return err
+			}
+			kept := notes[0]
+			contents := []string{kept.Note}
+			ids := make([]uint, 0, len(notes)-1)
+			for _, note := range notes[1:] {
+				ids = append(ids, note.ID)
+				if !note.DeletedAt.Valid && strings.TrimSpace(note.Note) != "" && note.Note != kept.Note {
+					contents = append(contents, note.Note)
+				}
+			}
+
+			if err := tx.Model(&models.Note{}).Where("id = ?", kept.ID).
+				Update("note", strings.Join(contents, "\n\n")).Error; err != nil {
+				return err
+			}
+			if hasRevisions {
+				if err := tx.Model(&models.NoteRevision{}).Where("note_id IN ?", ids).Update("note_id", kept.ID).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Note{}).Error
+		})
+		if err != nil {
+			logger.Error("合并任务 %d 的注释失败: %v", taskId, err)
+			continue
+		}
+		logger.Info("已合并任务 %d 的注释", taskId)
+	}
+
+	// 注释的检索文档改为按任务过滤，之前按用户保存的文档重新建立
+	if db.Migrator().HasTable(&models.SearchDocument{}) {
+		if err := db.Unscoped().Where("kind = ?", "note").Delete(&models.SearchDocument{}).Error; err != nil {
+			logger.Error("删除注释检索文档失败: %v", err)
+			return
+		}
+		var notes []models.Note
+		db.Find(&notes)
+		for _, note := range notes {
+			if strings.TrimSpace(note.Note) == "" {
+				continue
+			}
+			db.Create(&models.SearchDocument{
+				Kind:    "note",
+				UserId:  uint(note.UserId),
+				TaskId:  uint(note.TaskId),
+				RefId:   uint(note.TaskId),
+				Content: note.Note,
+			})
+		}
+	}
+}
+
+// dedupeJobHistories 建立 (predictor, queue_id, attempts) 唯一索引之前，删除重复的任务历史，保留最后写入的一条
+// 之前重新排队时记录被重置而不是删除，尝试次数从 0 开始，同一记录的多次执行可能有相同的尝试次数
+func dedupeJobHistories(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.JobHistory{}) || db.Migrator().HasIndex(&models.JobHistory{}, "idx_job_histories_run") {
+		return
+	}
+
+	var runs []struct {
+		Predictor string
+		QueueId   uint
+		Attempts  int
+		LatestId  uint
+	}
+	if err := db.Unscoped().Model(&models.JobHistory{}).Select("predictor, queue_id, attempts, MAX(id) AS latest_id").
+		Group("predictor, queue_id, attempts").Having("COUNT(*) > 1").Scan(&runs).Error; err != nil {
+		logger.Error("查询重复的任务历史失败: %v", err)
+		return
+	}
+	var removed int64
+	for _, run := range runs {
+		result := db.Unscoped().Where("predictor = ? AND queue_id = ? AND attempts = ? AND id < ?", run.Predictor, run.QueueId, run.Attempts, run.LatestId).
+			Delete(&models.JobHistory{})
+		if result.Error != nil {
+			logger.Error("删除%s任务 %d 重复的历史失败: %v", run.Predictor, run.QueueId, result.Error)
+			continue
+		}
+		removed += result.RowsAffected
+	}
+	if removed > 0 {
+		logger.Info("已删除 %d 条重复的任务历史", removed)
+	}
+}
+
+// legacyPredictorKeys 内置预测工具保存在任务 StructurePredictionTool 中的编号
+var legacyPredictorKeys = map[int64]string{1: "alpha", 2: "itasser", 3: "esm"}
+
+// backfillProteinPredictors 记录预测工具之前生成的模型只保存了耗时，补充 predictor 以便用于估计耗时：
+// 使用最早包含该蛋白质（或其主序列）的任务的预测工具；没有相关任务时按耗时判断，与序列时间表相同，超过 2 小时为 I-TASSER，否则为 AlphaFold
+// 之后生成的模型同时保存耗时和预测工具，因此只处理有耗时、没有预测工具的记录
+func backfillProteinPredictors(db *gorm.DB) {
+	var proteins []models.ProteinInformation
+	if err := db.Select("id, parent_id, duration").Where("predictor = ? AND duration > 0", "").Find(&proteins).Error; err != nil {
+		logger.Error("查询缺少预测工具的蛋白质信息失败: %v", err)
+		return
+	}
+	if len(proteins) == 0 {
+		return
+	}
+
+	// 已删除的任务同样记录了生成模型时使用的预测工具
+	var tasks []models.Task
+	if err := db.Unscoped().Select("id, model_id, structure_prediction_tool").
+		Where("structure_prediction_tool IS NOT NULL").Order("id").Find(&tasks).Error; err != nil {
+		logger.Error("查询任务的预测工具失败: %v", err)
+		return
+	}
+	predictorByProtein := make(map[uint]string)
+	for _, task := range tasks {
+		predictor, ok := legacyPredictorKeys[*task.StructurePredictionTool]
+		if !ok {
+			continue
+		}
+		for _, idStr := range strings.Split(task.ModelId, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32)
+			if err != nil || id == 0 {
+				continue
+			}
+			if _, exists := predictorByProtein[uint(id)]; !exists {
+				predictorByProtein[uint(id)] = predictor
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, protein := range proteins {
+		predictor, ok := predictorByProtein[protein.ID]
+		if !ok && protein.ParentId != 0 {
+			predictor, ok = predictorByProtein[protein.ParentId]
+		}
+		if !ok {
+			predictor = "alpha"
+			if protein.Duration > 2*60*60 {
+				predictor = "itasser"
+			}
+		}
+		// 不修改 updated_at
+		if err := db.Model(&models.ProteinInformation{}).Where("id = ? AND predictor = ?", protein.ID, "").
+			UpdateColumn("predictor", predictor).Error; err != nil {
+			logger.Error("补充蛋白质 %d 的预测工具失败: %v", protein.ID, err)
+			continue
+		}
+		counts[predictor]++
+	}
+	logger.Info("已补充蛋白质信息的预测工具: %v", counts)
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"Protein_Server/models"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	// search_documents 的 FULLTEXT 索引只有 MySQL 支持，表本身已经创建
+	for _, model := range Models {
+		if err := db.AutoMigrate(model); err != nil && !strings.Contains(err.Error(), "FULLTEXT") {
+			t.Fatalf("建表失败: %v", err)
+		}
+	}
+	return db
+}
+
+func TestBackfillProteinPredictors(t *testing.T) {
+	db := openTestDatabase(t)
+	tool := func(value int64) *int64 { return &value }
+
+	proteins := []models.ProteinInformation{
+		{Sequence: "MAIN", Duration: 100},                   // 1: 任务使用 I-TASSER
+		{Sequence: "SUB", Duration: 50, ParentId: 1},        // 2: 子序列，任务的 ModelId 中包含
+		{Sequence: "ORPHANSUB", Duration: 40, ParentId: 1},  // 3: 子序列，使用主序列的任务
+		{Sequence: "SLOW", Duration: 3 * 60 * 60},           // 4: 没有任务，耗时超过 2 小时
+		{Sequence: "FAST", Duration: 60},                    // 5: 只有不使用预测工具的任务
+		{Sequence: "KNOWN", Duration: 60, Predictor: "esm"}, // 6: 已有预测工具
+		{Sequence: "QUEUED"},                                // 7: 尚未生成模型
+		{Sequence: "SHARED", Duration: 60},                  // 8: 多个任务，使用最早的任务
+		{Sequence: "DELETED", Duration: 60},                 // 9: 任务已删除
+	}
+	for i := range proteins {
+		if err := db.Create(&proteins[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks := []models.Task{
+		{Title: "fold", Sequence: "MAIN", Type: 2, StructurePredictionTool: tool(2), ModelId: "1,2"},
+		{Title: "esm", Sequence: "KNOWN", Type: 2, StructurePredictionTool: tool(1), ModelId: "6"},
+		{Title: "queued", Sequence: "QUEUED", Type: 2, StructurePredictionTool: tool(3), ModelId: "7"},
+		{Title: "first", Sequence: "SHARED", Type: 2, StructurePredictionTool: tool(3), ModelId: "8"},
+		{Title: "second", Sequence: "SHARED", Type: 2, StructurePredictionTool: tool(1), ModelId: "8"},
+		{Title: "deleted", Sequence: "DELETED", Type: 2, StructurePredictionTool: tool(3), ModelId: "9"},
+		{Title: "params", Sequence: "FAST", Type: 3, ModelId: "5"},
+	}
+	for i := range tasks {
+		if err := db.Create(&tasks[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Delete(&tasks[5])
+
+	backfillProteinPredictors(db)
+	// 再次执行不会修改已补充的记录
+	backfillProteinPredictors(db)
+
+	want := map[string]string{
+		"MAIN":      "itasser",
+		"SUB":       "itasser",
+		"ORPHANSUB": "itasser",
+		"SLOW":      "itasser",
+		"FAST":      "alpha",
+		"KNOWN":     "esm",
+		"QUEUED":    "",
+		"SHARED":    "esm",
+		"DELETED":   "esm",
+	}
+	var got []models.ProteinInformation
+	if err := db.Find(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, protein := range got {
+		if protein.Predictor != want[protein.Sequence] {
+			t.Errorf("%s: predictor = %q, want %q", protein.Sequence, protein.Predictor, want[protein.Sequence])
+		}
+	}
+}
+
+// baselineNote 每个用户对同一任务各有一条注释时的表结构，没有版本号和 task_id 唯一索引
+type baselineNote struct {
+	gorm.Model
+	TaskId int64  `gorm:"not null"`
+	UserId int64  `gorm:"not null"`
+	Note   string `gorm:"type:longtext"`
+}
+
+func (baselineNote) TableName() string { return "notes" }
+
+func TestMigrateMergesDuplicateNotes(t *testing.T) {
+	tests := []struct {
+		name      string
+		revisions bool // 升级前已有历史版本表
+	}{
+		{"从没有历史版本表的版本升级", false},
+		{"已有历史版本表", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.AutoMigrate(&baselineNote{}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.revisions {
+				if err := db.AutoMigrate(&models.NoteRevision{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			now := time.Now()
+			notes := []baselineNote{
+				{TaskId: 1, UserId: 1, Note: "owner note"},
+				{TaskId: 1, UserId: 2, Note: "collaborator note"},
+				{TaskId: 1, UserId: 3, Note: "deleted note"},
+				{TaskId: 2, UserId: 1, Note: "only note"},
+			}
+			for i := range notes {
+				notes[i].UpdatedAt = now.Add(time.Duration(i) * time.Minute)
+				if err := db.Create(&notes[i]).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			db.Delete(&notes[2])
+			if tt.revisions {
+				db.Create(&models.NoteRevision{NoteId: notes[0].ID, TaskId: 1, UserId: 1, Version: 1, Note: "owner note"})
+			}
+
+			Migrate(db)
+
+			// 同一任务的注释合并到最近修改的一条，已删除的注释内容不再保留
+			var merged []models.Note
+			if err := db.Unscoped().Where("task_id = ?", 1).Find(&merged).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(merged) != 1 || merged[0].ID != notes[1].ID || merged[0].Note != "collaborator note\n\nowner note" {
+				t.Fatalf("task 1 notes = %+v", merged)
+			}
+			var count int64
+			db.Model(&models.Note{}).Where("task_id = ? AND note = ?", 2, "only note").Count(&count)
+			if count != 1 {
+				t.Errorf("task 2 note changed")
+			}
+
+			if !db.Migrator().HasIndex(&models.Note{}, "uni_notes_task_id") {
+				t.Error("uni_notes_task_id was not created")
+			}
+			if !db.Migrator().HasIndex(&models.NoteRevision{}, "idx_note_revisions_task_id") {
+				t.Error("idx_note_revisions_task_id was not created")
+			}
+			if tt.revisions {
+				var revision models.NoteRevision
+				db.First(&revision)
+				if revision.NoteId != merged[0].ID {
+					t.Errorf("revision note_id = %d, want %d", revision.NoteId, merged[0].ID)
+				}
+			}
+		})
+	}
+}
+
+// baselineJobHistory 重新排队时重置记录的版本中的任务历史表结构，没有 (predictor, queue_id, attempts) 唯一索引
+type baselineJobHistory struct {
+	gorm.Model
+	Predictor    string `gorm:"not null;type:varchar(64);index"`
+	QueueId      uint   `gorm:"not null;index"`
+	Sequence     string `gorm:"not null;type:longtext"`
+	ParentId     *int64 `gorm:"default:null"`
+	UserId       uint   `gorm:"not null;default:0;index"`
+	Priority     int    `gorm:"not null;default:0"`
+	Status       string `gorm:"not null;type:varchar(16);index"`
+	ErrorMessage string `gorm:"type:text"`
+	Attempts     int    `gorm:"not null;default:0"`
+	Version      string `gorm:"type:varchar(128)"`
+	LeaseOwner   string `gorm:"type:varchar(128)"`
+	QueuedAt     time.Time
+	StartedAt    *time.Time `gorm:"default:null"`
+	FinishedAt   *time.Time `gorm:"default:null;index"`
+	Duration     float64    `gorm:"not null;default:0"`
+}
+
+func (baselineJobHistory) TableName() string { return "job_histories" }
+
+func TestDedupeJobHistories(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&baselineJobHistory{}); err != nil {
+		t.Fatal(err)
+	}
+	rows := []baselineJobHistory{
+		{Predictor: "alpha", QueueId: 1, Attempts: 1, Status: "failed"},
+		{Predictor: "alpha", QueueId: 1, Attempts: 1, Status: "succeeded"},
+		{Predictor: "alpha", QueueId: 1, Attempts: 2, Status: "succeeded"},
+		{Predictor: "esm", QueueId: 1, Attempts: 1, Status: "succeeded"},
+	}
+	for i := range rows {
+		if err := db.Create(&rows[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	Migrate(db)
+
+	var histories []models.JobHistory
+	db.Order("id").Find(&histories)
+	if len(histories) != 3 || histories[0].Status != "succeeded" || histories[0].Attempts != 1 {
+		t.Errorf("histories = %+v", histories)
+	}
+	if !db.Migrator().HasIndex(&models.JobHistory{}, "idx_job_histories_run") {
+		t.Error("idx_job_histories_run was not created")
+	}
+}
@@ -1,19 +1,31 @@
 package main
 
 import (
+	"Protein_Server/database"
 	"Protein_Server/logger"
 	profasacontrollers "Protein_Server/profasa/controllers"
 	"Protein_Server/services"
-
-	_ "Protein_Server/database"
+	"os"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// worker 模式：只从服务器租用并执行预测任务，不启动 API 和队列调度器
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		if err := services.RunWorker(); err != nil {
+			logger.Fatal("worker 运行失败: %v", err)
+		}
+		return
+	}
+
 	// 初始化日志系统
 	logger.Info("启动蛋白质服务器...")
 
+	if err := database.Connect(); err != nil {
+		logger.Fatal("数据库连接失败: %v", err)
+	}
+
 	// 测试蛋白质参数计算正确性
 	//if err := services.ReadAndCalcParameterExcel(); err != nil {
 	//	logger.Error("批量计算Excel参数失败: %v", err)
@@ -23,6 +35,9 @@ func main() {
 	queueScheduler.Start()
 	defer queueScheduler.Stop()
 
+	// 首次部署时为已有数据建立检索索引
+	go services.RebuildSearchIndexIfEmpty()
+
 	//services.BackendProcess()
 	// Create a Gin Server
 	router := gin.Default()
@@ -70,9 +85,68 @@ func main() {
 		auth.POST("/shareBlast", profasacontrollers.ShareBlast)
 		auth.POST("/share/agree", profasacontrollers.AgreeShareBlast)
 		auth.POST("/share/refuse", profasacontrollers.RefuseShareBlast)
+		auth.GET("/shares/outgoing", profasacontrollers.GetOutgoingShares)
+		auth.GET("/shares/incoming", profasacontrollers.GetIncomingShares)
+		auth.POST("/shares/revoke", profasacontrollers.RevokeShare)
+		auth.POST("/shares/permission", profasacontrollers.UpdateSharePermission)
 		auth.POST("/viewNote", profasacontrollers.ViewNote)
 		auth.POST("/getAllModelNotMe", profasacontrollers.GetAllModelNotMe)
 		auth.POST("/updateNote", profasacontrollers.UpdateNote)
+		auth.POST("/note/revisions", profasacontrollers.NoteRevisions)
+		auth.POST("/note/diff", profasacontrollers.DiffNote)
+		auth.POST("/note/restore", profasacontrollers.RestoreNote)
+		auth.GET("/annotations", profasacontrollers.GetAnnotations)
+		auth.POST("/annotations", profasacontrollers.CreateAnnotation)
+		auth.POST("/annotations/update", profasacontrollers.UpdateAnnotation)
+		auth.POST("/annotations/delete", profasacontrollers.DeleteAnnotation)
+		auth.GET("/annotations/export", profasacontrollers.ExportAnnotations)
+		auth.GET("/notifications", profasacontrollers.GetNotifications)
+		auth.POST("/notifications/read", profasacontrollers.ReadNotifications)
+		auth.GET("/webhooks", profasacontrollers.GetWebhooks)
+		auth.POST("/webhooks", profasacontrollers.CreateWebhook)
+		auth.POST("/webhooks/delete", profasacontrollers.DeleteWebhook)
+		auth.POST("/webhooks/test", profasacontrollers.TestWebhook)
+		auth.GET("/webhooks/deliveries", profasacontrollers.GetWebhookDeliveries)
+		auth.GET("/tags", profasacontrollers.GetTags)
+		auth.POST("/tags", profasacontrollers.CreateTag)
+		auth.POST("/tags/delete", profasacontrollers.DeleteTag)
+		auth.GET("/folders", profasacontrollers.GetFolders)
+		auth.POST("/folders", profasacontrollers.CreateFolder)
+		auth.POST("/folders/update", profasacontrollers.UpdateFolder)
+		auth.POST("/folders/delete", profasacontrollers.DeleteFolder)
+		auth.POST("/tasks/tag", profasacontrollers.TagTasks)
+		auth.POST("/tasks/move", profasacontrollers.MoveTasks)
+		auth.POST("/task/rerun", profasacontrollers.RerunTask)
+		auth.POST("/task/delete", profasacontrollers.DeleteTask)
+		auth.POST("/task/cancel", profasacontrollers.CancelTask)
+		auth.GET("/task/progress", profasacontrollers.GetTaskProgress)
+		auth.POST("/task/stage/rerun", profasacontrollers.RerunTaskStage)
+		auth.GET("/queue/status", profasacontrollers.GetQueueStatus)
+		auth.GET("/predictors", profasacontrollers.GetPredictors)
+		auth.POST("/queue/cancel", profasacontrollers.CancelQueueJob)
+		auth.GET("/job/log", profasacontrollers.GetJobLog)
+		auth.GET("/job/log/download", profasacontrollers.DownloadJobLog)
+		auth.POST("/queue/priority", profasacontrollers.SetJobPriority)
+		auth.POST("/admin/users/priority", profasacontrollers.SetUserQueuePriority)
+		auth.GET("/admin/jobs/history", profasacontrollers.GetJobHistory)
+		auth.POST("/compare", profasacontrollers.Compare)
+		auth.GET("/search", profasacontrollers.Search)
+		auth.GET("/trash", profasacontrollers.GetTrash)
+		auth.POST("/trash/restore", profasacontrollers.RestoreTask)
+		auth.POST("/trash/purge", profasacontrollers.PurgeTask)
+	}
+
+	// 事件流，EventSource 无法设置请求头，允许通过查询参数传递 token
+	router.GET("/events", services.JwtVerifyQuery, profasacontrollers.Events)
+
+	// 远程 worker 的接口，使用 WORKER_TOKEN 鉴权
+	worker := router.Group("/worker")
+	worker.Use(services.WorkerVerify)
+	{
+		worker.POST("/lease", profasacontrollers.WorkerLease)
+		worker.POST("/heartbeat", profasacontrollers.WorkerHeartbeat)
+		worker.POST("/complete", profasacontrollers.WorkerComplete)
+		worker.POST("/fail", profasacontrollers.WorkerFail)
 	}
 
 	// Listen 10010 port
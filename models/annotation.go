@@ -0,0 +1,17 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Annotation struct {
+	gorm.Model
+	ProteinId    uint   `gorm:"not null;index:idx_annotations_protein_id" form:"proteinid"`
+	UserId       uint   `gorm:"not null" form:"userid"` // 作者
+	Chain        string `gorm:"not null;type:varchar(8);default:'A'" form:"chain"`
+	StartResidue int    `gorm:"not null" form:"start_residue"`
+	EndResidue   int    `gorm:"not null" form:"end_residue"`
+	Label        string `gorm:"not null;type:varchar(255)" form:"label"`
+	Category     string `gorm:"type:varchar(64)" form:"category"`
+	Color        string `gorm:"type:varchar(16)" form:"color"`
+}
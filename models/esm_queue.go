@@ -6,8 +6,8 @@ import (
 
 type ESMQueue struct {
 	gorm.Model
+	QueueJob
 	Sequence string  `gorm:"not null;type:longtext" form:"sequence"`
-	Status   string  `gorm:"not null;default:pending" form:"status"`
 	IsSubseq int64   `gorm:"not null;default:0" form:"is_subseq"`
 	ParentId *int64  `gorm:"default:null" form:"parent_id"`
 } 
\ No newline at end of file
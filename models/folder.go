@@ -0,0 +1,13 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Folder struct {
+	gorm.Model
+	UserId uint   `gorm:"not null;index:idx_folders_user_id" form:"userid"`
+	Name   string `gorm:"not null;type:varchar(255)" form:"name"`
+	// 0 表示根目录
+	ParentId uint `gorm:"default:0" form:"parent_id"`
+}
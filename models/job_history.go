@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobHistory 已结束的预测队列记录的归档
+// 队列记录超过保留时间或重新排队时删除，删除的同时写入，保留执行结果、耗时和错误
+// (predictor, queue_id, attempts) 唯一标识一次执行，同一次执行只归档一次
+type JobHistory struct {
+	gorm.Model
+	Predictor    string     `gorm:"not null;type:varchar(64);index;uniqueIndex:idx_job_histories_run,priority:1" form:"predictor"` // 预测工具名称，例如 "alpha"
+	QueueId      uint       `gorm:"not null;index;uniqueIndex:idx_job_histories_run,priority:2" form:"queue_id"`                   // 原队列记录ID
+	Sequence     string     `gorm:"not null;type:longtext" form:"sequence"`
+	ParentId     *int64     `gorm:"default:null" form:"parent_id"`
+	UserId       uint       `gorm:"not null;default:0;index" form:"user_id"`
+	Priority     int        `gorm:"not null;default:0" form:"priority"`
+	Status       string     `gorm:"not null;type:varchar(16);index" form:"status"` // succeeded, failed, cancelled
+	ErrorMessage string     `gorm:"type:text" form:"error_message"`
+	Attempts     int        `gorm:"not null;default:0;uniqueIndex:idx_job_histories_run,priority:3" form:"attempts"`
+	Version      string     `gorm:"type:varchar(128)" form:"version"`     // 预测工具版本
+	LeaseOwner   string     `gorm:"type:varchar(128)" form:"lease_owner"` // 最后执行该记录的调度器实例或远程 worker
+	QueuedAt     time.Time  `form:"queued_at"`                            // 加入队列的时间
+	StartedAt    *time.Time `gorm:"default:null" form:"started_at"`
+	FinishedAt   *time.Time `gorm:"default:null;index" form:"finished_at"`
+	Duration     float64    `gorm:"not null;default:0" form:"duration"` // 最后一次执行的耗时（秒）
+}
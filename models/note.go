@@ -4,9 +4,21 @@ import (
 	"gorm.io/gorm"
 )
 
+// Note 任务的注释，同一任务只有一条，拥有者和协作者共同编辑
 type Note struct {
 	gorm.Model
-	TaskId int64  `gorm:"not null" form:"taskid" binding:"required"`
-	UserId int64  `gorm:"not null" form:"userid" binding:"required"`
+	TaskId int64  `gorm:"not null;uniqueIndex:uni_notes_task_id" form:"taskid" binding:"required"`
+	UserId int64  `gorm:"not null" form:"userid" binding:"required"` // 最后修改的用户
 	Note   string `gorm:"type:longtext" form:"note"`
+	// 每次保存加一，用于乐观并发控制
+	Version int64 `gorm:"not null;default:0" form:"version"`
+}
+
+type NoteRevision struct {
+	gorm.Model
+	NoteId  uint   `gorm:"not null;index:idx_note_revisions_note_id" form:"noteid"`
+	TaskId  int64  `gorm:"not null;index:idx_note_revisions_task_id" form:"taskid"`
+	UserId  int64  `gorm:"not null" form:"userid"` // 保存该版本的用户
+	Version int64  `gorm:"not null" form:"version"`
+	Note    string `gorm:"type:longtext" form:"note"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Notification struct {
+	gorm.Model
+	UserId uint `gorm:"not null;index:idx_notifications_user_id" form:"userid"`
+	// share_new, share_agreed, share_refused, job_completed, job_failed, quota_warning
+	Type    string `gorm:"not null;type:varchar(64)" form:"type"`
+	Title   string `gorm:"not null;type:varchar(255)" form:"title"`
+	Content string `gorm:"type:longtext" form:"content"`
+	TaskId  uint   `gorm:"default:0" form:"taskid"`
+	IsRead  bool   `gorm:"not null;default:false" form:"is_read"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// PredictionQueue 通过配置添加的预测工具共用的队列表，按 Predictor 区分
+type PredictionQueue struct {
+	gorm.Model
+	QueueJob
+	Predictor string `gorm:"type:varchar(64);not null;index" form:"predictor"` // 预测工具名称，例如 "colabfold"
+	Sequence  string `gorm:"not null;type:longtext" form:"sequence"`
+	IsSubseq  int64  `gorm:"not null;default:0" form:"is_subseq"`
+	ParentId  *int64 `gorm:"default:null" form:"parent_id"`
+}
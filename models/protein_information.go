@@ -18,5 +18,6 @@ type ProteinInformation struct {
 	PdbId               string  `gorm:"type:longtext" form:"pdbid"`
 	ParentId            uint    `gorm:"default:0;index:idx_protein_informations_parent_id" form:"parent_id"`
 	Duration            float64 `gorm:"default:0" form:"duration"`
-	StructureNum        int     `gorm:"default:0" form:"structure_num"` // RCSB PDB结构数量
+	Predictor           string  `gorm:"type:varchar(64);index" form:"predictor"` // 生成模型的预测工具，与 Duration 一起用于估计耗时
+	StructureNum        int     `gorm:"default:0" form:"structure_num"`          // RCSB PDB结构数量
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// QueueJob 预测队列记录共用的状态字段
+type QueueJob struct {
+	Status         string     `gorm:"not null;default:'pending';index" form:"status"` // pending, claimed, running, postprocessing, succeeded, failed, cancelled
+	ErrorMessage   string     `gorm:"type:text" form:"error_message"`
+	Attempts       int        `gorm:"not null;default:0" form:"attempts"`
+	StartedAt      *time.Time `gorm:"default:null" form:"started_at"`
+	FinishedAt     *time.Time `gorm:"default:null" form:"finished_at"`
+	NextAttemptAt  *time.Time `gorm:"default:null" form:"next_attempt_at"`        // 重试的最早时间
+	UserId         uint       `gorm:"not null;default:0;index" form:"user_id"`    // 提交该记录的用户，用于公平调度
+	Priority       int        `gorm:"not null;default:0" form:"priority"`         // 数值越大越优先
+	LeaseOwner     string     `gorm:"type:varchar(128);index" form:"lease_owner"` // 正在执行该记录的调度器实例
+	LeaseExpiresAt *time.Time `gorm:"default:null" form:"lease_expires_at"`       // 租约到期后视为执行中断
+	HeartbeatAt    *time.Time `gorm:"default:null" form:"heartbeat_at"`
+	Version        string     `gorm:"type:varchar(128)" form:"version"` // 执行该记录的预测工具版本
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// SearchDocument 全文检索的索引文档，由任务、注释和结构域信息生成
+type SearchDocument struct {
+	gorm.Model
+	// task, note, domain
+	Kind   string `gorm:"not null;type:varchar(16);index:idx_search_documents_kind_ref" form:"kind"`
+	UserId uint   `gorm:"not null;index:idx_search_documents_user_id" form:"userid"`
+	// 文档所属的任务，检索时按任务的访问权限过滤
+	TaskId uint `gorm:"default:0;index:idx_search_documents_task_id" form:"taskid"`
+	// 来源记录ID：任务ID、注释所属的任务ID或结构域所在的蛋白质ID
+	RefId   uint   `gorm:"not null;index:idx_search_documents_kind_ref" form:"refid"`
+	Title   string `gorm:"type:varchar(512);index:idx_search_documents_fulltext,class:FULLTEXT" form:"title"`
+	Content string `gorm:"type:text;index:idx_search_documents_fulltext,class:FULLTEXT" form:"content"`
+}
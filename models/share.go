@@ -8,8 +8,10 @@ type Share struct {
 	gorm.Model
 	TaskId uint `gorm:"default:0" form:"taskid" binding:"required"`
 	ToId   uint `gorm:"not null" form:"toId" binding:"required"`
-	// 0 Undisposed; 1 agree; 2 reject
+	// 0 Undisposed; 1 agree; 2 reject; 3 revoked
 	Status int64 `gorm:"not null" form:"status"`
 	FromId uint  `gorm:"not null" form:"fromId"`
 	SeqId  uint  `gorm:"default:0" form:"seqId"`
+	// view, annotate, rerun
+	Permission string `gorm:"not null;type:varchar(16);default:'view'" form:"permission"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Tag struct {
+	gorm.Model
+	UserId uint   `gorm:"not null;uniqueIndex:uni_tags_user_name" form:"userid"`
+	Name   string `gorm:"not null;type:varchar(64);uniqueIndex:uni_tags_user_name" form:"name"`
+	Color  string `gorm:"type:varchar(16)" form:"color"`
+}
+
+type TaskTag struct {
+	ID     uint `gorm:"primarykey"`
+	TaskId uint `gorm:"not null;uniqueIndex:uni_task_tags_task_tag" form:"taskid"`
+	TagId  uint `gorm:"not null;uniqueIndex:uni_task_tags_task_tag;index:idx_task_tags_tag_id" form:"tagid"`
+}
@@ -16,4 +16,12 @@ type Task struct {
 	// Sequences from Sequence Search
 	SubSequence string `gorm:"type:longtext" form:"subsequence"`
 	ModelId     string `gorm:"not null;type:longtext" form:"model_id"`
+	// 0 表示未归档到任何文件夹
+	FolderId uint `gorm:"default:0;index:idx_tasks_folder_id" form:"folder_id"`
+	// 由重新运行或克隆创建时记录来源任务，0 表示原始任务
+	SourceTaskId uint `gorm:"default:0;index:idx_tasks_source_task_id" form:"source_task_id"`
+	// 结构域搜索使用的 E-value，0 表示默认值
+	Evalue float64 `gorm:"default:0" form:"evalue"`
+	// pending, running, completed, failed
+	Status string `gorm:"not null;type:varchar(32);default:'completed'" form:"status"`
 }
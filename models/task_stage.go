@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskStage 任务流水线中一个阶段的执行记录
+// 按蛋白质执行的阶段（fold、parameters、ramachandran、structure_num）每个蛋白质一条记录，其他阶段 ProteinId 为 0
+type TaskStage struct {
+	gorm.Model
+	TaskId       uint       `gorm:"not null;uniqueIndex:idx_task_stages_stage" form:"task_id"`
+	Stage        string     `gorm:"not null;type:varchar(32);uniqueIndex:idx_task_stages_stage" form:"stage"`
+	ProteinId    uint       `gorm:"not null;default:0;uniqueIndex:idx_task_stages_stage" form:"protein_id"`
+	Status       string     `gorm:"not null;type:varchar(16);default:'pending'" form:"status"` // pending, running, succeeded, failed, cancelled
+	Attempts     int        `gorm:"not null;default:0" form:"attempts"`
+	StartedAt    *time.Time `gorm:"default:null" form:"started_at"`
+	FinishedAt   *time.Time `gorm:"default:null" form:"finished_at"`
+	ErrorMessage string     `gorm:"type:text" form:"error_message"`
+}
@@ -6,7 +6,9 @@ import (
 
 type User struct {
 	gorm.Model
-	Email    string `gorm:"not null;uniqueIndex:uni_users_email;type:varchar(191)" form:"email" binding:"required"`
-	Password string `gorm:"not null;type:longtext" form:"password" binding:"required"`
-	NewCount int64  `gorm:"not null" form:"new_count"`
+	Email         string `gorm:"not null;uniqueIndex:uni_users_email;type:varchar(191)" form:"email" binding:"required"`
+	Password      string `gorm:"not null;type:longtext" form:"password" binding:"required"`
+	NewCount      int64  `gorm:"not null" form:"new_count"`
+	IsAdmin       bool   `gorm:"not null;default:false" form:"-"`
+	QueuePriority int    `gorm:"not null;default:0" form:"-"` // 该用户提交的预测任务的默认优先级
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type Webhook struct {
+	gorm.Model
+	UserId uint   `gorm:"not null;index:idx_webhooks_user_id" form:"userid"`
+	Url    string `gorm:"not null;type:varchar(1024)" form:"url"`
+	Secret string `gorm:"not null;type:varchar(255)" form:"secret"`
+	// 逗号分隔的事件列表（job.completed,job.failed），为空表示订阅全部事件
+	Events string `gorm:"type:varchar(255)" form:"events"`
+	Active bool   `gorm:"not null;default:true" form:"active"`
+}
+
+type WebhookDelivery struct {
+	gorm.Model
+	WebhookId  uint    `gorm:"not null;index:idx_webhook_deliveries_webhook_id" form:"webhook_id"`
+	Event      string  `gorm:"not null;type:varchar(64)" form:"event"`
+	Payload    string  `gorm:"type:longtext" form:"payload"`
+	Attempt    int     `gorm:"not null;default:1" form:"attempt"`
+	StatusCode int     `gorm:"default:0" form:"status_code"`
+	Success    bool    `gorm:"not null;default:false" form:"success"`
+	Error      string  `gorm:"type:text" form:"error"`
+	Response   string  `gorm:"type:text" form:"response"`
+	Duration   float64 `gorm:"default:0" form:"duration"`
+}
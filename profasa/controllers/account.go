@@ -9,13 +9,20 @@ import (
 	"net/http"
 )
 
+// AccountRequest 注册和登录的参数，只接受邮箱和密码，避免客户端设置管理员标记等字段
+type AccountRequest struct {
+	Email    string `form:"email" binding:"required"`
+	Password string `form:"password" binding:"required"`
+}
+
 func Register(c *gin.Context) {
-	var user models.User
+	var req AccountRequest
 	// Bind automatically parses the input parameters of the api to variables using the form description in the struct
-	if err := c.Bind(&user); err != nil {
+	if err := c.Bind(&req); err != nil {
 		utils.Error(c, 400, "Parameter error")
 		return
 	}
+	user := models.User{Email: req.Email, Password: req.Password}
 	if err := database.Database.Create(&user).Error; err != nil {
 		utils.Error(c, 400, "E-mail already exists")
 		return
@@ -24,7 +31,7 @@ func Register(c *gin.Context) {
 }
 
 func LogIn(c *gin.Context) {
-	var loginRequest models.User
+	var loginRequest AccountRequest
 	// Bind automatically parses the input parameters of the api to variables using the form description in the struct
 	if err := c.Bind(&loginRequest); err != nil {
 		utils.Error(c, 400, "Parameter error")
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAnnotations 查询蛋白质模型的残基区间注释
+// GET /annotations?proteinId=123
+func GetAnnotations(c *gin.Context) {
+	proteinId, err := strconv.ParseUint(c.Query("proteinId"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.ListAnnotations(userByToken.ID, uint(proteinId))
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// CreateAnnotation 创建残基区间注释
+// POST /annotations {"proteinId": 123, "chain": "A", "start": 120, "end": 145, "label": "active site loop", "category": "active_site", "color": "#E53935"}
+func CreateAnnotation(c *gin.Context) {
+	var req services.AnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ProteinId == 0 {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.CreateAnnotation(userByToken.ID, req)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "Created successfully")
+}
+
+// UpdateAnnotation 更新残基区间注释
+// POST /annotations/update {"id": 1, "start": 120, "end": 150, "label": "..."}
+func UpdateAnnotation(c *gin.Context) {
+	var req services.AnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.UpdateAnnotation(userByToken.ID, req)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "Updated successfully")
+}
+
+// DeleteAnnotationRequest 删除注释请求结构体
+type DeleteAnnotationRequest struct {
+	Id uint `json:"id" binding:"required"`
+}
+
+// DeleteAnnotation 删除残基区间注释
+// POST /annotations/delete {"id": 1}
+func DeleteAnnotation(c *gin.Context) {
+	var req DeleteAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.DeleteAnnotation(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Deleted successfully")
+}
+
+// ExportAnnotations 以 CSV 文件导出任务中所有模型的注释
+// GET /annotations/export?taskId=1
+func ExportAnnotations(c *gin.Context) {
+	taskId, err := strconv.ParseUint(c.Query("taskId"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	data, err := services.ExportTaskAnnotations(userByToken.ID, uint(taskId))
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=task_%d_annotations.csv", taskId))
+	c.Data(200, "text/csv; charset=utf-8", data)
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Compare 并排比较两个任务或两个模型
+// POST /compare {"taskIds": [1, 2]} 或 {"proteinIds": [3, 4]}
+func Compare(c *gin.Context) {
+	var req services.CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.Compare(userByToken.ID, req)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Events 以 Server-Sent Events 推送当前用户的任务进度
+// GET /events (EventSource 无法设置请求头时可使用 ?token=xxx)
+func Events(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	bus := services.GetEventBus()
+	ch := bus.Subscribe(userByToken.ID)
+	defer bus.Unsubscribe(userByToken.ID, ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// 定时发送心跳，避免代理断开空闲连接
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event := <-ch:
+			c.SSEvent(event.Type, event)
+			return true
+		case now := <-heartbeat.C:
+			c.SSEvent("ping", now.UnixMilli())
+			return true
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoteRevisionsRequest 查询注释历史版本请求结构体
+type NoteRevisionsRequest struct {
+	SequenceId uint `json:"sequenceId" binding:"required"`
+}
+
+// NoteRevisions 查询注释的历史版本
+// POST /note/revisions {"sequenceId": 123}
+func NoteRevisions(c *gin.Context) {
+	var req NoteRevisionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result := services.ListNoteRevisions(userByToken.ID, req.SequenceId)
+	if result.Error != "" {
+		utils.Error(c, 400, result.Error)
+		return
+	}
+	utils.Success(c, result.Data, "ok")
+}
+
+// DiffNoteRequest 比较注释版本请求结构体
+type DiffNoteRequest struct {
+	SequenceId uint `json:"sequenceId" binding:"required"`
+	From       uint `json:"from" binding:"required"` // 历史版本ID
+	To         uint `json:"to" binding:"required"`   // 历史版本ID
+}
+
+// DiffNote 比较注释的两个历史版本
+// POST /note/diff {"sequenceId": 123, "from": 1, "to": 2}
+func DiffNote(c *gin.Context) {
+	var req DiffNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result := services.DiffNoteRevisions(userByToken.ID, req.SequenceId, req.From, req.To)
+	if result.Error != "" {
+		utils.Error(c, 400, result.Error)
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// RestoreNoteRequest 恢复注释版本请求结构体
+type RestoreNoteRequest struct {
+	SequenceId uint   `json:"sequenceId" binding:"required"`
+	RevisionId uint   `json:"revisionId" binding:"required"`
+	Version    *int64 `json:"version"`
+}
+
+// RestoreNote 将注释恢复为指定历史版本
+// POST /note/restore {"sequenceId": 123, "revisionId": 1, "version": 3}
+func RestoreNote(c *gin.Context) {
+	var req RestoreNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result := services.RestoreNoteRevision(userByToken.ID, req.SequenceId, req.RevisionId, req.Version)
+	if result.Conflict {
+		utils.Error(c, 409, result.Error)
+		return
+	}
+	if result.Error != "" {
+		utils.Error(c, 400, result.Error)
+		return
+	}
+	utils.Success(c, gin.H{"msg": result.Message, "version": result.Version}, "ok")
+}
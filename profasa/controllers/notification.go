@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications 分页获取当前用户的通知
+// GET /notifications?current=1&pageSize=10&unread=true
+func GetNotifications(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if current < 1 {
+		current = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	result, err := services.ListNotifications(userByToken.ID, current, pageSize, unreadOnly)
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// ReadNotificationsRequest 标记已读请求结构体，ids 为空时标记全部
+type ReadNotificationsRequest struct {
+	Ids []uint `json:"ids"`
+}
+
+// ReadNotifications 将通知标记为已读
+// POST /notifications/read {"ids": [1, 2]}
+func ReadNotifications(c *gin.Context) {
+	var req ReadNotificationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.MarkNotificationsRead(userByToken.ID, req.Ids); err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateTagRequest 创建标签请求结构体
+type CreateTagRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
+
+// GetTags 查询当前用户的标签
+// GET /tags
+func GetTags(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.ListTags(userByToken.ID)
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// CreateTag 创建标签
+// POST /tags {"name": "kinase", "color": "#1E88E5"}
+func CreateTag(c *gin.Context) {
+	var req CreateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.CreateTag(userByToken.ID, req.Name, req.Color)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// TagIdRequest 指定标签ID的请求结构体
+type TagIdRequest struct {
+	Id uint `json:"id" binding:"required"`
+}
+
+// DeleteTag 删除标签
+// POST /tags/delete {"id": 1}
+func DeleteTag(c *gin.Context) {
+	var req TagIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.DeleteTag(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Deleted successfully")
+}
+
+// TagTasksRequest 批量标记任务请求结构体
+type TagTasksRequest struct {
+	TaskIds []uint `json:"taskIds" binding:"required"`
+	TagIds  []uint `json:"tagIds" binding:"required"`
+	Remove  bool   `json:"remove"`
+}
+
+// TagTasks 批量为任务添加或移除标签
+// POST /tasks/tag {"taskIds": [1, 2], "tagIds": [3], "remove": false}
+func TagTasks(c *gin.Context) {
+	var req TagTasksRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.TaskIds) == 0 || len(req.TagIds) == 0 {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.TagTasks(userByToken.ID, req.TaskIds, req.TagIds, req.Remove); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
+
+// GetFolders 查询当前用户的文件夹
+// GET /folders
+func GetFolders(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.ListFolders(userByToken.ID)
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// FolderRequest 创建或更新文件夹请求结构体
+type FolderRequest struct {
+	Id       uint   `json:"id"`
+	Name     string `json:"name" binding:"required"`
+	ParentId uint   `json:"parentId"` // 0 表示根目录
+}
+
+// CreateFolder 创建文件夹
+// POST /folders {"name": "Kinases", "parentId": 0}
+func CreateFolder(c *gin.Context) {
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.CreateFolder(userByToken.ID, req.Name, req.ParentId)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// UpdateFolder 重命名或移动文件夹
+// POST /folders/update {"id": 1, "name": "Kinases", "parentId": 2}
+func UpdateFolder(c *gin.Context) {
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Id == 0 {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.UpdateFolder(userByToken.ID, req.Id, req.Name, req.ParentId)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// FolderIdRequest 指定文件夹ID的请求结构体
+type FolderIdRequest struct {
+	Id uint `json:"id" binding:"required"`
+}
+
+// DeleteFolder 删除文件夹，内容移动到上一级
+// POST /folders/delete {"id": 1}
+func DeleteFolder(c *gin.Context) {
+	var req FolderIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.DeleteFolder(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Deleted successfully")
+}
+
+// MoveTasksRequest 批量移动任务请求结构体
+type MoveTasksRequest struct {
+	TaskIds  []uint `json:"taskIds" binding:"required"`
+	FolderId uint   `json:"folderId"` // 0 表示根目录
+}
+
+// MoveTasks 批量移动任务到文件夹
+// POST /tasks/move {"taskIds": [1, 2], "folderId": 3}
+func MoveTasks(c *gin.Context) {
+	var req MoveTasksRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.TaskIds) == 0 {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.MoveTasks(userByToken.ID, req.TaskIds, req.FolderId); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
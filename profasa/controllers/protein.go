@@ -38,7 +38,7 @@ func SequenceSearch(c *gin.Context) {
 	// find or create protein information
 	// main sequence
 	if task.StructurePredictionTool != nil {
-		services.ProteinInformation(task.Sequence, "", *task.StructurePredictionTool)
+		services.ProteinInformation(task.Sequence, "", *task.StructurePredictionTool, uint(task.UserId))
 	}
 	// subSequences
 	for i := range subSequences {
@@ -48,7 +48,7 @@ func SequenceSearch(c *gin.Context) {
 			blastinformationstr = string(blastinformation)
 		}
 		if task.StructurePredictionTool != nil {
-			services.ProteinInformation(subSequences[i], blastinformationstr, *task.StructurePredictionTool)
+			services.ProteinInformation(subSequences[i], blastinformationstr, *task.StructurePredictionTool, uint(task.UserId))
 		}
 	}
 
@@ -77,12 +77,12 @@ func StructurePrediction(c *gin.Context) {
 	// find or create protein information
 	// main sequence
 	if task.StructurePredictionTool != nil {
-		services.ProteinInformation(task.Sequence, "", *task.StructurePredictionTool)
+		services.ProteinInformation(task.Sequence, "", *task.StructurePredictionTool, uint(task.UserId))
 	}
 	// subSequences
 	for i := range subSequences {
 		if task.StructurePredictionTool != nil {
-			services.ProteinInformation(subSequences[i], "", *task.StructurePredictionTool)
+			services.ProteinInformation(subSequences[i], "", *task.StructurePredictionTool, uint(task.UserId))
 		}
 	}
 
@@ -119,6 +119,7 @@ func Superimpose(c *gin.Context) {
 		utils.Error(c, 400, result.Error)
 		return
 	}
+	services.CheckTaskQuota(userByToken.ID)
 	utils.Success(c, gin.H{"id": result.ID}, "ok")
 }
 
@@ -143,6 +144,7 @@ func Single(c *gin.Context) {
 		utils.Error(c, 400, result.Error)
 		return
 	}
+	services.CheckTaskQuota(userByToken.ID)
 	utils.Success(c, gin.H{"id": result.ID}, "ok")
 }
 
@@ -171,9 +173,32 @@ func Blast(c *gin.Context) {
 		return
 	}
 
+	services.CheckTaskQuota(user.ID)
 	utils.Success(c, gin.H{"id": result.ID}, "ok")
 }
 
+// RerunTask 以新的预测工具或 BLAST 参数重新运行任务，创建关联的新任务
+// POST /task/rerun {"taskId": 1, "type": "esm", "evalue": 0.001}
+func RerunTask(c *gin.Context) {
+	var req services.RerunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result := services.RerunTask(userByToken.ID, req)
+	if result.Error != "" {
+		utils.Error(c, 400, result.Error)
+		return
+	}
+
+	services.CheckTaskQuota(userByToken.ID)
+	utils.Success(c, result, "ok")
+}
+
 // ViewNote 查看用户对某个序列的注释
 // POST /viewNote {"sequenceId": 123}
 type ViewNoteRequest struct {
@@ -196,7 +221,7 @@ func ViewNote(c *gin.Context) {
 		return
 	}
 
-	utils.Success(c, gin.H{"data": result.Data}, "ok")
+	utils.Success(c, gin.H{"data": result.Data, "version": result.Version}, "ok")
 }
 
 // UpdateNote 更新或创建用户对某个序列的注释
@@ -204,6 +229,7 @@ func ViewNote(c *gin.Context) {
 type UpdateNoteRequest struct {
 	No         string `json:"no" binding:"required"`
 	SequenceId uint   `json:"sequenceId" binding:"required"`
+	Version    *int64 `json:"version"` // 读取注释时得到的版本号，用于拒绝过期的写入
 }
 
 func UpdateNote(c *gin.Context) {
@@ -216,13 +242,17 @@ func UpdateNote(c *gin.Context) {
 	account, _ := c.Get("account")
 	userByToken := account.(*services.AccountClaims).User
 
-	result := services.UpdateNote(req.No, userByToken.ID, req.SequenceId)
+	result := services.UpdateNote(req.No, userByToken.ID, req.SequenceId, req.Version)
+	if result.Conflict {
+		utils.Error(c, 409, result.Error)
+		return
+	}
 	if result.Error != "" {
 		utils.Error(c, 400, result.Error)
 		return
 	}
 
-	utils.Success(c, gin.H{"msg": result.Message}, "ok")
+	utils.Success(c, gin.H{"msg": result.Message, "version": result.Version}, "ok")
 }
 
 // FoldRequest fold请求结构体
@@ -262,6 +292,7 @@ func Fold(c *gin.Context) {
 		return
 	}
 
+	services.CheckTaskQuota(user.ID)
 	utils.Success(c, gin.H{"id": result.ID}, "ok")
 }
 
@@ -688,13 +719,37 @@ func GetBlastList(c *gin.Context) {
 	// 解析分页参数
 	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
-	title := c.Query("title")
-	category := c.Query("category")
-	createStart := c.Query("createStart")
-	createEnd := c.Query("createEnd")
+	if current < 1 {
+		current = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	query := services.BlastListQuery{
+		Current:     current,
+		PageSize:    pageSize,
+		Title:       c.Query("title"),
+		Category:    c.Query("category"),
+		CreateStart: c.Query("createStart"),
+		CreateEnd:   c.Query("createEnd"),
+		TaskType:    c.Query("taskType"),
+		Status:      c.Query("status"),
+		SortField:   c.Query("sortField"),
+		SortOrder:   c.Query("sortOrder"),
+		Scope:       c.Query("scope"),
+	}
+	if tagId, err := strconv.ParseUint(c.Query("tagId"), 10, 32); err == nil {
+		query.TagId = uint(tagId)
+	}
+	if folderId, err := strconv.ParseUint(c.Query("folderId"), 10, 32); err == nil {
+		id := uint(folderId)
+		query.FolderId = &id
+	}
+	query.MinLength, _ = strconv.Atoi(c.Query("minLength"))
+	query.MaxLength, _ = strconv.Atoi(c.Query("maxLength"))
 
 	// 查询
-	result, err := services.GetBlastList(int64(userByToken.ID), current, pageSize, title, category, createStart, createEnd)
+	result, err := services.GetBlastList(int64(userByToken.ID), query)
 	if err != nil {
 		utils.Success(c, 500, err.Error())
 		return
@@ -712,8 +767,11 @@ func GetBlastResult(c *gin.Context) {
 		return
 	}
 
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
 	// 调用服务层获取结果
-	result, err := services.GetBlastResult(req.ID)
+	result, err := services.GetBlastResult(req.ID, userByToken.ID)
 	if err != nil {
 		utils.Error(c, 500, err.Error())
 		return
@@ -794,17 +852,20 @@ func TaskDetails(c *gin.Context) {
 	utils.Success(c, data, "ok")
 }
 
+// DeleteTask 将任务移入回收站
+// POST /task/delete {"id": 1}
 func DeleteTask(c *gin.Context) {
-	var task models.Task
-	if id, err := strconv.Atoi(c.Param("id")); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req TaskIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
 		return
-	} else {
-		task.ID = uint(id)
 	}
-	if err := database.Database.Delete(&task).Error; err != nil {
-		logger.Error("删除任务失败: %v", err)
-		utils.Error(c, 500, "Delete task failed")
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.DeleteTask(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
 		return
 	}
 	utils.Success(c, nil, "Deleted successfully")
@@ -1008,8 +1069,9 @@ func createZipFromDir(sourceDir, zipPath string) error {
 
 // ShareBlastRequest shareBlast请求结构体
 type ShareBlastRequest struct {
-	SeqId  uint `json:"seqId" binding:"required"`  // 序列ID
-	UserId uint `json:"userId" binding:"required"` // 目标用户ID
+	SeqId      uint   `json:"seqId" binding:"required"`  // 序列ID
+	UserId     uint   `json:"userId" binding:"required"` // 目标用户ID
+	Permission string `json:"permission"`                // view, annotate, rerun，默认 view
 }
 
 // ShareBlast 分享Blast结果给其他用户
@@ -1024,17 +1086,8 @@ func ShareBlast(c *gin.Context) {
 	account, _ := c.Get("account")
 	userByToken := account.(*services.AccountClaims).User
 
-	// 创建分享记录
-	share := &models.Share{
-		FromId: userByToken.ID,
-		ToId:   req.UserId,
-		TaskId: req.SeqId,
-		SeqId:  req.SeqId,
-		Status: 0, // 0: 未处理
-	}
-
-	if err := database.Database.Create(&share).Error; err != nil {
-		utils.Error(c, 500, "database error!")
+	if err := services.CreateShare(userByToken.ID, userByToken.Email, req.UserId, req.SeqId, req.Permission); err != nil {
+		utils.Error(c, 400, err.Error())
 		return
 	}
 
@@ -1046,7 +1099,7 @@ type AgreeShareBlastRequest struct {
 	Id uint `json:"id" binding:"required"` // 分享记录ID
 }
 
-// AgreeShareBlast 同意分享Blast结果
+// AgreeShareBlast 同意分享Blast结果，同意后按分享权限访问原任务
 func AgreeShareBlast(c *gin.Context) {
 	var req AgreeShareBlastRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1054,40 +1107,11 @@ func AgreeShareBlast(c *gin.Context) {
 		return
 	}
 
-	// 查找分享记录
-	var share models.Share
-	if err := database.Database.Where("id = ?", req.Id).First(&share).Error; err != nil {
-		utils.Error(c, 400, "Share record not found")
-		return
-	}
-
-	// 查找原始任务（用于复制任务信息）
-	var originalTask models.Task
-	if err := database.Database.Where("id = ?", share.TaskId).First(&originalTask).Error; err != nil {
-		utils.Error(c, 400, "Original task not found")
-		return
-	}
-
-	// 为接收用户创建新的任务记录
-	newTask := models.Task{
-		Title:                   originalTask.Title,
-		Sequence:                originalTask.Sequence,
-		Type:                    originalTask.Type,
-		StructurePredictionTool: originalTask.StructurePredictionTool,
-		UserId:                  int64(share.ToId),
-		SubSequence:             originalTask.SubSequence,
-		ModelId:                 originalTask.ModelId,
-	}
-
-	if err := database.Database.Create(&newTask).Error; err != nil {
-		utils.Error(c, 500, "Network error!")
-		return
-	}
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
 
-	// 更新分享状态为同意
-	share.Status = 1
-	if err := database.Database.Save(&share).Error; err != nil {
-		utils.Error(c, 500, "Network error!")
+	if err := services.AgreeShare(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
 		return
 	}
 
@@ -1107,17 +1131,11 @@ func RefuseShareBlast(c *gin.Context) {
 		return
 	}
 
-	// 查找分享记录
-	var share models.Share
-	if err := database.Database.Where("id = ?", req.Id).First(&share).Error; err != nil {
-		utils.Error(c, 400, "Share record not found")
-		return
-	}
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
 
-	// 更新分享状态为拒绝
-	share.Status = 2
-	if err := database.Database.Save(&share).Error; err != nil {
-		utils.Error(c, 500, "Network error!")
+	if err := services.RefuseShare(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
 		return
 	}
 
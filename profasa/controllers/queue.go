@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CancelTask 取消任务中所有等待或执行中的预测
+// POST /task/cancel {"id": 1}
+func CancelTask(c *gin.Context) {
+	var req TaskIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result := services.CancelTaskJobs(userByToken.ID, req.Id)
+	if result.Error != "" {
+		utils.Error(c, 400, result.Error)
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// CancelQueueJob 取消单条预测队列记录
+// POST /queue/cancel {"tool": "alpha", "id": 1}
+func CancelQueueJob(c *gin.Context) {
+	var req services.CancelJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result := services.CancelQueueJob(userByToken.ID, req)
+	if result.Error != "" {
+		utils.Error(c, 400, result.Error)
+		return
+	}
+	utils.Success(c, result, "Cancelled successfully")
+}
+
+// SetJobPriority 管理员修改待处理预测记录的优先级
+// POST /queue/priority {"tool": "alpha", "id": 1, "priority": 5}
+func SetJobPriority(c *gin.Context) {
+	var req services.SetJobPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+	if !services.IsAdmin(userByToken.ID) {
+		utils.Error(c, 403, "Permission denied.")
+		return
+	}
+
+	if err := services.SetJobPriority(req); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Updated successfully")
+}
+
+// SetUserQueuePriority 管理员修改用户提交任务的默认优先级
+// POST /admin/users/priority {"userId": 1, "priority": -5}
+func SetUserQueuePriority(c *gin.Context) {
+	var req services.SetUserPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+	if !services.IsAdmin(userByToken.ID) {
+		utils.Error(c, 403, "Permission denied.")
+		return
+	}
+
+	if err := services.SetUserQueuePriority(req); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Updated successfully")
+}
+
+// GetJobHistory 管理员查询已归档的预测记录
+// GET /admin/jobs/history?predictor=alpha&status=failed&userId=1&from=1700000000000&to=1700086400000&current=1&pageSize=20
+func GetJobHistory(c *gin.Context) {
+	var query services.JobHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+	if !services.IsAdmin(userByToken.ID) {
+		utils.Error(c, 403, "Permission denied.")
+		return
+	}
+
+	result, err := services.ListJobHistory(query)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// GetPredictors 获取可用的预测工具
+// GET /predictors
+func GetPredictors(c *gin.Context) {
+	utils.Success(c, services.Predictors(), "ok")
+}
+
+// GetJobLog 查看预测记录日志的末尾部分，指定 offset 时只返回新的输出，用于实时查看
+// GET /job/log?tool=alpha&id=12&tail=200 或 /job/log?tool=alpha&id=12&offset=4096
+func GetJobLog(c *gin.Context) {
+	var req services.JobLogRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	log, err := services.GetJobLog(userByToken.ID, req)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, log, "ok")
+}
+
+// DownloadJobLog 下载预测记录的完整日志
+// GET /job/log/download?tool=alpha&id=12
+func DownloadJobLog(c *gin.Context) {
+	var req services.JobLogRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	files, err := services.JobLogFiles(userByToken.ID, req.Tool, req.Id)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%d.log", req.Tool, req.Id))
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(200)
+	for _, path := range files {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		io.Copy(c.Writer, file)
+		file.Close()
+	}
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search 在当前用户可访问的任务、注释和结构域中全文检索
+// 分页的结果在 list 中；查询像序列片段时，直接匹配任务序列的结果在不分页的 sequences 中
+// GET /search?q=zinc finger&current=1&pageSize=10
+func Search(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if current < 1 {
+		current = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	result, err := services.Search(userByToken.ID, keyword, current, pageSize)
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shareStatusQuery 解析可选的 status 查询参数
+func shareStatusQuery(c *gin.Context) *int64 {
+	status, err := strconv.ParseInt(c.Query("status"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &status
+}
+
+// GetOutgoingShares 查询当前用户发出的分享
+// GET /shares/outgoing?status=0
+func GetOutgoingShares(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.ListOutgoingShares(userByToken.ID, shareStatusQuery(c))
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// GetIncomingShares 查询当前用户收到的分享
+// GET /shares/incoming?status=1
+func GetIncomingShares(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.ListIncomingShares(userByToken.ID, shareStatusQuery(c))
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// ShareIdRequest 指定分享记录ID的请求结构体
+type ShareIdRequest struct {
+	Id uint `json:"id" binding:"required"`
+}
+
+// RevokeShare 撤销分享，接收者也可以用来退出分享
+// POST /shares/revoke {"id": 1}
+func RevokeShare(c *gin.Context) {
+	var req ShareIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.RevokeShare(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Revoked successfully")
+}
+
+// UpdateSharePermissionRequest 修改分享权限请求结构体
+type UpdateSharePermissionRequest struct {
+	Id         uint   `json:"id" binding:"required"`
+	Permission string `json:"permission" binding:"required"` // view, annotate, rerun
+}
+
+// UpdateSharePermission 修改分享权限
+// POST /shares/permission {"id": 1, "permission": "annotate"}
+func UpdateSharePermission(c *gin.Context) {
+	var req UpdateSharePermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.UpdateSharePermission(userByToken.ID, req.Id, req.Permission); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
@@ -10,10 +10,16 @@ func UploadFile(c *gin.Context) {
 
 }
 
-// GetQueueStatus 获取队列状态
+// GetQueueStatus 获取队列状态和调度顺序
+// GET /queue/status
 func GetQueueStatus(c *gin.Context) {
 	queueScheduler := services.GetGlobalQueueScheduler()
 	status := queueScheduler.GetQueueStatus()
+
+	// 附加调度顺序，非管理员只能看到自己的记录
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+	status["order"] = services.QueueOrder(userByToken.ID)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTaskProgress 查看任务流水线每个阶段的状态、耗时和错误
+// GET /task/progress?id=1
+func GetTaskProgress(c *gin.Context) {
+	taskId, err := strconv.ParseUint(c.Query("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	progress, err := services.GetTaskProgress(userByToken.ID, uint(taskId))
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, progress, "ok")
+}
+
+// RerunTaskStage 重新运行任务中的一个阶段
+// POST /task/stage/rerun {"taskId": 1, "stage": "parameters", "proteinId": 12}
+func RerunTaskStage(c *gin.Context) {
+	var req services.RerunStageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.RerunTaskStage(userByToken.ID, req); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
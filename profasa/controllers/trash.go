@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskIdRequest 指定任务ID的请求结构体
+type TaskIdRequest struct {
+	Id uint `json:"id" binding:"required"`
+}
+
+// GetTrash 分页查询回收站中的任务
+// GET /trash?current=1&pageSize=10
+func GetTrash(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if current < 1 {
+		current = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	result, err := services.ListTrash(userByToken.ID, current, pageSize)
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// RestoreTask 从回收站恢复任务
+// POST /trash/restore {"id": 1}
+func RestoreTask(c *gin.Context) {
+	var req TaskIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.RestoreTask(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Restored successfully")
+}
+
+// PurgeTask 立即永久删除回收站中的任务
+// POST /trash/purge {"id": 1}
+func PurgeTask(c *gin.Context) {
+	var req TaskIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.PurgeTask(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Deleted successfully")
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhookRequest 注册回调请求结构体
+type CreateWebhookRequest struct {
+	Url    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"` // job.completed, job.failed，为空表示全部
+}
+
+// CreateWebhook 注册回调地址
+// POST /webhooks {"url": "https://example.com/hook", "secret": "xxx", "events": ["job.completed"]}
+func CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.CreateWebhook(userByToken.ID, req.Url, req.Secret, req.Events)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// GetWebhooks 查询当前用户的回调列表
+// GET /webhooks
+func GetWebhooks(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.ListWebhooks(userByToken.ID)
+	if err != nil {
+		utils.Error(c, 500, "Network error.")
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// WebhookIdRequest 指定回调ID的请求结构体
+type WebhookIdRequest struct {
+	Id uint `json:"id" binding:"required"`
+}
+
+// DeleteWebhook 删除回调
+// POST /webhooks/delete {"id": 1}
+func DeleteWebhook(c *gin.Context) {
+	var req WebhookIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	if err := services.DeleteWebhook(userByToken.ID, req.Id); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, nil, "Deleted successfully")
+}
+
+// TestWebhook 向回调地址发送一次 ping 事件并返回投递结果
+// POST /webhooks/test {"id": 1}
+func TestWebhook(c *gin.Context) {
+	var req WebhookIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	result, err := services.TestWebhook(userByToken.ID, req.Id)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
+
+// GetWebhookDeliveries 分页查询回调的投递记录
+// GET /webhooks/deliveries?id=1&current=1&pageSize=10
+func GetWebhookDeliveries(c *gin.Context) {
+	account, _ := c.Get("account")
+	userByToken := account.(*services.AccountClaims).User
+
+	id, err := strconv.ParseUint(c.Query("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+	current, _ := strconv.Atoi(c.DefaultQuery("current", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	if current < 1 {
+		current = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	result, err := services.ListWebhookDeliveries(userByToken.ID, uint(id), current, pageSize)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, result, "ok")
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"Protein_Server/services"
+	"Protein_Server/utils"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerLease 远程 worker 租用一条待处理的预测记录，没有可执行的记录时 data 为 null
+// POST /worker/lease {"workerId": "gpu-01", "predictors": ["alpha"]}
+func WorkerLease(c *gin.Context) {
+	var req services.WorkerLeaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+
+	job, err := services.LeaseWorkerJob(req)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, job, "ok")
+}
+
+// WorkerHeartbeat 远程 worker 续期执行中记录的租约，返回已被取消或回收的记录
+// POST /worker/heartbeat {"workerId": "gpu-01", "jobs": [{"tool": "alpha", "id": 12}]}
+func WorkerHeartbeat(c *gin.Context) {
+	var req services.WorkerHeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+	utils.Success(c, services.WorkerHeartbeat(req), "ok")
+}
+
+// WorkerComplete 远程 worker 上传生成的模型和日志
+// POST /worker/complete multipart: workerId, tool, id, duration, model（PDB 文件）, log（可选）
+func WorkerComplete(c *gin.Context) {
+	var req services.WorkerCompleteRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+	modelHeader, err := c.FormFile("model")
+	if err != nil {
+		utils.Error(c, 400, "Model file is required.")
+		return
+	}
+	model, err := modelHeader.Open()
+	if err != nil {
+		utils.Error(c, 500, "Failed to read model file.")
+		return
+	}
+	defer model.Close()
+
+	var log io.Reader
+	if logHeader, err := c.FormFile("log"); err == nil {
+		if file, err := logHeader.Open(); err == nil {
+			defer file.Close()
+			log = file
+		}
+	}
+
+	if err := services.CompleteWorkerJob(req, model, log); err != nil {
+		utils.Error(c, workerErrorCode(err), err.Error())
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
+
+// WorkerFail 远程 worker 报告执行失败
+// POST /worker/fail {"workerId": "gpu-01", "tool": "alpha", "id": 12, "error": "...", "transient": false, "log": "..."}
+func WorkerFail(c *gin.Context) {
+	var req services.WorkerFailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "Parameter error")
+		return
+	}
+	if err := services.FailWorkerJob(req); err != nil {
+		utils.Error(c, workerErrorCode(err), err.Error())
+		return
+	}
+	utils.Success(c, nil, "ok")
+}
+
+// workerErrorCode 失去租约时返回 409，worker 不再重试；其他错误返回 500，worker 可以重试
+func workerErrorCode(err error) int {
+	if errors.Is(err, services.ErrWorkerLeaseLost) {
+		return 409
+	}
+	return 500
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// alphaFoldPredictor 在本机运行 AlphaFold
+type alphaFoldPredictor struct {
+	limits  predictorLimits
+	workers int
+}
+
+func newAlphaFoldPredictor() *alphaFoldPredictor {
+	return &alphaFoldPredictor{
+		limits:  alphaFoldLimits,
+		workers: workerCount("ALPHAFOLD_WORKERS", 1),
+	}
+}
+
+func (p *alphaFoldPredictor) Info() PredictorInfo {
+	return PredictorInfo{Key: "alpha", Name: "AlphaFold", Type: 1, Kind: "local", Workers: p.workers, Version: predictorVersion("ALPHAFOLD")}
+}
+
+func (p *alphaFoldPredictor) Validate(sequence string) error {
+	return validateSequence(sequence, 0)
+}
+
+func (p *alphaFoldPredictor) Run(job PredictionJob) error {
+	outputDir := filepath.Join(job.Workspace, "output")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	// Run the AlphaFold command
+	logger.Info("开始执行AlphaFold命令，工作目录: %s", job.Workspace)
+	cmd := p.limits.command("bash", "-c", fmt.Sprintf("source /root/miniconda3/etc/profile.d/conda.sh && conda activate alphafold && bash ../alphafold/run_alphafold.sh -d ../alphadata -o %q -f %q -t 2021-11-01 -g False -c reduced_dbs", outputDir, job.FastaPath))
+	return job.RunCommand(cmd, p.limits)
+}
+
+// Collect AlphaFold 按 FASTA 文件名在输出目录中创建子目录
+func (p *alphaFoldPredictor) Collect(job PredictionJob) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(job.FastaPath), filepath.Ext(job.FastaPath))
+	return existingFile(filepath.Join(job.Workspace, "output", name, "unrelaxed_model_1.pdb"))
+}
+
+func IsFasta(seq string) bool {
+	// Define valid amino acid characters
+	validAminoAcids := "ACDEFGHIKLMNPQRSTVWY"
+
+	// Convert sequence to uppercase for case-insensitive comparison
+	seq = strings.ToUpper(seq)
+
+	// Check each character in the sequence
+	for _, char := range seq {
+		// If character is not found in validAminoAcids, return false
+		if !strings.ContainsRune(validAminoAcids, char) {
+			return false
+		}
+	}
+
+	// All characters are valid
+	return true
+}
@@ -8,11 +8,11 @@ import (
 
 // Add To Alpha Fold Queue
 func AddToAlphaFoldQueue(sequence string) {
-	AddToAlphaFoldQueueWithParent(sequence, nil)
+	AddToAlphaFoldQueueWithParent(sequence, nil, 0)
 }
 
 // Add To Alpha Fold Queue with parent ID
-func AddToAlphaFoldQueueWithParent(sequence string, parentId *int64) {
+func AddToAlphaFoldQueueWithParent(sequence string, parentId *int64, userId uint) {
 	var alphafoldQueue models.AlphaFoldQueue
 	
 	// 构建查询条件
@@ -29,12 +29,13 @@ func AddToAlphaFoldQueueWithParent(sequence string, parentId *int64) {
 			alphafoldQueue = models.AlphaFoldQueue{
 				Sequence: sequence,
 				ParentId: parentId,
-				Status:   "pending",
+				QueueJob: newQueueJob(userId),
 			}
 			if err := database.Database.Create(&alphafoldQueue).Error; err != nil {
 				logger.Error("创建AlphaFold队列失败: %v", err)
 			} else {
 				logger.Info("已添加序列到AlphaFold队列，ID: %d", alphafoldQueue.ID)
+				WakeQueueScheduler()
 			}
 		} else {
 			logger.Error("查询AlphaFold队列失败: %v", err)
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 未指定颜色时按类别使用的默认颜色
+var annotationCategoryColors = map[string]string{
+	"active_site":    "#E53935",
+	"binding_site":   "#FB8C00",
+	"domain":         "#1E88E5",
+	"motif":          "#8E24AA",
+	"mutation":       "#43A047",
+	"disordered":     "#757575",
+	"transmembrane":  "#00897B",
+	"signal_peptide": "#FDD835",
+}
+
+var (
+	annotationColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	annotationChainPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,4}$`)
+)
+
+// AnnotationRequest 创建或更新残基区间注释的请求
+type AnnotationRequest struct {
+	Id           uint   `json:"id"`
+	ProteinId    uint   `json:"proteinId"`
+	Chain        string `json:"chain"`
+	StartResidue int    `json:"start" binding:"required"`
+	EndResidue   int    `json:"end" binding:"required"`
+	Label        string `json:"label" binding:"required"`
+	Category     string `json:"category"`
+	Color        string `json:"color"`
+}
+
+// AnnotationItem 残基区间注释
+type AnnotationItem struct {
+	ID           uint   `json:"id"`
+	ProteinId    uint   `json:"proteinId"`
+	Chain        string `json:"chain"`
+	StartResidue int    `json:"start"`
+	EndResidue   int    `json:"end"`
+	Label        string `json:"label"`
+	Category     string `json:"category"`
+	Color        string `json:"color"`
+	UserId       uint   `json:"userId"`
+	Author       string `json:"author"`
+	UpdatedAt    int64  `json:"updatedAt"`
+}
+
+// ListAnnotations 查询用户可见的某个蛋白质模型的注释：本人的注释，以及包含该模型、用户可以访问的任务的拥有者的注释
+func ListAnnotations(userId uint, proteinId uint) ([]AnnotationItem, error) {
+	if !UserCanAccessProtein(userId, proteinId) {
+		return nil, errors.New("Model not found.")
+	}
+	query, args := modelIdMatch(proteinId)
+	var ownerIds []uint
+	database.Database.Model(&models.Task{}).Where("id IN (?)", sharedTaskIds(userId)).Where(query, args...).
+		Distinct().Pluck("user_id", &ownerIds)
+	return annotationsForProteins([]uint{proteinId}, annotationAuthors(userId, ownerIds...))
+}
+
+// CreateAnnotation 创建残基区间注释，需要拥有任务或具有 annotate 分享权限
+func CreateAnnotation(userId uint, req AnnotationRequest) (AnnotationItem, error) {
+	if !UserHasProteinPermission(userId, req.ProteinId, SharePermissionAnnotate) {
+		return AnnotationItem{}, errors.New("Model not found.")
+	}
+	annotation := models.Annotation{
+		ProteinId: req.ProteinId,
+		UserId:    userId,
+	}
+	if err := applyAnnotationRequest(&annotation, req); err != nil {
+		return AnnotationItem{}, err
+	}
+	if err := database.Database.Create(&annotation).Error; err != nil {
+		return AnnotationItem{}, errors.New("Network error.")
+	}
+	return toAnnotationItem(annotation, ""), nil
+}
+
+// UpdateAnnotation 更新作者本人的注释
+func UpdateAnnotation(userId uint, req AnnotationRequest) (AnnotationItem, error) {
+	var annotation models.Annotation
+	if err := database.Database.Where("id = ? AND user_id = ?", req.Id, userId).First(&annotation).Error; err != nil {
+		return AnnotationItem{}, errors.New("Annotation not found.")
+	}
+	if !UserHasProteinPermission(userId, annotation.ProteinId, SharePermissionAnnotate) {
+		return AnnotationItem{}, errors.New("Permission denied.")
+	}
+	if err := applyAnnotationRequest(&annotation, req); err != nil {
+		return AnnotationItem{}, err
+	}
+	if err := database.Database.Save(&annotation).Error; err != nil {
+		return AnnotationItem{}, errors.New("Network error.")
+	}
+	return toAnnotationItem(annotation, ""), nil
+}
+
+// DeleteAnnotation 删除作者本人的注释
+func DeleteAnnotation(userId uint, annotationId uint) error {
+	result := database.Database.Where("id = ? AND user_id = ?", annotationId, userId).Delete(&models.Annotation{})
+	if result.Error != nil {
+		return errors.New("Network error.")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Annotation not found.")
+	}
+	return nil
+}
+
+// ExportTaskAnnotations 以 CSV 格式导出任务中所有模型的注释
+func ExportTaskAnnotations(userId uint, taskId uint) ([]byte, error) {
+	var task models.Task
+	if err := database.Database.Where("id = ?", taskId).First(&task).Error; err != nil || !UserCanAccessTask(userId, taskId) {
+		return nil, errors.New("Task not found.")
+	}
+
+	annotations, err := annotationsForProteins(parseModelIds(task.ModelId), annotationAuthors(userId, uint(task.UserId)))
+	if err != nil {
+		return nil, errors.New("Network error.")
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"protein_id", "chain", "start", "end", "label", "category", "color", "author"})
+	for _, a := range annotations {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(a.ProteinId), 10),
+			a.Chain,
+			strconv.Itoa(a.StartResidue),
+			strconv.Itoa(a.EndResidue),
+			a.Label,
+			a.Category,
+			a.Color,
+			a.Author,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, errors.New("Network error.")
+	}
+	return buf.Bytes(), nil
+}
+
+// annotationAuthors 查看者可以看到的注释作者：查看者本人和相关任务的拥有者
+// 模型注释列表、blast 结果和注释导出使用相同的范围
+func annotationAuthors(viewerId uint, ownerIds ...uint) []uint {
+	authors := []uint{viewerId}
+	for _, ownerId := range ownerIds {
+		if ownerId != viewerId {
+			authors = append(authors, ownerId)
+		}
+	}
+	return authors
+}
+
+// annotationsForProteins 查询指定作者在一组蛋白质模型上的注释
+func annotationsForProteins(proteinIds []uint, authorIds []uint) ([]AnnotationItem, error) {
+	if len(proteinIds) == 0 {
+		return []AnnotationItem{}, nil
+	}
+	var annotations []models.Annotation
+	if err := database.Database.Where("protein_id IN ? AND user_id IN ?", proteinIds, authorIds).
+		Order("protein_id, chain, start_residue").Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+
+	authors := make(map[uint]string)
+	var users []models.User
+	if err := database.Database.Where("id IN ?", authorIds).Find(&users).Error; err == nil {
+		for _, user := range users {
+			authors[user.ID] = user.Email
+		}
+	}
+
+	result := make([]AnnotationItem, 0, len(annotations))
+	for _, annotation := range annotations {
+		result = append(result, toAnnotationItem(annotation, authors[annotation.UserId]))
+	}
+	return result, nil
+}
+
+// applyAnnotationRequest 校验请求并写入注释字段，残基范围不能超过模型序列长度
+func applyAnnotationRequest(annotation *models.Annotation, req AnnotationRequest) error {
+	var proteinInfo models.ProteinInformation
+	if err := database.Database.Where("id = ?", annotation.ProteinId).First(&proteinInfo).Error; err != nil {
+		return errors.New("Model not found.")
+	}
+
+	chain := strings.TrimSpace(req.Chain)
+	if chain == "" {
+		chain = "A"
+	}
+	if !annotationChainPattern.MatchString(chain) {
+		return errors.New("Invalid chain.")
+	}
+
+	length := len(proteinInfo.Sequence)
+	if req.StartResidue < 1 || req.EndResidue < req.StartResidue || req.EndResidue > length {
+		return fmt.Errorf("Residue range must be within 1-%d.", length)
+	}
+
+	label := strings.TrimSpace(req.Label)
+	if label == "" || len([]rune(label)) > 255 {
+		return errors.New("Invalid label.")
+	}
+
+	category := strings.ToLower(strings.TrimSpace(req.Category))
+	if len(category) > 64 {
+		return errors.New("Invalid category.")
+	}
+
+	color := strings.TrimSpace(req.Color)
+	if color == "" {
+		color = annotationCategoryColors[category]
+	} else if !annotationColorPattern.MatchString(color) {
+		return errors.New("Invalid color, expected #RRGGBB.")
+	}
+
+	annotation.Chain = chain
+	annotation.StartResidue = req.StartResidue
+	annotation.EndResidue = req.EndResidue
+	annotation.Label = label
+	annotation.Category = category
+	annotation.Color = color
+	return nil
+}
+
+// parseModelIds 解析逗号分隔的 ModelId
+func parseModelIds(modelId string) []uint {
+	var ids []uint
+	for _, idStr := range strings.Split(modelId, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32); err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+func toAnnotationItem(annotation models.Annotation, author string) AnnotationItem {
+	return AnnotationItem{
+		ID:           annotation.ID,
+		ProteinId:    annotation.ProteinId,
+		Chain:        annotation.Chain,
+		StartResidue: annotation.StartResidue,
+		EndResidue:   annotation.EndResidue,
+		Label:        annotation.Label,
+		Category:     annotation.Category,
+		Color:        annotation.Color,
+		UserId:       annotation.UserId,
+		Author:       author,
+		UpdatedAt:    annotation.UpdatedAt.UnixMilli(),
+	}
+}
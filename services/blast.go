@@ -4,43 +4,79 @@ import (
 	"Protein_Server/logger"
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// 默认的 BLAST E-value 阈值
+const defaultBlastEvalue = 0.01
+
 // BLAST Processing
 func BlastProcessing(sequence string) ([]string, []map[string]string) {
-	// Create a fasta.txt file
-	file, err := os.Create("fasta.txt")
+	return BlastProcessingWithEvalue(sequence, defaultBlastEvalue)
+}
+
+// BLAST Processing with custom E-value threshold
+// 在临时目录中执行，多个请求可以同时运行
+func BlastProcessingWithEvalue(sequence string, evalue float64) ([]string, []map[string]string) {
+	dir, err := os.MkdirTemp("", "blast-")
 	if err != nil {
-		logger.Error("创建文件失败: %v", err)
+		logger.Error("创建BLAST工作目录失败: %v", err)
 		return nil, nil
 	}
-	// Write sequence to fasta.txt file
-	_, err = file.WriteString(sequence)
+	defer os.RemoveAll(dir)
+
+	if err := runRpsblast(dir, sequence, evalue, nil); err != nil {
+		logger.Error("%v", err)
+		return nil, nil
+	}
+	if err := runRpsbproc(dir, evalue, nil); err != nil {
+		logger.Error("%v", err)
+		return nil, nil
+	}
+	subSequences, results, err := extractDomains(dir, sequence)
 	if err != nil {
-		logger.Error("写入文件失败: %v", err)
+		logger.Error("%v", err)
 		return nil, nil
 	}
-	file.Close()
+	return subSequences, results
+}
+
+// runRpsblast 在 dir 中写入 fasta.txt 并运行 rpsblast，结果保存为 fasta.asn，log 不为 nil 时写入命令输出
+func runRpsblast(dir string, sequence string, evalue float64, log io.Writer) error {
+	// Create a fasta.txt file
+	queryPath := filepath.Join(dir, "fasta.txt")
+	// Write sequence to fasta.txt file
+	if err := os.WriteFile(queryPath, []byte(sequence), 0644); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
 
 	// rpsblast - used to calculate CD-Search (Conserved Domain Search)
 	// -db - blast/bin/db/Cdd database, use Cdd(Conserved Domain database)
 	// -outfmt - 11 is asn format
 	// cmd := "../RpsbProc-x64-linux/rpsblast -query fasta.txt -db ../RpsbProc-x64-linux/db/Cdd -evalue 0.01 -outfmt 11 -out fasta.asn"
-	// err = exec.Command(cmd).Run()
 	rpsblastPath := "../RpsbProc-x64-linux/rpsblast"
-	err = exec.Command(rpsblastPath,
-		"-query", "fasta.txt",
+	output, err := runLoggedCommand(exec.Command(rpsblastPath,
+		"-query", queryPath,
 		"-db", "../RpsbProc-x64-linux/db/Cdd",
-		"-evalue", "0.01",
+		"-evalue", strconv.FormatFloat(evalue, 'g', -1, 64),
 		"-outfmt", "11",
-		"-out", "fasta.asn").Run()
+		"-out", filepath.Join(dir, "fasta.asn")), log)
 	if err != nil {
-		logger.Error("运行rpsblast失败: %v", err)
-		return nil, nil
+		return fmt.Errorf("运行rpsblast失败: %v, 输出: %s", err, tailOutput(output, 2000))
+	}
+	return nil
+}
+
+// runRpsbproc 处理 dir 中 rpsblast 的结果 fasta.asn，输出 fasta.out，log 不为 nil 时写入命令输出
+func runRpsbproc(dir string, evalue float64, log io.Writer) error {
+	asnPath := filepath.Join(dir, "fasta.asn")
+	if _, err := os.Stat(asnPath); err != nil {
+		return fmt.Errorf("rpsblast 结果不存在: %v", err)
 	}
 
 	// rpsbproc is used to process rpsblast results
@@ -50,28 +86,28 @@ func BlastProcessing(sequence string) ([]string, []map[string]string) {
 	// -m the result mode, std is the standard result, rep is the concise result, full is the full result
 	// -t doms only needs domains
 	// cmd = "../RpsbProc-x64-linux/rpsbproc -i fasta.asn -o fasta.out -e 0.01 -m std -t doms"
-	// err = exec.Command(cmd).Run()
 	rpsbprocPath := "../RpsbProc-x64-linux/rpsbproc"
-	err = exec.Command(rpsbprocPath,
-		"-i", "fasta.asn",
-		"-o", "fasta.out",
-		"-e", "0.01",
+	output, err := runLoggedCommand(exec.Command(rpsbprocPath,
+		"-i", asnPath,
+		"-o", filepath.Join(dir, "fasta.out"),
+		"-e", strconv.FormatFloat(evalue, 'g', -1, 64),
 		"-m", "std",
-		"-t", "doms").Run()
+		"-t", "doms"), log)
 	if err != nil {
-		logger.Error("运行rpsbproc失败: %v", err)
-		return nil, nil
+		return fmt.Errorf("运行rpsbproc失败: %v, 输出: %s", err, tailOutput(output, 2000))
 	}
+	return nil
+}
 
-	results, err := parseFastaResult()
+// extractDomains 解析 dir 中 rpsbproc 的结果 fasta.out，返回结构域序列和对应的描述信息
+func extractDomains(dir string, sequence string) ([]string, []map[string]string, error) {
+	results, err := parseFastaResult(filepath.Join(dir, "fasta.out"))
 	if err != nil {
-		logger.Error("解析 fasta 结果失败: %v", err)
-		return nil, nil
+		return nil, nil, fmt.Errorf("解析 fasta 结果失败: %v", err)
 	}
 
 	if len(results) == 0 {
-		logger.Error("没有找到有效的子序列")
-		return nil, nil
+		return nil, nil, fmt.Errorf("没有找到有效的子序列")
 	}
 
 	var subSequences []string
@@ -139,11 +175,11 @@ func BlastProcessing(sequence string) ([]string, []map[string]string) {
 		subSequences = append(subSequences, subSequence)
 	}
 
-	return subSequences, results
+	return subSequences, results, nil
 }
 
-func parseFastaResult() ([]map[string]string, error) {
-	file, err := os.Open("fasta.out")
+func parseFastaResult(path string) ([]map[string]string, error) {
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -6,6 +6,7 @@ import (
 	"Protein_Server/models"
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -34,17 +35,12 @@ type FoldResponse struct {
 	Error string `json:"error,omitempty"`
 }
 
+// BlastTypeStringToInt 按预测工具名称查找保存在任务中的编号，未注册的工具返回 0
 func BlastTypeStringToInt(typeStr string) int64 {
-	switch typeStr {
-	case "alpha":
-		return 1
-	case "itasser":
-		return 2
-	case "esm":
-		return 3
-	default:
-		return 0 // 非法类型
+	if tool, ok := queueTools[typeStr]; ok {
+		return tool.predictor.Info().Type
 	}
+	return 0 // 非法类型
 }
 
 // Blast 处理 blast 请求的主要函数
@@ -53,10 +49,13 @@ func Blast(code, title string, typeStr string, userId int64) BlastResponse {
 	if typeValue == 0 {
 		return BlastResponse{Error: "Invalid type."}
 	}
+	if err := ValidatePredictorInput(typeValue, code); err != nil {
+		return BlastResponse{Error: err.Error()}
+	}
 
-	// 检查是否已存在相同序列的任务（不考虑标题和用户，只检查序列）
+	// 检查当前用户是否已用同一预测工具提交过相同序列的任务
 	var existingTask models.Task
-	if err := database.Database.Where("sequence = ?", code).First(&existingTask).Error; err == nil {
+	if err := database.Database.Where("sequence = ? AND user_id = ? AND type = ? AND structure_prediction_tool = ?", code, userId, 1, typeValue).First(&existingTask).Error; err == nil {
 		// 如果找到相同序列的任务，直接返回该任务的ID
 		return BlastResponse{ID: existingTask.ID}
 	}
@@ -70,12 +69,6 @@ func Blast(code, title string, typeStr string, userId int64) BlastResponse {
 		return BlastResponse{Error: "项目已存在"}
 	}
 
-	// 调用 BlastProcessing 处理序列
-	subSequences, blastInformations := BlastProcessing(code)
-	if subSequences == nil {
-		return BlastResponse{Error: "BLAST 处理失败"}
-	}
-
 	// 查找主序列在 protein_information 表中是否存在
 	var mainProteinInfo models.ProteinInformation
 	if err := database.Database.Where("sequence = ?", code).Find(&mainProteinInfo).Error; err != nil {
@@ -83,42 +76,22 @@ func Blast(code, title string, typeStr string, userId int64) BlastResponse {
 	}
 
 	// 查找主序列在所有队列中是否存在
-	var mainQueueCount int64
-	if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("sequence = ?", code).Count(&mainQueueCount).Error; err != nil {
-		return BlastResponse{Error: "查询 AlphaFold 队列失败"}
-	}
-	if mainQueueCount == 0 {
-		if err := database.Database.Model(&models.ITasserQueue{}).Where("sequence = ?", code).Count(&mainQueueCount).Error; err != nil {
-			return BlastResponse{Error: "查询 I-Tasser 队列失败"}
-		}
-	}
-	if mainQueueCount == 0 {
-		if err := database.Database.Model(&models.ESMQueue{}).Where("sequence = ?", code).Count(&mainQueueCount).Error; err != nil {
-			return BlastResponse{Error: "查询 ESM 队列失败"}
-		}
+	mainQueueCount, err := countSequenceInQueues(code)
+	if err != nil {
+		return BlastResponse{Error: "查询预测队列失败"}
 	}
 
-	// 如果主序列既不在 protein_information 中也不在队列中，则添加到队列或同步处理
+	// 如果主序列既不在 protein_information 中也不在队列中，则创建记录并添加到队列
 	if mainProteinInfo.ID == 0 && mainQueueCount == 0 {
-		// 只有新序列才需要创建记录和添加到队列
-		if typeValue == 3 { // ESM - 同步处理
-			// 对ESM，先创建蛋白质信息记录
-			ProteinInformation(code, "", typeValue)
-			// 重新查询获取创建后的ID
-			if err := database.Database.Where("sequence = ?", code).Find(&mainProteinInfo).Error; err != nil {
-				return BlastResponse{Error: "查询主序列蛋白质信息失败"}
-			}
-		} else {
-			// 对AlphaFold和I-Tasser，先创建蛋白质信息记录，再添加到队列
-			ProteinInformation(code, "", typeValue)
-			// 重新查询获取创建后的ID
-			if err := database.Database.Where("sequence = ?", code).Find(&mainProteinInfo).Error; err != nil {
-				return BlastResponse{Error: "查询主序列蛋白质信息失败"}
-			}
+		// 只有新序列才需要创建记录，创建时按预测工具添加到队列
+		ProteinInformation(code, "", typeValue, uint(userId))
+		// 重新查询获取创建后的ID
+		if err := database.Database.Where("sequence = ?", code).Find(&mainProteinInfo).Error; err != nil {
+			return BlastResponse{Error: "查询主序列蛋白质信息失败"}
 		}
 	} else if mainProteinInfo.ID == 0 {
 		// 如果蛋白质信息不存在但在队列中，仍需创建蛋白质信息记录
-		ProteinInformation(code, "", typeValue)
+		ProteinInformation(code, "", typeValue, uint(userId))
 		// 重新查询获取创建后的ID
 		if err := database.Database.Where("sequence = ?", code).Find(&mainProteinInfo).Error; err != nil {
 			return BlastResponse{Error: "查询主序列蛋白质信息失败"}
@@ -130,37 +103,53 @@ func Blast(code, title string, typeStr string, userId int64) BlastResponse {
 		return BlastResponse{Error: "无法获取主序列蛋白质信息ID"}
 	}
 
-	// 创建主任务（先不设置 ModelId，后续收集完所有 ID 后再更新）
+	// 创建主任务，ModelId 先只设置主序列，子序列的 ModelId 将在队列处理完成后更新
 	mainTask := models.Task{
 		Title:                   title,
 		Sequence:                code,
 		Type:                    1,          // Sequence Search
 		StructurePredictionTool: &typeValue, // 设置结构预测工具类型
 		UserId:                  userId,
-		ModelId:                 "", // 暂时为空，后续更新
+		ModelId:                 strconv.FormatUint(uint64(mainProteinInfo.ID), 10),
 	}
 
 	if err := database.Database.Create(&mainTask).Error; err != nil {
 		return BlastResponse{Error: "创建主任务失败"}
 	}
 
-	// 构建子序列字符串（用于保存到 task.SubSequence 字段）
-	var processedSubSequences []string
+	// 结构域搜索阶段在后台执行，完成后添加子序列并排队预测
+	for _, stage := range blastStages {
+		setTaskStageStatus(mainTask.ID, 0, stage, JobStatusPending, "")
+	}
 
-	// 收集所有相关序列的 protein_information ID（包括主序列）
-	var allProteinIds []string
-	proteinIdSet := make(map[string]bool) // 使用map来去重
+	// 根据相关序列的队列记录设置任务状态
+	assignQueueJobs(mainTask)
+	RefreshTaskStatus(mainTask)
+	IndexTask(mainTask.ID)
+	go runBlastStages(mainTask, StageRpsblast)
 
-	// 添加主序列ID
-	mainIdStr := strconv.FormatUint(uint64(mainProteinInfo.ID), 10)
-	allProteinIds = append(allProteinIds, mainIdStr)
-	proteinIdSet[mainIdStr] = true
+	return BlastResponse{ID: mainTask.ID}
+}
+
+// addTaskDomains 为结构域提取得到的子序列创建蛋白质信息并排队预测，更新任务的子序列字段
+func addTaskDomains(task models.Task, subSequences []string, blastInformations []map[string]string) error {
+	var mainProteinInfo models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", task.Sequence).Find(&mainProteinInfo).Error; err != nil || mainProteinInfo.ID == 0 {
+		return fmt.Errorf("查询主序列蛋白质信息失败: %v", err)
+	}
+	var typeValue int64
+	if task.StructurePredictionTool != nil {
+		typeValue = *task.StructurePredictionTool
+	}
+
+	// 构建子序列字符串（用于保存到 task.SubSequence 字段）
+	var processedSubSequences []string
 
 	// 处理所有子序列，只创建蛋白质信息和队列项
 	for i := range subSequences {
 		// 获取子序列的描述信息
 		description := getDescription(blastInformations[i])
-		fasta := code[description.From-1 : description.To]
+		fasta := task.Sequence[description.From-1 : description.To]
 
 		// 将子序列添加到列表中
 		processedSubSequences = append(processedSubSequences, fasta)
@@ -171,21 +160,10 @@ func Blast(code, title string, typeStr string, userId int64) BlastResponse {
 			continue
 		}
 
-		// 查找子序列在所有队列中是否存在
-		var subQueueCount int64
-		if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("sequence = ?", fasta).Count(&subQueueCount).Error; err != nil {
+		// 已存在的子序列直接复用，不重复处理
+		if subProteinInfo.ID != 0 {
 			continue
 		}
-		if subQueueCount == 0 {
-			if err := database.Database.Model(&models.ITasserQueue{}).Where("sequence = ?", fasta).Count(&subQueueCount).Error; err != nil {
-				continue
-			}
-		}
-		if subQueueCount == 0 {
-			if err := database.Database.Model(&models.ESMQueue{}).Where("sequence = ?", fasta).Count(&subQueueCount).Error; err != nil {
-				continue
-			}
-		}
 
 		// 将描述信息转换为 JSON 字符串
 		informationJSON := ""
@@ -193,115 +171,44 @@ func Blast(code, title string, typeStr string, userId int64) BlastResponse {
 			informationJSON = string(infoJSON)
 		}
 
-		// 如果子序列既不在 protein_information 中也不在队列中，则处理
-		if subProteinInfo.ID == 0 && subQueueCount == 0 {
-			// 只有新序列才需要创建记录和添加到队列
-			if typeValue == 3 { // ESM - 同步处理
-				// 对ESM，直接创建蛋白质信息记录并同步处理
-				ProteinInformationWithParent(fasta, informationJSON, typeValue, mainProteinInfo.ID)
-			} else {
-				// 对AlphaFold和I-Tasser，先创建蛋白质信息记录
-				ProteinInformationWithParent(fasta, informationJSON, typeValue, mainProteinInfo.ID)
-			}
-			// 重新查询获取创建后的记录ID
-			if err := database.Database.Where("sequence = ?", fasta).Find(&subProteinInfo).Error; err == nil && subProteinInfo.ID > 0 {
-				idStr := strconv.FormatUint(uint64(subProteinInfo.ID), 10)
-				// 使用map去重，只有不存在的ID才添加
-				if !proteinIdSet[idStr] {
-					allProteinIds = append(allProteinIds, idStr)
-					proteinIdSet[idStr] = true
-				}
-			}
-		} else if subProteinInfo.ID == 0 {
-			// 如果蛋白质信息不存在但在队列中，仍需创建蛋白质信息记录
-			ProteinInformationWithParent(fasta, informationJSON, typeValue, mainProteinInfo.ID)
-			// 重新查询获取创建后的记录ID
-			if err := database.Database.Where("sequence = ?", fasta).Find(&subProteinInfo).Error; err == nil && subProteinInfo.ID > 0 {
-				idStr := strconv.FormatUint(uint64(subProteinInfo.ID), 10)
-				// 使用map去重，只有不存在的ID才添加
-				if !proteinIdSet[idStr] {
-					allProteinIds = append(allProteinIds, idStr)
-					proteinIdSet[idStr] = true
-				}
-			}
-		} else {
-			// 如果子序列已存在，直接添加其ID，不重复处理
-			idStr := strconv.FormatUint(uint64(subProteinInfo.ID), 10)
-			// 使用map去重，只有不存在的ID才添加
-			if !proteinIdSet[idStr] {
-				allProteinIds = append(allProteinIds, idStr)
-				proteinIdSet[idStr] = true
-			}
-		}
+		// 创建蛋白质信息记录并按预测工具添加到队列
+		ProteinInformationWithParent(fasta, informationJSON, typeValue, mainProteinInfo.ID, uint(task.UserId))
 	}
 
 	// 更新主任务的子序列字段
-	if len(processedSubSequences) > 0 {
-		subSequenceStr := strings.Join(processedSubSequences, "|")
-		if err := database.Database.Model(&mainTask).Update("sub_sequence", subSequenceStr).Error; err != nil {
-			logger.Error("更新任务子序列字段失败: %v", err)
-		}
+	task.SubSequence = strings.Join(processedSubSequences, "|")
+	if err := database.Database.Model(&models.Task{}).Where("id = ?", task.ID).Update("sub_sequence", task.SubSequence).Error; err != nil {
+		return fmt.Errorf("更新任务子序列字段失败: %v", err)
 	}
 
-	// 更新主任务的 ModelId 字段
-	// 只有 ESM 任务（typeValue == 3）才立即设置完整的 ModelId
-	if typeValue == 3 && len(allProteinIds) > 0 {
-		modelIdStr := strings.Join(allProteinIds, ",")
-		if err := database.Database.Model(&mainTask).Update("model_id", modelIdStr).Error; err != nil {
-			logger.Error("更新任务ModelId字段失败: %v", err)
-		}
-		logger.Info("ESM Task %d ModelId 设置为: %s", mainTask.ID, modelIdStr)
-	} else if len(allProteinIds) > 0 {
-		// 对于 Alpha 和 I-Tasser 任务，只设置主序列的 ModelId
-		// 子序列的 ModelId 将在队列处理完成后更新
-		modelIdStr := allProteinIds[0] // 只使用主序列的 ID
-		if err := database.Database.Model(&mainTask).Update("model_id", modelIdStr).Error; err != nil {
-			logger.Error("更新任务ModelId字段失败: %v", err)
-		}
-		logger.Info("Alpha/I-Tasser Task %d ModelId 初始设置为: %s（仅主序列）", mainTask.ID, modelIdStr)
-	}
-
-	return BlastResponse{ID: mainTask.ID}
+	assignQueueJobs(task)
+	IndexTask(task.ID)
+	return nil
 }
 
 // addSequenceToQueue 根据类型将序列添加到相应的队列（无父ID版本）
 func addSequenceToQueue(sequence string, typeValue int64) {
-	addSequenceToQueueWithParent(sequence, typeValue, nil)
+	addSequenceToQueueWithParent(sequence, typeValue, nil, 0)
 }
 
 // addSequenceToQueueWithParent 根据类型将序列添加到相应的队列，支持父ID
-func addSequenceToQueueWithParent(sequence string, typeValue int64, parentId *int64) {
-	switch typeValue {
-	case 1:
-		AddToAlphaFoldQueueWithParent(sequence, parentId)
-	case 2:
-		AddToITasserQueueWithParent(sequence, parentId)
-	case 3: // ESMFold
-		// 直接同步生成模型和图片
-		SyncESMFoldAndImage(sequence, parentId)
+func addSequenceToQueueWithParent(sequence string, typeValue int64, parentId *int64, userId uint) {
+	if tool, ok := queueToolByType(typeValue); ok {
+		tool.enqueue(sequence, parentId, userId)
 	}
 }
 
-// SyncESMFoldAndImage 同步生成ESMFold模型和Ramachandran图片
-func SyncESMFoldAndImage(sequence string, parentId *int64) {
-	// 1. 写入protein_information表（如果不存在）
-	var proteinInfo models.ProteinInformation
-	database.Database.Where("sequence = ?", sequence).Find(&proteinInfo)
-	if proteinInfo.ID == 0 {
-		proteinInfo = models.ProteinInformation{
-			Sequence: sequence,
-		}
-		if parentId != nil {
-			proteinInfo.ParentId = uint(*parentId)
+// countSequenceInQueues 统计序列在所有预测队列中的记录数
+func countSequenceInQueues(sequence string) (int64, error) {
+	var total int64
+	for _, tool := range queueTools {
+		var count int64
+		if err := tool.query().Where("sequence = ?", sequence).Count(&count).Error; err != nil {
+			return 0, err
 		}
-		database.Database.Create(&proteinInfo)
+		total += count
 	}
-
-	// 2. 调用ESMFold生成结构和模型文件
-	ESMFold(sequence)
-
-	// 3. 生成Ramachandran图片
-	Ramachandran(fmt.Sprintf("%d", proteinInfo.ID))
+	return total, nil
 }
 
 // BlastDescription BLAST 描述信息结构体
@@ -472,14 +379,21 @@ func parseAcdFile(filePath string) (string, string) {
 type BlastListItem struct {
 	Category      int64       `json:"category"`
 	CreatedAt     int64       `json:"createdAt"`
+	EtaFinish     *int64      `json:"estimatedFinish"` // 未完成任务的预计完成时间（毫秒时间戳），按历史耗时估计，无法估计时为 null
+	EtaStart      *int64      `json:"estimatedStart"`  // 预计开始时间
 	Fasta         string      `json:"fasta"`
+	FolderId      uint        `json:"folderId"`
 	HasModelCount int64       `json:"hasModelCount"`
 	ID            uint        `json:"id"`
 	Information   interface{} `json:"information"`
 	ModelId       string      `json:"modelId"`
 	ModelTotal    int64       `json:"modelTotal"`
 	ParentId      *uint       `json:"parentId"`
+	Permission    string      `json:"permission"` // 当前用户对任务的权限，自己的任务为 owner
+	SourceTaskId  uint        `json:"sourceTaskId"`
+	Status        string      `json:"status"`
 	SubSequence   *string     `json:"subSequence"`
+	Tags          []TagItem   `json:"tags"`
 	TaskType      string      `json:"taskType"`
 	Title         string      `json:"title"`
 	ToolType      string      `json:"toolType"`
@@ -493,43 +407,141 @@ type BlastListResult struct {
 	Total int64           `json:"total"`
 }
 
+// BlastListQuery 任务列表的筛选和排序条件
+type BlastListQuery struct {
+	Current     int
+	PageSize    int
+	Title       string
+	Category    string
+	CreateStart string
+	CreateEnd   string
+	TagId       uint
+	FolderId    *uint  // nil 表示不按文件夹筛选，0 表示根目录
+	TaskType    string // blast, fold, analysis, superimpose
+	Status      string // pending, running, completed, failed
+	MinLength   int
+	MaxLength   int
+	SortField   string
+	SortOrder   string // ascend, descend
+	Scope       string // own, shared，为空表示全部
+}
+
+// 任务类型字符串到 Task.Type 的映射
+var taskTypeStringToInt = map[string]int64{
+	"blast":       1,
+	"fold":        2,
+	"analysis":    3,
+	"superimpose": 4,
+}
+
+// 可排序的列，防止拼接任意字段
+var blastListSortFields = map[string]string{
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+	"title":     "title",
+	"status":    "status",
+	"type":      "type",
+	"length":    "CHAR_LENGTH(sequence)",
+}
+
 // GetBlastList 查询任务的分页列表
-func GetBlastList(userId int64, current, pageSize int, title, category, createStart, createEnd string) (BlastListResult, error) {
+func GetBlastList(userId int64, query BlastListQuery) (BlastListResult, error) {
 	var tasks []models.Task
 	var total int64
 
-	db := database.Database.Model(&models.Task{}).
-		Where("user_id = ?", userId) // 查询用户的所有任务
+	db := database.Database.Model(&models.Task{})
+	switch query.Scope {
+	case "own":
+		db = db.Where("user_id = ?", userId)
+	case "shared":
+		db = db.Where("id IN (?)", sharedTaskIds(uint(userId)))
+	default:
+		// 用户自己的任务以及他人分享给用户并已同意的任务
+		db = db.Where(database.Database.Where("user_id = ?", userId).Or("id IN (?)", sharedTaskIds(uint(userId))))
+	}
 
-	if title != "" {
-		db = db.Where("title LIKE ?", "%"+title+"%")
+	if query.Title != "" {
+		db = db.Where("title LIKE ?", "%"+query.Title+"%")
 	}
 
-	if category != "" {
-		categoryInt, _ := strconv.ParseInt(category, 10, 64)
+	if query.Category != "" {
+		categoryInt, _ := strconv.ParseInt(query.Category, 10, 64)
 		db = db.Where("structure_prediction_tool = ?", categoryInt)
 	}
-	if createStart != "" && createEnd != "" {
-		start, _ := strconv.ParseInt(createStart, 10, 64)
-		end, _ := strconv.ParseInt(createEnd, 10, 64)
+	if query.CreateStart != "" && query.CreateEnd != "" {
+		start, _ := strconv.ParseInt(query.CreateStart, 10, 64)
+		end, _ := strconv.ParseInt(query.CreateEnd, 10, 64)
 		db = db.Where("created_at BETWEEN ? AND ?", time.UnixMilli(start), time.UnixMilli(end))
-	} else if createStart != "" {
-		start, _ := strconv.ParseInt(createStart, 10, 64)
+	} else if query.CreateStart != "" {
+		start, _ := strconv.ParseInt(query.CreateStart, 10, 64)
 		db = db.Where("created_at > ?", time.UnixMilli(start))
-	} else if createEnd != "" {
-		end, _ := strconv.ParseInt(createEnd, 10, 64)
+	} else if query.CreateEnd != "" {
+		end, _ := strconv.ParseInt(query.CreateEnd, 10, 64)
 		db = db.Where("created_at < ?", time.UnixMilli(end))
 	}
 
+	if query.TagId != 0 {
+		db = db.Where("id IN (?)", database.Database.Model(&models.TaskTag{}).Select("task_id").Where("tag_id = ?", query.TagId))
+	}
+	if query.FolderId != nil {
+		if *query.FolderId == 0 {
+			db = db.Where("folder_id = 0")
+		} else {
+			// 包含所有子文件夹中的任务
+			db = db.Where("folder_id IN ?", folderDescendants(uint(userId), *query.FolderId))
+		}
+	}
+	if query.TaskType != "" {
+		typeInt, ok := taskTypeStringToInt[query.TaskType]
+		if !ok {
+			return BlastListResult{}, errors.New("Invalid task type.")
+		}
+		db = db.Where("type = ?", typeInt)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.MinLength > 0 {
+		db = db.Where("CHAR_LENGTH(sequence) >= ?", query.MinLength)
+	}
+	if query.MaxLength > 0 {
+		db = db.Where("CHAR_LENGTH(sequence) <= ?", query.MaxLength)
+	}
+
 	// 统计总数
 	db.Count(&total)
 
 	// 分页
-	db = db.Order("created_at DESC").Offset((current - 1) * pageSize).Limit(pageSize)
+	orderColumn, ok := blastListSortFields[query.SortField]
+	if !ok {
+		orderColumn = "created_at"
+	}
+	orderDirection := "DESC"
+	if query.SortOrder == "ascend" {
+		orderDirection = "ASC"
+	}
+	db = db.Order(orderColumn + " " + orderDirection).Order("id DESC").
+		Offset((query.Current - 1) * query.PageSize).Limit(query.PageSize)
 	if err := db.Find(&tasks).Error; err != nil {
 		return BlastListResult{}, err
 	}
 
+	taskIds := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		taskIds = append(taskIds, task.ID)
+	}
+	taskTags := tagsForTasks(taskIds)
+	sharePermissions := sharedTaskPermissions(uint(userId), taskIds)
+
+	// 只有列表中有未完成的任务时才估计完成时间
+	var estimates map[string]jobEstimate
+	for _, task := range tasks {
+		if task.Status == TaskStatusPending || task.Status == TaskStatusRunning {
+			estimates = sequenceEstimates()
+			break
+		}
+	}
+
 	// 组装返回
 	list := make([]BlastListItem, 0, len(tasks))
 	for _, task := range tasks {
@@ -565,18 +577,38 @@ func GetBlastList(userId int64, current, pageSize int, title, category, createSt
 			subSequence = &task.SubSequence
 		}
 
+		tags := taskTags[task.ID]
+		if tags == nil {
+			tags = []TagItem{}
+		}
+		permission := SharePermissionOwner
+		if task.UserId != userId {
+			permission = sharePermissions[task.ID]
+		}
+		var etaStart, etaFinish *int64
+		if task.Status == TaskStatusPending || task.Status == TaskStatusRunning {
+			etaStart, etaFinish = taskEstimate(task, estimates)
+		}
+
 		// 组装
 		list = append(list, BlastListItem{
 			Category:      task.Type,
 			CreatedAt:     task.CreatedAt.UnixMilli(),
+			EtaFinish:     etaFinish,
+			EtaStart:      etaStart,
 			Fasta:         task.Sequence,
+			FolderId:      task.FolderId,
 			HasModelCount: hasModelCount,
 			ID:            task.ID,
 			Information:   nil,
 			ModelId:       task.ModelId,
 			ModelTotal:    modelTotal,
 			ParentId:      nil, // 主任务无parent
+			Permission:    permission,
+			SourceTaskId:  task.SourceTaskId,
+			Status:        task.Status,
 			SubSequence:   subSequence,
+			Tags:          tags,
 			TaskType:      taskType,
 			Title:         task.Title,
 			ToolType:      "",
@@ -594,23 +626,27 @@ func GetBlastList(userId int64, current, pageSize int, title, category, createSt
 
 // BlastResultItem 表示 blast result 的单个项目
 type BlastResultItem struct {
-	ID                    uint    `json:"id"`
-	AccessibilityFraction float64 `json:"accessibilityFraction"`
-	Category              int     `json:"category"`
-	Fasta                 string  `json:"fasta"`
-	Hydrophobicity        float64 `json:"hydrophobicity"`
-	Information           *string `json:"information"`
-	Instability           float64 `json:"instability"`
-	IsoelectricPoint      float64 `json:"isoelectricPoint"`
-	ModelId               int     `json:"modelId"`
-	ParentId              *uint   `json:"parentId"`
-	RcScore               float64 `json:"rcScore"`
-	Size                  float64 `json:"size"`
-	SolventAccesibility   float64 `json:"solventAccesibility"`
-	Title                 string  `json:"title"`
-	TotalNum              int     `json:"totalNum"`
-	Type                  int     `json:"type"`
-	UserId                int64   `json:"userId"`
+	ID                    uint             `json:"id"`
+	AccessibilityFraction float64          `json:"accessibilityFraction"`
+	Category              int              `json:"category"`
+	Fasta                 string           `json:"fasta"`
+	Hydrophobicity        float64          `json:"hydrophobicity"`
+	Information           *string          `json:"information"`
+	Instability           float64          `json:"instability"`
+	IsoelectricPoint      float64          `json:"isoelectricPoint"`
+	ModelId               int              `json:"modelId"`
+	ParentId              *uint            `json:"parentId"`
+	RcScore               float64          `json:"rcScore"`
+	Size                  float64          `json:"size"`
+	SolventAccesibility   float64          `json:"solventAccesibility"`
+	Title                 string           `json:"title"`
+	TotalNum              int              `json:"totalNum"`
+	Type                  int              `json:"type"`
+	UserId                int64            `json:"userId"`
+	Annotations           []AnnotationItem `json:"annotations"`
+	JobStatus             string           `json:"jobStatus,omitempty"` // 最近一次预测的队列状态
+	JobError              string           `json:"jobError,omitempty"`
+	JobAttempts           int              `json:"jobAttempts,omitempty"`
 }
 
 // RCSBQuery RCSB PDB 查询结构体
@@ -641,8 +677,8 @@ type RCSBResponse struct {
 	TotalCount int `json:"total_count"`
 }
 
-// GetBlastResult 获取 blast 结果详情
-func GetBlastResult(idStr string) ([]BlastResultItem, error) {
+// GetBlastResult 获取 blast 结果详情，viewerId 为当前查看的用户
+func GetBlastResult(idStr string, viewerId uint) ([]BlastResultItem, error) {
 	// 将 string 类型的 ID 转换为 uint
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
@@ -654,14 +690,12 @@ func GetBlastResult(idStr string) ([]BlastResultItem, error) {
 	if err := database.Database.Where("id = ?", uint(id)).First(&mainTask).Error; err != nil {
 		return nil, fmt.Errorf("查询任务信息失败: %v", err)
 	}
+	if !UserCanAccessTask(viewerId, mainTask.ID) {
+		return nil, errors.New("Task not found.")
+	}
 
 	var proteinInfos []models.ProteinInformation
 
-	// 修复：正确解析Task.ModelId字段来查询相关的蛋白质信息
-	if mainTask.ModelId == "" {
-		return []BlastResultItem{}, nil // 如果没有ModelId，返回空结果
-	}
-
 	// 解析ModelId字段（逗号分隔的ID列表）
 	modelIdStrs := strings.Split(mainTask.ModelId, ",")
 	var proteinIds []uint
@@ -673,6 +707,9 @@ func GetBlastResult(idStr string) ([]BlastResultItem, error) {
 		}
 	}
 
+	// 预测失败或被取消的序列不会写入 ModelId，也一并返回以展示失败原因
+	proteinIds = append(proteinIds, unsuccessfulTaskProteinIds(mainTask, proteinIds)...)
+
 	if len(proteinIds) == 0 {
 		return []BlastResultItem{}, nil // 如果没有有效的蛋白质ID，返回空结果
 	}
@@ -726,9 +763,56 @@ func GetBlastResult(idStr string) ([]BlastResultItem, error) {
 		}
 	}
 
+	// 附加查看者和任务拥有者在各模型上的残基区间注释
+	annotations, err := annotationsForProteins(proteinIds, annotationAuthors(viewerId, uint(mainTask.UserId)))
+	if err != nil {
+		logger.Error("查询模型注释失败: %v", err)
+	}
+	annotationMap := make(map[uint][]AnnotationItem)
+	for _, annotation := range annotations {
+		annotationMap[annotation.ProteinId] = append(annotationMap[annotation.ProteinId], annotation)
+	}
+	for i := range result {
+		result[i].Annotations = annotationMap[result[i].ID]
+		if result[i].Annotations == nil {
+			result[i].Annotations = []AnnotationItem{}
+		}
+		// 附加最近一次预测的状态和失败原因
+		if job, ok := latestSequenceJob(result[i].Fasta); ok {
+			result[i].JobStatus = job.Status
+			result[i].JobError = job.Error
+			result[i].JobAttempts = job.Attempts
+		}
+	}
+
 	return result, nil
 }
 
+// unsuccessfulTaskProteinIds 返回任务中最近一次预测失败或被取消、且不在 exclude 中的蛋白质ID
+func unsuccessfulTaskProteinIds(task models.Task, exclude []uint) []uint {
+	excluded := make(map[uint]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+
+	var ids []uint
+	for _, sequence := range taskSequences(task) {
+		job, ok := latestSequenceJob(sequence)
+		if !ok || (job.Status != JobStatusFailed && job.Status != JobStatusCancelled) {
+			continue
+		}
+		var proteinInfo models.ProteinInformation
+		if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInfo).Error; err != nil || proteinInfo.ID == 0 {
+			continue
+		}
+		if !excluded[proteinInfo.ID] {
+			excluded[proteinInfo.ID] = true
+			ids = append(ids, proteinInfo.ID)
+		}
+	}
+	return ids
+}
+
 // createBlastResultItem 创建BlastResultItem的辅助函数
 func createBlastResultItem(proteinInfo *models.ProteinInformation, mainTask models.Task, forceType int, customTitle *string) BlastResultItem {
 	// 使用数据库中保存的结构数量，避免重复API调用
@@ -807,28 +891,37 @@ func createBlastResultItem(proteinInfo *models.ProteinInformation, mainTask mode
 
 // saveStructureNum 查询RCSB PDB数据库获取结构数量并保存到数据库
 func SaveStructureNum(proteinInfoId uint) {
+	if err := saveStructureNum(proteinInfoId, false); err != nil {
+		logger.Error("%v", err)
+	}
+}
+
+// saveStructureNum 查询并保存结构数量，force 为 false 时已有结构数量的记录直接跳过
+func saveStructureNum(proteinInfoId uint, force bool) error {
 	// 查找蛋白质信息记录
 	var proteinInfo models.ProteinInformation
 	if err := database.Database.Where("id = ?", proteinInfoId).First(&proteinInfo).Error; err != nil {
-		logger.Error("查找蛋白质信息失败: %v", err)
-		return
+		return fmt.Errorf("查找蛋白质信息失败: %v", err)
 	}
 
 	// 如果已经有结构数量信息，跳过
-	if proteinInfo.StructureNum > 0 {
+	if proteinInfo.StructureNum > 0 && !force {
 		logger.Info("蛋白质信息 %d 已有结构数量信息，跳过查询", proteinInfoId)
-		return
+		return nil
 	}
 
 	// 查询RCSB PDB数据库
-	structureNum := getStructureNum(proteinInfo.Sequence)
+	structureNum, err := fetchStructureNum(proteinInfo.Sequence)
+	if err != nil {
+		return err
+	}
 
 	// 保存到数据库
 	if err := database.Database.Model(&models.ProteinInformation{}).Where("id = ?", proteinInfoId).Update("structure_num", structureNum).Error; err != nil {
-		logger.Error("保存结构数量失败: %v", err)
-	} else {
-		logger.Info("已保存蛋白质信息 %d 的结构数量: %d", proteinInfoId, structureNum)
+		return fmt.Errorf("保存结构数量失败: %v", err)
 	}
+	logger.Info("已保存蛋白质信息 %d 的结构数量: %d", proteinInfoId, structureNum)
+	return nil
 }
 
 // BatchUpdateStructureNum 批量更新所有缺少结构数量信息的蛋白质记录
@@ -878,8 +971,18 @@ func BatchUpdateStructureNum() {
 	logger.Info("批量更新结构数量任务已启动，共处理 %d 条记录", len(proteinInfos))
 }
 
-// getStructureNum 查询 RCSB PDB 数据库获取结构数量（内部函数）
+// getStructureNum 查询 RCSB PDB 数据库获取结构数量（内部函数），失败时返回 0
 func getStructureNum(sequence string) int {
+	structureNum, err := fetchStructureNum(sequence)
+	if err != nil {
+		logger.Error("%v", err)
+		return 0
+	}
+	return structureNum
+}
+
+// fetchStructureNum 查询 RCSB PDB 数据库获取结构数量
+func fetchStructureNum(sequence string) (int, error) {
 	// 构建查询 JSON
 	query := RCSBQuery{
 		Query: RCSBQueryDetail{
@@ -901,8 +1004,7 @@ func getStructureNum(sequence string) int {
 	// 将查询转换为 JSON 字符串
 	queryJSON, err := json.Marshal(query)
 	if err != nil {
-		logger.Error("序列化 RCSB 查询失败: %v", err)
-		return 0
+		return 0, fmt.Errorf("序列化 RCSB 查询失败: %v", err)
 	}
 
 	// 构建 URL
@@ -913,19 +1015,48 @@ func getStructureNum(sequence string) int {
 	// 发起 GET 请求
 	resp, err := http.Get(fullURL)
 	if err != nil {
-		logger.Error("请求 RCSB PDB 失败: %v", err)
-		return 0
+		return 0, fmt.Errorf("请求 RCSB PDB 失败: %v", err)
 	}
 	defer resp.Body.Close()
 
+	// RCSB 没有匹配结果时返回 204
+	if resp.StatusCode == http.StatusNoContent {
+		return 0, nil
+	}
+
 	// 解析响应
 	var rcsResponse RCSBResponse
 	if err := json.NewDecoder(resp.Body).Decode(&rcsResponse); err != nil {
-		logger.Error("解析 RCSB 响应失败: %v", err)
-		return 0
+		return 0, fmt.Errorf("解析 RCSB 响应失败: %v", err)
 	}
 
-	return rcsResponse.TotalCount
+	return rcsResponse.TotalCount, nil
+}
+
+// modelIdMatch 构造匹配 ModelId（逗号分隔）中包含指定蛋白质ID的查询条件
+func modelIdMatch(proteinId uint) (string, []interface{}) {
+	idStr := strconv.FormatUint(uint64(proteinId), 10)
+	return "(model_id LIKE ? OR model_id LIKE ? OR model_id LIKE ? OR model_id = ?)",
+		[]interface{}{idStr + ",%", "%," + idStr + ",%", "%," + idStr, idStr}
+}
+
+// FindTasksByModelId 查找 ModelId 中包含指定蛋白质ID的任务
+func FindTasksByModelId(proteinId uint) ([]models.Task, error) {
+	var tasks []models.Task
+	query, args := modelIdMatch(proteinId)
+	if err := database.Database.Where(query, args...).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// FindTasksByProtein 查找 ModelId 中包含该蛋白质所属主序列的任务
+func FindTasksByProtein(proteinInfo models.ProteinInformation) ([]models.Task, error) {
+	mainProteinId := proteinInfo.ID
+	if proteinInfo.ParentId != 0 {
+		mainProteinId = proteinInfo.ParentId
+	}
+	return FindTasksByModelId(mainProteinId)
 }
 
 // UpdateTaskModelIdAfterAsyncCompletion 异步任务完成后更新主任务的ModelId
@@ -944,43 +1075,46 @@ func UpdateTaskModelIdAfterAsyncCompletion(proteinInfoId uint) {
 	}
 
 	// 查找包含该主序列ID的任务
-	var tasks []models.Task
-	mainProteinIdStr := strconv.FormatUint(uint64(mainProteinId), 10)
-
-	// 使用LIKE查询找到ModelId中包含该主序列ID的任务
-	if err := database.Database.Where("model_id LIKE ? OR model_id LIKE ? OR model_id LIKE ? OR model_id = ?",
-		mainProteinIdStr+",%", "%,"+mainProteinIdStr+",%", "%,"+mainProteinIdStr, mainProteinIdStr).Find(&tasks).Error; err != nil {
+	tasks, err := FindTasksByModelId(mainProteinId)
+	if err != nil {
 		logger.Error("查找相关任务失败: %v", err)
 		return
 	}
 
 	for _, task := range tasks {
-		// 重新收集该任务相关的所有蛋白质信息ID
-		var allProteinInfos []models.ProteinInformation
-		if err := database.Database.Where("id = ? OR parent_id = ?", mainProteinId, mainProteinId).Find(&allProteinInfos).Error; err != nil {
-			logger.Error("查找任务相关蛋白质信息失败: %v", err)
-			continue
-		}
+		runStage([]models.Task{task}, 0, StageModelId, func() error {
+			return updateTaskModelId(task, mainProteinId)
+		})
+	}
+}
 
-		// 构建新的ModelId字符串
-		var newModelIds []string
-		for _, info := range allProteinInfos {
-			newModelIds = append(newModelIds, strconv.FormatUint(uint64(info.ID), 10))
-		}
+// updateTaskModelId 按主序列及其子序列的蛋白质信息重新生成任务的ModelId
+func updateTaskModelId(task models.Task, mainProteinId uint) error {
+	// 重新收集该任务相关的所有蛋白质信息ID
+	var allProteinInfos []models.ProteinInformation
+	if err := database.Database.Where("id = ? OR parent_id = ?", mainProteinId, mainProteinId).Find(&allProteinInfos).Error; err != nil {
+		return fmt.Errorf("查找任务相关蛋白质信息失败: %v", err)
+	}
 
-		if len(newModelIds) > 0 {
-			newModelIdStr := strings.Join(newModelIds, ",")
+	// 构建新的ModelId字符串
+	var newModelIds []string
+	for _, info := range allProteinInfos {
+		newModelIds = append(newModelIds, strconv.FormatUint(uint64(info.ID), 10))
+	}
 
-			// 只有当ModelId发生变化时才更新
-			if task.ModelId != newModelIdStr {
-				if err := database.Database.Model(&models.Task{}).Where("id = ?", task.ID).Update("model_id", newModelIdStr).Error; err != nil {
-					logger.Error("更新任务ModelId失败: %v", err)
-				} else {
-					logger.Info("异步任务完成，已更新Task %d 的ModelId: %s", task.ID, newModelIdStr)
-				}
+	if len(newModelIds) > 0 {
+		newModelIdStr := strings.Join(newModelIds, ",")
+
+		// 只有当ModelId发生变化时才更新
+		if task.ModelId != newModelIdStr {
+			if err := database.Database.Model(&models.Task{}).Where("id = ?", task.ID).Update("model_id", newModelIdStr).Error; err != nil {
+				return fmt.Errorf("更新任务ModelId失败: %v", err)
 			}
+			logger.Info("异步任务完成，已更新Task %d 的ModelId: %s", task.ID, newModelIdStr)
+			PublishTaskModelsUpdated(task, newModelIdStr)
 		}
 	}
+	return nil
 }
 
 // Fold 处理 fold 请求的主要函数
@@ -999,6 +1133,11 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 	if mainSequence == "" {
 		return FoldResponse{Error: "连接后的序列为空"}
 	}
+	for _, sequence := range append([]string{mainSequence}, codes...) {
+		if err := ValidatePredictorInput(typeValue, sequence); err != nil {
+			return FoldResponse{Error: err.Error()}
+		}
+	}
 
 	// 将多个序列合并为一个主序列（用于数据库存储）
 	codesString := strings.Join(codes, "|") // 子序列用"|"分割
@@ -1011,42 +1150,22 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 	}
 
 	// 查找主序列在所有队列中是否存在
-	var mainQueueCount int64
-	if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("sequence = ?", mainSequence).Count(&mainQueueCount).Error; err != nil {
-		return FoldResponse{Error: "查询 AlphaFold 队列失败"}
-	}
-	if mainQueueCount == 0 {
-		if err := database.Database.Model(&models.ITasserQueue{}).Where("sequence = ?", mainSequence).Count(&mainQueueCount).Error; err != nil {
-			return FoldResponse{Error: "查询 I-Tasser 队列失败"}
-		}
-	}
-	if mainQueueCount == 0 {
-		if err := database.Database.Model(&models.ESMQueue{}).Where("sequence = ?", mainSequence).Count(&mainQueueCount).Error; err != nil {
-			return FoldResponse{Error: "查询 ESM 队列失败"}
-		}
+	mainQueueCount, err := countSequenceInQueues(mainSequence)
+	if err != nil {
+		return FoldResponse{Error: "查询预测队列失败"}
 	}
 
-	// 如果主序列既不在 protein_information 中也不在队列中，则添加到队列或同步处理
+	// 如果主序列既不在 protein_information 中也不在队列中，则创建记录并添加到队列
 	if mainProteinInfo.ID == 0 && mainQueueCount == 0 {
-		// 只有新序列才需要创建记录和添加到队列
-		if typeValue == 3 { // ESM - 同步处理
-			// 对ESM，先创建蛋白质信息记录
-			ProteinInformation(mainSequence, "", typeValue)
-			// 重新查询获取创建后的ID
-			if err := database.Database.Where("sequence = ?", mainSequence).Find(&mainProteinInfo).Error; err != nil {
-				return FoldResponse{Error: "查询主序列蛋白质信息失败"}
-			}
-		} else {
-			// 对AlphaFold和I-Tasser，先创建蛋白质信息记录，再添加到队列
-			ProteinInformation(mainSequence, "", typeValue)
-			// 重新查询获取创建后的ID
-			if err := database.Database.Where("sequence = ?", mainSequence).Find(&mainProteinInfo).Error; err != nil {
-				return FoldResponse{Error: "查询主序列蛋白质信息失败"}
-			}
+		// 只有新序列才需要创建记录，创建时按预测工具添加到队列
+		ProteinInformation(mainSequence, "", typeValue, uint(userId))
+		// 重新查询获取创建后的ID
+		if err := database.Database.Where("sequence = ?", mainSequence).Find(&mainProteinInfo).Error; err != nil {
+			return FoldResponse{Error: "查询主序列蛋白质信息失败"}
 		}
 	} else if mainProteinInfo.ID == 0 {
 		// 如果蛋白质信息不存在但在队列中，仍需创建蛋白质信息记录
-		ProteinInformation(mainSequence, "", typeValue)
+		ProteinInformation(mainSequence, "", typeValue, uint(userId))
 		// 重新查询获取创建后的ID
 		if err := database.Database.Where("sequence = ?", mainSequence).Find(&mainProteinInfo).Error; err != nil {
 			return FoldResponse{Error: "查询主序列蛋白质信息失败"}
@@ -1091,20 +1210,10 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 		}
 
 		// 查找子序列在所有队列中是否存在
-		var subQueueCount int64
-		if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("sequence = ?", code).Count(&subQueueCount).Error; err != nil {
+		subQueueCount, err := countSequenceInQueues(code)
+		if err != nil {
 			continue
 		}
-		if subQueueCount == 0 {
-			if err := database.Database.Model(&models.ITasserQueue{}).Where("sequence = ?", code).Count(&subQueueCount).Error; err != nil {
-				continue
-			}
-		}
-		if subQueueCount == 0 {
-			if err := database.Database.Model(&models.ESMQueue{}).Where("sequence = ?", code).Count(&subQueueCount).Error; err != nil {
-				continue
-			}
-		}
 
 		// 计算子序列在主序列中的位置
 		startPos := findSubsequencePosition(mainSequence, code)
@@ -1131,14 +1240,8 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 
 		// 如果子序列既不在 protein_information 中也不在队列中，则处理
 		if subProteinInfo.ID == 0 && subQueueCount == 0 {
-			// 只有新序列才需要创建记录和添加到队列
-			if typeValue == 3 { // ESM - 同步处理
-				// 对ESM，直接创建蛋白质信息记录并同步处理
-				ProteinInformationWithParent(code, informationJSON, typeValue, mainProteinInfo.ID)
-			} else {
-				// 对AlphaFold和I-Tasser，先创建蛋白质信息记录
-				ProteinInformationWithParent(code, informationJSON, typeValue, mainProteinInfo.ID)
-			}
+			// 只有新序列才需要创建记录，创建时按预测工具添加到队列
+			ProteinInformationWithParent(code, informationJSON, typeValue, mainProteinInfo.ID, uint(userId))
 			// 重新查询获取创建后的记录ID
 			if err := database.Database.Where("sequence = ?", code).Find(&subProteinInfo).Error; err == nil && subProteinInfo.ID > 0 {
 				idStr := strconv.FormatUint(uint64(subProteinInfo.ID), 10)
@@ -1150,7 +1253,7 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 			}
 		} else if subProteinInfo.ID == 0 {
 			// 如果蛋白质信息不存在但在队列中，仍需创建蛋白质信息记录
-			ProteinInformationWithParent(code, informationJSON, typeValue, mainProteinInfo.ID)
+			ProteinInformationWithParent(code, informationJSON, typeValue, mainProteinInfo.ID, uint(userId))
 			// 重新查询获取创建后的记录ID
 			if err := database.Database.Where("sequence = ?", code).Find(&subProteinInfo).Error; err == nil && subProteinInfo.ID > 0 {
 				idStr := strconv.FormatUint(uint64(subProteinInfo.ID), 10)
@@ -1188,16 +1291,8 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 
 	}
 
-	// 更新主任务的 ModelId 字段
-	// 只有 ESM 任务（typeValue == 3）才立即设置完整的 ModelId
-	if typeValue == 3 && len(allProteinIds) > 0 {
-		modelIdStr := strings.Join(allProteinIds, ",")
-		if err := database.Database.Model(&mainTask).Update("model_id", modelIdStr).Error; err != nil {
-			logger.Error("更新任务ModelId字段失败: %v", err)
-		}
-		logger.Info("ESM Fold Task %d ModelId 设置为: %s", mainTask.ID, modelIdStr)
-	} else if len(allProteinIds) > 0 {
-		// 对于AlphaFold和I-Tasser，先设置主序列ID，后续异步更新
+	// 更新主任务的 ModelId 字段，预测完成后异步更新
+	if len(allProteinIds) > 0 {
 		modelIdStr := strings.Join(allProteinIds, ",")
 		if err := database.Database.Model(&mainTask).Update("model_id", modelIdStr).Error; err != nil {
 			logger.Error("更新任务ModelId字段失败: %v", err)
@@ -1205,6 +1300,11 @@ func Fold(codes []string, title string, typeStr string, userId int64) FoldRespon
 		logger.Info("Fold Task %d ModelId 设置为: %s", mainTask.ID, modelIdStr)
 	}
 
+	// 根据相关序列的队列记录设置任务状态
+	assignQueueJobs(mainTask)
+	RefreshTaskStatus(mainTask)
+	IndexTask(mainTask.ID)
+
 	return FoldResponse{ID: mainTask.ID}
 }
 
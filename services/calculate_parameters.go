@@ -90,6 +90,13 @@ var HydropathyValues = map[string]float64{
 }
 
 func CalcAllWithPath(sequence string, protein_id string) (rc, sa, ii, mw, h, ip float64) {
+	rc, sa, ii, mw, h, ip = calcParametersWithPath(sequence, protein_id)
+	Ramachandran(protein_id)
+	return
+}
+
+// calcParametersWithPath 计算 static/models 中模型的参数，不生成Ramachandran图
+func calcParametersWithPath(sequence string, protein_id string) (rc, sa, ii, mw, h, ip float64) {
 	// rcScore - 使用 ./static/models 路径
 	rcPath := fmt.Sprintf("./static/models/%s.pdb", protein_id)
 	rc = CalcRcWithPath(rcPath)
@@ -103,7 +110,6 @@ func CalcAllWithPath(sequence string, protein_id string) (rc, sa, ii, mw, h, ip
 	h = CalcH(sequence)
 	// Isoelectric Point
 	ip = CalcIp(sequence)
-	Ramachandran(protein_id)
 	return
 }
 
@@ -303,7 +309,22 @@ func CalcIp(fasta string) float64 {
 }
 
 func CalculateProteinInfomationWithPath(proteinInformation models.ProteinInformation) {
-	rc, sa, ii, mw, h, ip := CalcAllWithPath(proteinInformation.Sequence, fmt.Sprintf("%d", proteinInformation.ID))
+	if err := calculateProteinParameters(&proteinInformation); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	Ramachandran(fmt.Sprintf("%d", proteinInformation.ID))
+
+	// 参数和Ramachandran图已生成，通知相关任务的拥有者
+	PublishProteinReady(proteinInformation)
+}
+
+// calculateProteinParameters 计算 static/models 中模型的参数并保存到数据库
+func calculateProteinParameters(proteinInformation *models.ProteinInformation) error {
+	if !proteinModelExists(proteinInformation.ID) {
+		return fmt.Errorf("模型文件不存在")
+	}
+	rc, sa, ii, mw, h, ip := calcParametersWithPath(proteinInformation.Sequence, fmt.Sprintf("%d", proteinInformation.ID))
 	proteinInformation.Hydrophobicity = fmt.Sprintf("%f", h)
 	proteinInformation.Instability = fmt.Sprintf("%f", ii)
 	proteinInformation.IsoelectricPoint = fmt.Sprintf("%f", ip)
@@ -317,9 +338,10 @@ func CalculateProteinInfomationWithPath(proteinInformation models.ProteinInforma
 	// Size 复用 MolecularWeight 的数据
 	proteinInformation.Size = fmt.Sprintf("%f", mw)
 	
-	if err := database.Database.Updates(&proteinInformation).Error; err != nil {
-		logger.Error("无法更新参数: %v", err)
+	if err := database.Database.Updates(proteinInformation).Error; err != nil {
+		return fmt.Errorf("无法更新参数: %v", err)
 	}
+	return nil
 }
 
 func CalculateProteinInfomatio(proteinInformation models.ProteinInformation) {
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompareRequest 比较两个任务或两个模型，二选一
+type CompareRequest struct {
+	TaskIds    []uint `json:"taskIds"`
+	ProteinIds []uint `json:"proteinIds"`
+}
+
+// CompareSide 参与比较的一方
+type CompareSide struct {
+	TaskId    uint   `json:"taskId,omitempty"`
+	ProteinId uint   `json:"proteinId"`
+	Title     string `json:"title"`
+	Sequence  string `json:"sequence"`
+	Length    int    `json:"length"`
+	HasModel  bool   `json:"hasModel"`
+}
+
+// ParameterDelta 单个理化参数的差值，Delta = B - A，无法解析的参数为 null
+type ParameterDelta struct {
+	Name  string   `json:"name"`
+	A     *float64 `json:"a"`
+	B     *float64 `json:"b"`
+	Delta *float64 `json:"delta"`
+}
+
+// DomainItem BLAST 结构域
+type DomainItem struct {
+	Accession string `json:"accession"`
+	Title     string `json:"title"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+}
+
+// DomainOverlap 按 accession 统计的结构域重叠
+type DomainOverlap struct {
+	Shared  []string     `json:"shared"`
+	OnlyA   []string     `json:"onlyA"`
+	OnlyB   []string     `json:"onlyB"`
+	DomainA []DomainItem `json:"domainsA"`
+	DomainB []DomainItem `json:"domainsB"`
+	Jaccard float64      `json:"jaccard"`
+}
+
+// CompareResult 比较结果，比对或 RMSD 无法计算时返回对应的错误说明
+type CompareResult struct {
+	A              CompareSide        `json:"a"`
+	B              CompareSide        `json:"b"`
+	Alignment      *SequenceAlignment `json:"alignment"`
+	AlignmentError string             `json:"alignmentError,omitempty"`
+	Parameters     []ParameterDelta   `json:"parameters"`
+	Domains        DomainOverlap      `json:"domains"`
+	Structure      *StructureRMSD     `json:"structure"`
+	StructureError string             `json:"structureError,omitempty"`
+}
+
+// compareSubject 比较一方对应的主蛋白质和结构域来源
+type compareSubject struct {
+	side        CompareSide
+	protein     models.ProteinInformation
+	domainSeeds []models.ProteinInformation
+}
+
+// Compare 比较两个任务或两个模型的序列、理化参数、结构域和结构
+func Compare(userId uint, req CompareRequest) (CompareResult, error) {
+	var subjects [2]compareSubject
+	switch {
+	case len(req.TaskIds) == 2 && len(req.ProteinIds) == 0:
+		for i, taskId := range req.TaskIds {
+			subject, err := compareSubjectFromTask(userId, taskId)
+			if err != nil {
+				return CompareResult{}, err
+			}
+			subjects[i] = subject
+		}
+	case len(req.ProteinIds) == 2 && len(req.TaskIds) == 0:
+		for i, proteinId := range req.ProteinIds {
+			subject, err := compareSubjectFromProtein(userId, proteinId)
+			if err != nil {
+				return CompareResult{}, err
+			}
+			subjects[i] = subject
+		}
+	default:
+		return CompareResult{}, errors.New("Provide exactly two taskIds or two proteinIds.")
+	}
+
+	a, b := subjects[0], subjects[1]
+	result := CompareResult{
+		A:          a.side,
+		B:          b.side,
+		Parameters: compareParameters(a.protein, b.protein),
+		Domains:    compareDomains(a.domainSeeds, b.domainSeeds),
+	}
+
+	if alignment, err := AlignSequences(a.protein.Sequence, b.protein.Sequence); err != nil {
+		result.AlignmentError = err.Error()
+	} else {
+		result.Alignment = &alignment
+	}
+
+	if !a.side.HasModel || !b.side.HasModel {
+		result.StructureError = "Model not available."
+	} else if rmsd, err := CalculateCARMSD(
+		fmt.Sprintf("static/models/%d.pdb", a.protein.ID),
+		fmt.Sprintf("static/models/%d.pdb", b.protein.ID),
+	); err != nil {
+		result.StructureError = err.Error()
+	} else {
+		result.Structure = &rmsd
+	}
+
+	return result, nil
+}
+
+// compareSubjectFromTask 取任务的主序列作为比较对象，结构域来自任务的子序列
+func compareSubjectFromTask(userId uint, taskId uint) (compareSubject, error) {
+	var task models.Task
+	if err := database.Database.Where("id = ?", taskId).First(&task).Error; err != nil || !UserCanAccessTask(userId, taskId) {
+		return compareSubject{}, errors.New("Task not found.")
+	}
+
+	var protein models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", task.Sequence).First(&protein).Error; err != nil {
+		return compareSubject{}, errors.New("Model not found.")
+	}
+
+	var seeds []models.ProteinInformation
+	if sequences := taskSequences(task)[1:]; len(sequences) > 0 {
+		database.Database.Where("sequence IN ?", sequences).Find(&seeds)
+	}
+
+	return compareSubject{
+		side:        newCompareSide(task.ID, task.Title, protein),
+		protein:     protein,
+		domainSeeds: seeds,
+	}, nil
+}
+
+// compareSubjectFromProtein 以模型本身作为比较对象，主序列模型的结构域来自其子序列
+func compareSubjectFromProtein(userId uint, proteinId uint) (compareSubject, error) {
+	var protein models.ProteinInformation
+	if err := database.Database.Where("id = ?", proteinId).First(&protein).Error; err != nil || !UserCanAccessProtein(userId, proteinId) {
+		return compareSubject{}, errors.New("Model not found.")
+	}
+
+	seeds := []models.ProteinInformation{protein}
+	if protein.ParentId == 0 {
+		database.Database.Where("parent_id = ?", protein.ID).Find(&seeds)
+	}
+
+	return compareSubject{
+		side:        newCompareSide(0, fmt.Sprintf("Model %d", protein.ID), protein),
+		protein:     protein,
+		domainSeeds: seeds,
+	}, nil
+}
+
+func newCompareSide(taskId uint, title string, protein models.ProteinInformation) CompareSide {
+	return CompareSide{
+		TaskId:    taskId,
+		ProteinId: protein.ID,
+		Title:     title,
+		Sequence:  protein.Sequence,
+		Length:    len(protein.Sequence),
+		HasModel:  proteinModelExists(protein.ID),
+	}
+}
+
+// compareParameters 计算各理化参数的差值
+func compareParameters(a, b models.ProteinInformation) []ParameterDelta {
+	fields := []struct {
+		name   string
+		valueA string
+		valueB string
+	}{
+		{"rcScore", a.RcScore, b.RcScore},
+		{"solventAccesibility", a.SolventAccesibility, b.SolventAccesibility},
+		{"instability", a.Instability, b.Instability},
+		{"isoelectricPoint", a.IsoelectricPoint, b.IsoelectricPoint},
+		{"hydrophobicity", a.Hydrophobicity, b.Hydrophobicity},
+		{"size", a.Size, b.Size},
+		{"molecularWeight", a.MolecularWeight, b.MolecularWeight},
+	}
+
+	result := make([]ParameterDelta, 0, len(fields))
+	for _, field := range fields {
+		item := ParameterDelta{Name: field.name}
+		if value, err := strconv.ParseFloat(strings.TrimSpace(field.valueA), 64); err == nil {
+			item.A = &value
+		}
+		if value, err := strconv.ParseFloat(strings.TrimSpace(field.valueB), 64); err == nil {
+			item.B = &value
+		}
+		if item.A != nil && item.B != nil {
+			delta := *item.B - *item.A
+			item.Delta = &delta
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// compareDomains 按 accession 比较两组结构域
+func compareDomains(seedsA, seedsB []models.ProteinInformation) DomainOverlap {
+	domainsA, domainsB := domainsFromProteins(seedsA), domainsFromProteins(seedsB)
+
+	setA, setB := make(map[string]bool), make(map[string]bool)
+	for _, domain := range domainsA {
+		setA[domain.Accession] = true
+	}
+	for _, domain := range domainsB {
+		setB[domain.Accession] = true
+	}
+
+	overlap := DomainOverlap{
+		Shared:  []string{},
+		OnlyA:   []string{},
+		OnlyB:   []string{},
+		DomainA: domainsA,
+		DomainB: domainsB,
+	}
+	for accession := range setA {
+		if setB[accession] {
+			overlap.Shared = append(overlap.Shared, accession)
+		} else {
+			overlap.OnlyA = append(overlap.OnlyA, accession)
+		}
+	}
+	for accession := range setB {
+		if !setA[accession] {
+			overlap.OnlyB = append(overlap.OnlyB, accession)
+		}
+	}
+	sort.Strings(overlap.Shared)
+	sort.Strings(overlap.OnlyA)
+	sort.Strings(overlap.OnlyB)
+
+	if union := len(overlap.Shared) + len(overlap.OnlyA) + len(overlap.OnlyB); union > 0 {
+		overlap.Jaccard = float64(len(overlap.Shared)) / float64(union)
+	}
+	return overlap
+}
+
+// domainsFromProteins 从蛋白质记录保存的 BlastInformation 中解析结构域
+func domainsFromProteins(proteinInfos []models.ProteinInformation) []DomainItem {
+	domains := []DomainItem{}
+	for _, domain := range proteinDomainDescriptions(proteinInfos) {
+		domains = append(domains, DomainItem{
+			Accession: domain.Accession,
+			Title:     domain.Title,
+			From:      domain.From,
+			To:        domain.To,
+		})
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i].From < domains[j].From
+	})
+	return domains
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// esmFoldPredictor 调用 ESMFold API
+type esmFoldPredictor struct {
+	workers int
+}
+
+func newESMFoldPredictor() *esmFoldPredictor {
+	return &esmFoldPredictor{
+		workers: workerCount("ESM_WORKERS", 2),
+	}
+}
+
+func (p *esmFoldPredictor) Info() PredictorInfo {
+	return PredictorInfo{Key: "esm", Name: "ESMFold", Type: 3, Kind: "remote", Workers: p.workers, Version: predictorVersion("ESM")}
+}
+
+func (p *esmFoldPredictor) Validate(sequence string) error {
+	return validateSequence(sequence, 0)
+}
+
+// Run 任务被取消时中断 API 调用
+func (p *esmFoldPredictor) Run(job PredictionJob) error {
+	pdb, err := ESMFold(job.Context(), job.Sequence)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(job.Workspace, "model.pdb"), pdb, 0644); err != nil {
+		return fmt.Errorf("保存PDB文件失败: %v", err)
+	}
+	return nil
+}
+
+func (p *esmFoldPredictor) Collect(job PredictionJob) (string, error) {
+	return existingFile(filepath.Join(job.Workspace, "model.pdb"))
+}
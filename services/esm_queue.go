@@ -8,11 +8,11 @@ import (
 
 // Add To ESM Queue
 func AddToESMQueue(sequence string) {
-	AddToESMQueueWithParent(sequence, nil)
+	AddToESMQueueWithParent(sequence, nil, 0)
 }
 
 // Add To ESM Queue with parent ID
-func AddToESMQueueWithParent(sequence string, parentId *int64) {
+func AddToESMQueueWithParent(sequence string, parentId *int64, userId uint) {
 	var esmQueue models.ESMQueue
 	if err := database.Database.Where("sequence = ?", sequence).Find(&esmQueue).Error; err != nil {
 		return
@@ -20,8 +20,12 @@ func AddToESMQueueWithParent(sequence string, parentId *int64) {
 	if esmQueue.ID == 0 {
 		esmQueue.Sequence = sequence
 		esmQueue.ParentId = parentId
+		esmQueue.QueueJob = newQueueJob(userId)
 		if err := database.Database.Create(&esmQueue).Error; err != nil {
 			logger.Error("创建ESM队列失败: %v", err)
+		} else {
+			logger.Info("已添加序列到ESM队列，ID: %d", esmQueue.ID)
+			WakeQueueScheduler()
 		}
 	}
 } 
\ No newline at end of file
@@ -1,71 +1,46 @@
 package services
 
 import (
-	"Protein_Server/database"
 	"Protein_Server/logger"
-	"Protein_Server/models"
+	"context"
 	"crypto/tls"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
-// ESMFold
-func ESMFold(sequence string) {
-	// 记录开始时间
-	startTime := time.Now()
-	logger.Info("ESMFold任务开始处理，序列长度: %d", len(sequence))
-	
-	var proteinInformation models.ProteinInformation
-	// get sequence id
-	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInformation).Error; err != nil {
-		logger.Error("查找蛋白质信息失败: %v", err)
-		return
-	}
-	
-	// 确保输出目录存在
-	modelsDir := filepath.Join("static", "models")
-	if err := os.MkdirAll(modelsDir, 0755); err != nil {
-		logger.Error("创建模型目录失败: %v", err)
-		return
-	}
-	
+// ESMFold API 单次请求的超时时间
+var esmRequestTimeout = 5 * time.Minute
+
+// ESMFold 调用 ESMFold API 预测结构，返回 PDB 文件内容
+// 网络错误、限流和服务端错误包装为可重试错误
+// ctx 被取消时中断请求
+func ESMFold(ctx context.Context, sequence string) ([]byte, error) {
 	// ESMFold's API requires skipping SSL authentication
 	// SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
 	// resty.New() can get an object
-	logger.Info("开始调用ESMFold API...")
-	client := resty.New().SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	logger.Info("开始调用ESMFold API，序列长度: %d", len(sequence))
+	client := resty.New().
+		SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true}).
+		SetTimeout(esmRequestTimeout)
 	// Use R() then can use POST GET ...
 	// ESMFold API: https://api.esmatlas.com/foldSequence/v1/pdb/
-	resp, err := client.R().SetBody(sequence).Post("https://api.esmatlas.com/foldSequence/v1/pdb/")
+	resp, err := client.R().SetContext(ctx).SetBody(sequence).Post("https://api.esmatlas.com/foldSequence/v1/pdb/")
+	if ctx.Err() != nil {
+		return nil, errJobCancelled
+	}
 	if err != nil {
-		logger.Error("请求ESMFold失败: %v", err)
-		return
+		return nil, fmt.Errorf("%w: 请求ESMFold失败: %v", errJobTransient, err)
 	}
-	
-	// Save pdb files in static/models fold
-	// PDB file's name should be id.pdb
-	filename := filepath.Join("static/models", fmt.Sprintf("%d.pdb", proteinInformation.ID))
-	if err := os.WriteFile(filename, resp.Body(), 0644); err != nil {
-		logger.Error("保存PDB文件失败: %v", err)
-		return
+	if code := resp.StatusCode(); code == 429 || code >= 500 {
+		return nil, fmt.Errorf("%w: ESMFold返回状态码 %d", errJobTransient, code)
+	} else if code != 200 {
+		return nil, fmt.Errorf("ESMFold返回状态码 %d: %s", code, strings.TrimSpace(string(resp.Body())))
 	}
-
-	// 计算处理时间并保存到数据库
-	duration := time.Since(startTime)
-	durationSeconds := duration.Seconds()
-	if err := database.Database.Model(&models.ProteinInformation{}).Where("id = ?", proteinInformation.ID).Update("duration", durationSeconds).Error; err != nil {
-		logger.Error("保存处理时间失败: %v", err)
-	} else {
-		logger.Info("ESMFold任务执行完成，耗时: %.2f秒", durationSeconds)
+	if !strings.Contains(string(resp.Body()), "ATOM") {
+		return nil, fmt.Errorf("ESMFold返回的内容不是PDB格式")
 	}
-
-	// Calculate parameters
-	CalculateProteinInfomationWithPath(proteinInformation)
-	
-	// 保存RCSB PDB结构数量到数据库
-	SaveStructureNum(proteinInformation.ID)
+	return resp.Body(), nil
 }
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	EventQueueStatus       = "queue_status"        // 队列记录状态变化
+	EventProteinReady      = "protein_ready"       // 蛋白质参数和Ramachandran图已生成
+	EventTaskModelsUpdated = "task_models_updated" // 任务的模型列表增加
+)
+
+// Event 内部事件
+type Event struct {
+	Type   string      `json:"type"`
+	UserId uint        `json:"userId"`
+	TaskId uint        `json:"taskId"`
+	Data   interface{} `json:"data"`
+	Time   int64       `json:"time"`
+}
+
+// EventBus 按用户分发事件的内部事件总线
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan Event]struct{}
+}
+
+var globalEventBus = NewEventBus()
+
+// GetEventBus 获取全局事件总线
+func GetEventBus() *EventBus {
+	return globalEventBus
+}
+
+// NewEventBus 创建事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[uint]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe 订阅指定用户的事件
+func (b *EventBus) Subscribe(userId uint) chan Event {
+	ch := make(chan Event, 32)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[userId] == nil {
+		b.subscribers[userId] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userId][ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe 取消订阅
+func (b *EventBus) Unsubscribe(userId uint, ch chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if subs, ok := b.subscribers[userId]; ok {
+		delete(subs, ch)
+		if len(subs) == 0 {
+			delete(b.subscribers, userId)
+		}
+	}
+}
+
+// Publish 发布事件，订阅者处理不过来时丢弃事件，不阻塞发布方
+func (b *EventBus) Publish(event Event) {
+	if event.Time == 0 {
+		event.Time = time.Now().UnixMilli()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[event.UserId] {
+		select {
+		case ch <- event:
+		default:
+			logger.Warn("用户 %d 的事件通道已满，丢弃事件: %s", event.UserId, event.Type)
+		}
+	}
+}
+
+// publishToTaskViewers 向可以查看任务的所有用户（拥有者和已同意分享的用户）发布事件
+func publishToTaskViewers(task models.Task, eventType string, data map[string]interface{}) {
+	for _, userId := range taskViewerIds(task) {
+		globalEventBus.Publish(Event{
+			Type:   eventType,
+			UserId: userId,
+			TaskId: task.ID,
+			Data:   data,
+		})
+	}
+}
+
+// publishToProteinViewers 向可以查看 ModelId 中包含该蛋白质（或其主序列）的任务的所有用户发布事件
+func publishToProteinViewers(proteinInfo models.ProteinInformation, eventType string, data map[string]interface{}) {
+	tasks, err := FindTasksByProtein(proteinInfo)
+	if err != nil {
+		logger.Error("查找事件相关任务失败: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		publishToTaskViewers(task, eventType, data)
+	}
+}
+
+// PublishQueueStatus 发布队列记录状态变化事件
+func PublishQueueStatus(tool string, queueId uint, sequence string, status string) {
+	var proteinInfo models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInfo).Error; err != nil || proteinInfo.ID == 0 {
+		return
+	}
+	publishToProteinViewers(proteinInfo, EventQueueStatus, map[string]interface{}{
+		"tool":      tool,
+		"queueId":   queueId,
+		"proteinId": proteinInfo.ID,
+		"status":    status,
+	})
+}
+
+// PublishProteinReady 发布蛋白质参数和Ramachandran图已生成事件
+func PublishProteinReady(proteinInfo models.ProteinInformation) {
+	publishToProteinViewers(proteinInfo, EventProteinReady, map[string]interface{}{
+		"proteinId": proteinInfo.ID,
+		"image":     "/imgs/" + proteinInfo.PdbId + ".png",
+		"model":     "/models/" + proteinInfo.PdbId + ".pdb",
+	})
+}
+
+// PublishTaskModelsUpdated 发布任务模型列表更新事件
+func PublishTaskModelsUpdated(task models.Task, modelId string) {
+	publishToTaskViewers(task, EventTaskModelsUpdated, map[string]interface{}{
+		"modelId":    modelId,
+		"modelCount": len(strings.Split(modelId, ",")),
+	})
+}
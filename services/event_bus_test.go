@@ -0,0 +1,60 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"fmt"
+	"testing"
+)
+
+func TestPublishToTaskViewers(t *testing.T) {
+	db := openTestDatabase(t)
+
+	proteinInfo := models.ProteinInformation{Sequence: "MKTAYIAKQR"}
+	if err := db.Create(&proteinInfo).Error; err != nil {
+		t.Fatal(err)
+	}
+	task := models.Task{Title: "fold", Sequence: proteinInfo.Sequence, Type: 2, UserId: 1, ModelId: fmt.Sprint(proteinInfo.ID)}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	shares := []models.Share{
+		{TaskId: task.ID, FromId: 1, ToId: 2, Status: ShareStatusAgreed, Permission: SharePermissionView},
+		{TaskId: task.ID, FromId: 1, ToId: 3, Status: 0, Permission: SharePermissionView}, // 尚未同意
+		{TaskId: task.ID, FromId: 1, ToId: 4, Status: 2, Permission: SharePermissionView}, // 已拒绝
+	}
+	for i := range shares {
+		if err := db.Create(&shares[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bus := GetEventBus()
+	subscriptions := make(map[uint]chan Event)
+	for userId := uint(1); userId <= 5; userId++ {
+		subscriptions[userId] = bus.Subscribe(userId)
+		defer bus.Unsubscribe(userId, subscriptions[userId])
+	}
+
+	PublishQueueStatus("Fake", 1, proteinInfo.Sequence, JobStatusRunning)
+	PublishProteinReady(proteinInfo)
+	PublishTaskModelsUpdated(task, task.ModelId)
+
+	// 拥有者和已同意分享的用户收到每个事件一次
+	want := map[uint]bool{1: true, 2: true}
+	for userId, ch := range subscriptions {
+		var types []string
+		for len(ch) > 0 {
+			event := <-ch
+			if event.TaskId != task.ID {
+				t.Errorf("user %d: event for task %d", userId, event.TaskId)
+			}
+			types = append(types, event.Type)
+		}
+		if want[userId] && fmt.Sprint(types) != fmt.Sprint([]string{EventQueueStatus, EventProteinReady, EventTaskModelsUpdated}) {
+			t.Errorf("user %d received %v", userId, types)
+		}
+		if !want[userId] && len(types) > 0 {
+			t.Errorf("user %d should not receive %v", userId, types)
+		}
+	}
+}
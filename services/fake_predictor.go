@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fakePredictor 测试用的预测工具，不调用任何外部程序，按序列生成确定的 PDB 文件
+// 设置 FAKE_PREDICTOR=true 时注册，用于端到端测试队列、后处理和任务状态
+type fakePredictor struct {
+	template string        // 模板 PDB 文件，为空时生成理想 α 螺旋的主链
+	delay    time.Duration // 模拟预测耗时
+	workers  int
+}
+
+// newFakePredictorFromEnv 读取 FAKE_PREDICTOR、FAKE_PREDICTOR_TEMPLATE、FAKE_PREDICTOR_DELAY_SECONDS、FAKE_PREDICTOR_WORKERS
+func newFakePredictorFromEnv() (*fakePredictor, bool) {
+	if enabled, err := strconv.ParseBool(os.Getenv("FAKE_PREDICTOR")); err != nil || !enabled {
+		return nil, false
+	}
+	predictor := &fakePredictor{
+		template: os.Getenv("FAKE_PREDICTOR_TEMPLATE"),
+		workers:  workerCount("FAKE_PREDICTOR_WORKERS", 2),
+	}
+	if value, ok := envInt("FAKE_PREDICTOR_DELAY_SECONDS"); ok {
+		predictor.delay = time.Duration(value) * time.Second
+	}
+	return predictor, true
+}
+
+func (p *fakePredictor) Info() PredictorInfo {
+	return PredictorInfo{Key: "fake", Name: "Fake", Type: 99, Kind: "fake", Workers: p.workers}
+}
+
+func (p *fakePredictor) Validate(sequence string) error {
+	return validateSequence(sequence, 0)
+}
+
+func (p *fakePredictor) Run(job PredictionJob) error {
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-job.Context().Done():
+			return errJobCancelled
+		}
+	}
+
+	pdb := []byte(fakeHelixPDB(job.Sequence))
+	if p.template != "" {
+		content, err := os.ReadFile(p.template)
+		if err != nil {
+			return fmt.Errorf("读取模板PDB失败: %v", err)
+		}
+		pdb = content
+	}
+	return os.WriteFile(filepath.Join(job.Workspace, "model.pdb"), pdb, 0644)
+}
+
+func (p *fakePredictor) Collect(job PredictionJob) (string, error) {
+	return existingFile(filepath.Join(job.Workspace, "model.pdb"))
+}
+
+// threeLetterCodes 氨基酸单字母到三字母代码
+var threeLetterCodes = map[rune]string{
+	'A': "ALA", 'R': "ARG", 'N': "ASN", 'D': "ASP", 'C': "CYS",
+	'Q': "GLN", 'E': "GLU", 'G': "GLY", 'H': "HIS", 'I': "ILE",
+	'L': "LEU", 'K': "LYS", 'M': "MET", 'F': "PHE", 'P': "PRO",
+	'S': "SER", 'T': "THR", 'W': "TRP", 'Y': "TYR", 'V': "VAL",
+}
+
+type vec3 [3]float64
+
+func (a vec3) add(b vec3) vec3      { return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]} }
+func (a vec3) sub(b vec3) vec3      { return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]} }
+func (a vec3) scale(k float64) vec3 { return vec3{a[0] * k, a[1] * k, a[2] * k} }
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+func (a vec3) unit() vec3           { return a.scale(1 / math.Sqrt(a[0]*a[0]+a[1]*a[1]+a[2]*a[2])) }
+func degrees(value float64) float64 { return value * math.Pi / 180 }
+
+// placeAtom 按键长、键角 b-c-d 和二面角 a-b-c-d 放置原子 d（NeRF 算法）
+func placeAtom(a, b, c vec3, bond, angle, torsion float64) vec3 {
+	bc := c.sub(b).unit()
+	n := b.sub(a).cross(bc).unit()
+	m := n.cross(bc)
+	return c.add(bc.scale(-bond * math.Cos(angle))).
+		add(m.scale(bond * math.Sin(angle) * math.Cos(torsion))).
+		add(n.scale(bond * math.Sin(angle) * math.Sin(torsion)))
+}
+
+// fakeHelixPDB 按序列生成理想 α 螺旋（phi -57°，psi -47°）的主链原子 N、CA、C、O
+func fakeHelixPDB(sequence string) string {
+	phi, psi, omega := degrees(-57), degrees(-47), degrees(180)
+
+	var builder strings.Builder
+	builder.WriteString("REMARK   1 GENERATED BY FAKE PREDICTOR\n")
+
+	n := vec3{0, 0, 0}
+	ca := vec3{1.458, 0, 0}
+	c := ca.add(vec3{-math.Cos(degrees(111.2)), math.Sin(degrees(111.2)), 0}.scale(1.525))
+	serial := 1
+	for i, residue := range strings.ToUpper(sequence) {
+		if i > 0 {
+			nextN := placeAtom(n, ca, c, 1.329, degrees(116.2), psi)
+			nextCA := placeAtom(ca, c, nextN, 1.458, degrees(121.7), omega)
+			nextC := placeAtom(c, nextN, nextCA, 1.525, degrees(111.2), phi)
+			n, ca, c = nextN, nextCA, nextC
+		}
+		o := placeAtom(n, ca, c, 1.231, degrees(120.5), psi+math.Pi)
+
+		name := threeLetterCodes[residue]
+		for _, atom := range []struct {
+			name string
+			pos  vec3
+		}{{"N", n}, {"CA", ca}, {"C", c}, {"O", o}} {
+			fmt.Fprintf(&builder, "ATOM  %5d  %-3s %3s A%4d    %8.3f%8.3f%8.3f  1.00  0.00          %2s\n",
+				serial, atom.name, name, i+1, atom.pos[0], atom.pos[1], atom.pos[2], atom.name[:1])
+			serial++
+		}
+	}
+	builder.WriteString("TER\nEND\n")
+	return builder.String()
+}
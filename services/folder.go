@@ -0,0 +1,167 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// FolderItem 文件夹，ParentId 为 0 表示根目录
+type FolderItem struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	ParentId  uint   `json:"parentId"`
+	TaskCount int64  `json:"taskCount"`
+}
+
+// ListFolders 查询用户的所有文件夹，前端根据 parentId 组装树
+func ListFolders(userId uint) ([]FolderItem, error) {
+	var folders []models.Folder
+	if err := database.Database.Where("user_id = ?", userId).Order("name").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	type folderCount struct {
+		FolderId uint
+		Count    int64
+	}
+	var counts []folderCount
+	database.Database.Model(&models.Task{}).
+		Select("folder_id, COUNT(*) AS count").
+		Where("user_id = ? AND folder_id > 0", userId).
+		Group("folder_id").
+		Scan(&counts)
+	countMap := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		countMap[c.FolderId] = c.Count
+	}
+
+	result := make([]FolderItem, 0, len(folders))
+	for _, folder := range folders {
+		result = append(result, FolderItem{
+			ID:        folder.ID,
+			Name:      folder.Name,
+			ParentId:  folder.ParentId,
+			TaskCount: countMap[folder.ID],
+		})
+	}
+	return result, nil
+}
+
+// CreateFolder 在指定父文件夹下创建文件夹
+func CreateFolder(userId uint, name string, parentId uint) (FolderItem, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 255 {
+		return FolderItem{}, errors.New("Invalid folder name.")
+	}
+	if parentId != 0 && !userOwnsFolder(userId, parentId) {
+		return FolderItem{}, errors.New("Parent folder not found.")
+	}
+
+	folder := models.Folder{UserId: userId, Name: name, ParentId: parentId}
+	if err := database.Database.Create(&folder).Error; err != nil {
+		return FolderItem{}, errors.New("Network error.")
+	}
+	return FolderItem{ID: folder.ID, Name: folder.Name, ParentId: folder.ParentId}, nil
+}
+
+// UpdateFolder 重命名或移动文件夹，不能移动到自身或其子文件夹下
+func UpdateFolder(userId uint, folderId uint, name string, parentId uint) (FolderItem, error) {
+	var folder models.Folder
+	if err := database.Database.Where("id = ? AND user_id = ?", folderId, userId).First(&folder).Error; err != nil {
+		return FolderItem{}, errors.New("Folder not found.")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 255 {
+		return FolderItem{}, errors.New("Invalid folder name.")
+	}
+	if parentId != 0 {
+		if !userOwnsFolder(userId, parentId) {
+			return FolderItem{}, errors.New("Parent folder not found.")
+		}
+		for _, id := range folderDescendants(userId, folderId) {
+			if id == parentId {
+				return FolderItem{}, errors.New("Cannot move a folder into itself.")
+			}
+		}
+	}
+
+	folder.Name = name
+	folder.ParentId = parentId
+	if err := database.Database.Save(&folder).Error; err != nil {
+		return FolderItem{}, errors.New("Network error.")
+	}
+	return FolderItem{ID: folder.ID, Name: folder.Name, ParentId: folder.ParentId}, nil
+}
+
+// DeleteFolder 删除文件夹，其中的子文件夹和任务移动到上一级
+func DeleteFolder(userId uint, folderId uint) error {
+	var folder models.Folder
+	if err := database.Database.Where("id = ? AND user_id = ?", folderId, userId).First(&folder).Error; err != nil {
+		return errors.New("Folder not found.")
+	}
+
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Folder{}).Where("user_id = ? AND parent_id = ?", userId, folderId).Update("parent_id", folder.ParentId).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		if err := tx.Model(&models.Task{}).Where("user_id = ? AND folder_id = ?", userId, folderId).Update("folder_id", folder.ParentId).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		if err := tx.Delete(&folder).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		return nil
+	})
+}
+
+// MoveTasks 批量移动任务到文件夹，folderId 为 0 表示移到根目录
+func MoveTasks(userId uint, taskIds []uint, folderId uint) error {
+	if folderId != 0 && !userOwnsFolder(userId, folderId) {
+		return errors.New("Folder not found.")
+	}
+	result := database.Database.Model(&models.Task{}).Where("id IN ? AND user_id = ?", taskIds, userId).Update("folder_id", folderId)
+	if result.Error != nil {
+		return errors.New("Network error.")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Task not found.")
+	}
+	return nil
+}
+
+// userOwnsFolder 判断文件夹是否属于该用户
+func userOwnsFolder(userId uint, folderId uint) bool {
+	var count int64
+	if err := database.Database.Model(&models.Folder{}).Where("id = ? AND user_id = ?", folderId, userId).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// folderDescendants 返回文件夹本身及其所有子孙文件夹的ID
+func folderDescendants(userId uint, folderId uint) []uint {
+	var folders []models.Folder
+	database.Database.Select("id", "parent_id").Where("user_id = ?", userId).Find(&folders)
+
+	children := make(map[uint][]uint)
+	for _, folder := range folders {
+		children[folder.ParentId] = append(children[folder.ParentId], folder.ID)
+	}
+
+	result := []uint{folderId}
+	visited := map[uint]bool{folderId: true}
+	for i := 0; i < len(result); i++ {
+		for _, child := range children[result[i]] {
+			if !visited[child] {
+				visited[child] = true
+				result = append(result, child)
+			}
+		}
+	}
+	return result
+}
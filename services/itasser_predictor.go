@@ -0,0 +1,50 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"fmt"
+	"path/filepath"
+)
+
+// itasserPredictor 在本机运行 I-TASSER
+type itasserPredictor struct {
+	limits  predictorLimits
+	workers int
+}
+
+func newItasserPredictor() *itasserPredictor {
+	return &itasserPredictor{
+		limits:  itasserLimits,
+		workers: workerCount("ITASSER_WORKERS", 1),
+	}
+}
+
+func (p *itasserPredictor) Info() PredictorInfo {
+	return PredictorInfo{Key: "itasser", Name: "I-TASSER", Type: 2, Kind: "local", Workers: p.workers, Version: predictorVersion("ITASSER")}
+}
+
+func (p *itasserPredictor) Validate(sequence string) error {
+	return validateSequence(sequence, 0)
+}
+
+func (p *itasserPredictor) Run(job PredictionJob) error {
+	// I-TASSER 从 -datadir 中读取固定名称的 seq.fasta
+	if err := writeFastaFile(filepath.Join(job.Workspace, "seq.fasta"), "seq", job.Sequence); err != nil {
+		return fmt.Errorf("创建FASTA文件失败: %v", err)
+	}
+
+	// Run the I-Tasser command
+	logger.Info("开始执行I-Tasser命令，工作目录: %s", job.Workspace)
+	cmd := p.limits.command("../I-TASSER5.1/I-TASSERmod/runI-TASSER.pl",
+		"-libdir", "../I-TASSER5.1/lib",
+		"-seqname", "itasser_example",
+		"-datadir", job.Workspace,
+		"-light", "true",
+		"-nmodel", "1",
+		"-hours", "2")
+	return job.RunCommand(cmd, p.limits)
+}
+
+func (p *itasserPredictor) Collect(job PredictionJob) (string, error) {
+	return existingFile(filepath.Join(job.Workspace, "model1.pdb"))
+}
@@ -8,11 +8,11 @@ import (
 
 // Add To ITasser Queue
 func AddToITasserQueue(sequence string) {
-	AddToITasserQueueWithParent(sequence, nil)
+	AddToITasserQueueWithParent(sequence, nil, 0)
 }
 
 // Add To ITasser Queue with parent ID
-func AddToITasserQueueWithParent(sequence string, parentId *int64) {
+func AddToITasserQueueWithParent(sequence string, parentId *int64, userId uint) {
 	var itasserQueue models.ITasserQueue
 	
 	// 构建查询条件
@@ -29,12 +29,13 @@ func AddToITasserQueueWithParent(sequence string, parentId *int64) {
 			itasserQueue = models.ITasserQueue{
 				Sequence: sequence,
 				ParentId: parentId,
-				Status:   "pending",
+				QueueJob: newQueueJob(userId),
 			}
 			if err := database.Database.Create(&itasserQueue).Error; err != nil {
 				logger.Error("创建ITasser队列失败: %v", err)
 			} else {
 				logger.Info("已添加序列到ITasser队列，ID: %d", itasserQueue.ID)
+				WakeQueueScheduler()
 			}
 		} else {
 			logger.Error("查询ITasser队列失败: %v", err)
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"errors"
+	"time"
+)
+
+// queueRow 队列记录中取消任务需要的字段
+type queueRow struct {
+	ID       uint
+	Sequence string
+	Status   string
+}
+
+type CancelJobRequest struct {
+	Tool string `json:"tool" binding:"required"` // "alpha", "itasser", "esm"
+	Id   uint   `json:"id" binding:"required"`
+}
+
+// CancelledJob 已取消的队列记录
+type CancelledJob struct {
+	Tool string `json:"tool"`
+	Id   uint   `json:"id"`
+}
+
+type CancelResult struct {
+	Cancelled []CancelledJob `json:"cancelled"`
+	Skipped   int            `json:"skipped"` // 与其他用户的任务共用、未取消的记录数
+	Error     string         `json:"error,omitempty"`
+}
+
+// errJobShared 队列记录同时被其他用户的任务使用
+var errJobShared = errors.New("The job is used by other users' tasks and cannot be cancelled.")
+
+// CancelQueueJob 取消单条队列记录
+func CancelQueueJob(userId uint, req CancelJobRequest) CancelResult {
+	tool, ok := queueTools[req.Tool]
+	if !ok {
+		return CancelResult{Error: "Invalid tool."}
+	}
+
+	var row queueRow
+	if err := tool.query().Select("id, sequence, status").Where("id = ?", req.Id).Scan(&row).Error; err != nil || row.ID == 0 {
+		return CancelResult{Error: "Job not found."}
+	}
+	if err := checkJobCancellable(userId, row.Sequence); err != nil {
+		return CancelResult{Error: err.Error()}
+	}
+	if err := cancelQueueRow(tool, row); err != nil {
+		return CancelResult{Error: err.Error()}
+	}
+	return CancelResult{Cancelled: []CancelledJob{{Tool: req.Tool, Id: row.ID}}}
+}
+
+// CancelTaskJobs 取消任务所有序列中等待或执行中的队列记录
+func CancelTaskJobs(userId uint, taskId uint) CancelResult {
+	var task models.Task
+	if err := database.Database.Where("id = ? AND user_id = ?", taskId, userId).First(&task).Error; err != nil {
+		return CancelResult{Error: "Task not found."}
+	}
+
+	result := CancelResult{Cancelled: []CancelledJob{}}
+	statuses := append([]string{JobStatusPending}, jobActiveStatuses...)
+	for key, tool := range queueTools {
+		var rows []queueRow
+		if err := tool.query().Select("id, sequence, status").
+			Where("sequence IN ? AND status IN ?", taskSequences(task), statuses).Scan(&rows).Error; err != nil {
+			logger.Error("查询%s队列记录失败: %v", tool.Name, err)
+			return CancelResult{Error: "Network error."}
+		}
+		for _, row := range rows {
+			if err := checkJobCancellable(userId, row.Sequence); err != nil {
+				result.Skipped++
+				continue
+			}
+			if err := cancelQueueRow(tool, row); err != nil {
+				logger.Error("取消%s任务 ID %d 失败: %v", tool.Name, row.ID, err)
+				continue
+			}
+			result.Cancelled = append(result.Cancelled, CancelledJob{Tool: key, Id: row.ID})
+		}
+	}
+
+	RefreshTaskStatus(task)
+	return result
+}
+
+// checkJobCancellable 只有序列所属的任务全部属于该用户时才允许取消
+func checkJobCancellable(userId uint, sequence string) error {
+	var proteinInfo models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInfo).Error; err != nil || proteinInfo.ID == 0 {
+		return errors.New("Job not found.")
+	}
+	tasks, err := FindTasksByProtein(proteinInfo)
+	if err != nil {
+		return errors.New("Network error.")
+	}
+
+	owned := false
+	for _, task := range tasks {
+		if uint(task.UserId) != userId {
+			return errJobShared
+		}
+		owned = true
+	}
+	if !owned {
+		return errors.New("Job not found.")
+	}
+	return nil
+}
+
+// cancelQueueRow 取消等待中的记录；执行中的记录先标记为取消，再终止正在运行的进程
+// 读取状态之后记录可能已被认领，状态转换失败时重新读取状态再试，只有已结束的记录才报告无法取消
+func cancelQueueRow(tool queueTool, row queueRow) error {
+	status := row.Status
+	for attempt := 0; attempt < 3; attempt++ {
+		if isJobFinished(status) {
+			return errors.New("The job has already finished.")
+		}
+		from := []string{JobStatusPending}
+		running := status != JobStatusPending
+		if running {
+			from = jobActiveStatuses
+		}
+
+		// 保留租约，执行该记录的实例在心跳时据此终止进程
+		ok, err := transitionQueueJob(tool.newModel(), tool.Name, row.ID, row.Sequence, from, map[string]interface{}{
+			"status":      JobStatusCancelled,
+			"finished_at": time.Now(),
+		})
+		if err != nil {
+			return errors.New("Network error.")
+		}
+		if ok {
+			logger.Info("%s任务 ID %d 已取消", tool.Name, row.ID)
+			if running {
+				// 进程运行在其他实例上时，由该实例在心跳时终止；远程 worker 在心跳中收到失去租约的记录
+				cancelRunningJob(tool.Name, row.ID)
+			}
+			return nil
+		}
+
+		var current queueRow
+		if err := tool.query().Select("id, status").Where("id = ?", row.ID).Scan(&current).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		if current.ID == 0 {
+			return errors.New("Job not found.")
+		}
+		status = current.Status
+	}
+	return errors.New("The job status is changing, please try again.")
+}
+
+// isJobFinished 判断记录是否已结束（成功、失败或取消）
+func isJobFinished(status string) bool {
+	for _, finished := range jobFinishedStatuses {
+		if status == finished {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"testing"
+	"time"
+)
+
+// TestCancelStopsJobOnOwningInstance 取消请求由其他实例处理时，执行该记录的实例在心跳时终止进程
+func TestCancelStopsJobOnOwningInstance(t *testing.T) {
+	db := openTestDatabase(t)
+	tool := queueTools["fake"]
+
+	now := time.Now()
+	job := models.PredictionQueue{Predictor: tool.Key, Sequence: "MKTAYIAKQR"}
+	job.Status, job.StartedAt = JobStatusRunning, &now
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&job).Updates(leaseUpdates(now)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他实例处理取消请求：本实例的进程不在其登记的任务中
+	if err := cancelQueueRow(tool, queueRow{ID: job.ID, Sequence: job.Sequence, Status: JobStatusRunning}); err != nil {
+		t.Fatalf("cancelQueueRow: %v", err)
+	}
+	db.First(&job, job.ID)
+	if job.Status != JobStatusCancelled || job.LeaseOwner != schedulerInstanceId {
+		t.Fatalf("取消后 status = %s lease_owner = %q，应保留租约", job.Status, job.LeaseOwner)
+	}
+
+	cancelled := make(chan struct{})
+	finish := trackRunningJob(tool.Name, job.ID, func() { close(cancelled) })
+	defer finish()
+
+	renewJobLeases()
+	stopCancelledJobs()
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("心跳没有终止已取消的任务")
+	}
+
+	// 已处理的记录不再重复终止，租约所有者保留在记录中用于归档
+	var stopped models.PredictionQueue
+	db.First(&stopped, job.ID)
+	if stopped.LeaseExpiresAt != nil || stopped.LeaseOwner != schedulerInstanceId {
+		t.Errorf("lease_expires_at = %v lease_owner = %q", stopped.LeaseExpiresAt, stopped.LeaseOwner)
+	}
+	if !finish() {
+		t.Error("任务应标记为已取消")
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// jobRetention 已结束的记录在队列表中的保留时间，按状态配置，超过后归档到 job_histories 并删除
+// 可通过 JOB_RETENTION_{STATUS}_HOURS 修改，0 表示不清理
+var jobRetention = map[string]time.Duration{
+	JobStatusSucceeded: 24 * time.Hour,
+	JobStatusFailed:    7 * 24 * time.Hour,
+	JobStatusCancelled: 24 * time.Hour,
+}
+
+// jobHistoryRetention 历史记录的保留时间，0 表示永久保留，可通过 JOB_HISTORY_RETENTION_DAYS 修改
+var jobHistoryRetention time.Duration
+
+// 每次归档的记录数
+const jobArchiveBatch = 200
+
+func init() {
+	for status := range jobRetention {
+		if hours, ok := envInt("JOB_RETENTION_" + strings.ToUpper(status) + "_HOURS"); ok {
+			jobRetention[status] = time.Duration(hours) * time.Hour
+		}
+	}
+	if days, ok := envInt("JOB_HISTORY_RETENTION_DAYS"); ok {
+		jobHistoryRetention = time.Duration(days) * 24 * time.Hour
+	}
+}
+
+// finishedJob 归档时读取的队列记录字段
+type finishedJob struct {
+	ID           uint
+	CreatedAt    time.Time
+	Sequence     string
+	ParentId     *int64
+	UserId       uint
+	Priority     int
+	Status       string
+	ErrorMessage string
+	Attempts     int
+	Version      string
+	LeaseOwner   string
+	StartedAt    *time.Time
+	FinishedAt   *time.Time
+}
+
+const finishedJobColumns = "id, created_at, sequence, parent_id, user_id, priority, status, error_message, attempts, version, lease_owner, started_at, finished_at"
+
+// jobHistoryRecord 由已结束的队列记录构造历史记录
+func jobHistoryRecord(tool queueTool, job finishedJob) models.JobHistory {
+	history := models.JobHistory{
+		Predictor:    tool.Key,
+		QueueId:      job.ID,
+		Sequence:     job.Sequence,
+		ParentId:     job.ParentId,
+		UserId:       job.UserId,
+		Priority:     job.Priority,
+		Status:       job.Status,
+		ErrorMessage: job.ErrorMessage,
+		Attempts:     job.Attempts,
+		Version:      job.Version,
+		LeaseOwner:   job.LeaseOwner,
+		QueuedAt:     job.CreatedAt,
+		StartedAt:    job.StartedAt,
+		FinishedAt:   job.FinishedAt,
+	}
+	if job.StartedAt != nil && job.FinishedAt != nil && job.FinishedAt.After(*job.StartedAt) {
+		history.Duration = job.FinishedAt.Sub(*job.StartedAt).Seconds()
+	}
+	return history
+}
+
+// archiveJobs 归档一批符合条件的已结束记录：在同一事务中锁定并删除记录，只把实际删除的记录写入历史表
+// 锁定的记录在查询和删除之间不会被重新排队；同一次执行已有历史记录时不再写入。返回归档的记录ID
+func archiveJobs(tool queueTool, conditions func(*gorm.DB) *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(tool.newModel())
+		if tool.shared {
+			query = query.Where("predictor = ?", tool.Key)
+		}
+		var jobs []finishedJob
+		if err := conditions(query).Select(finishedJobColumns).Where("status IN ?", jobFinishedStatuses).
+			Clauses(clause.Locking{Strength: "UPDATE"}).Order("id").Limit(jobArchiveBatch).Scan(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		histories := make([]models.JobHistory, 0, len(jobs))
+		for _, job := range jobs {
+			histories = append(histories, jobHistoryRecord(tool, job))
+			ids = append(ids, job.ID)
+		}
+		result := tx.Unscoped().Where("id IN ? AND status IN ?", ids, jobFinishedStatuses).Delete(tool.newModel())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(ids)) {
+			return fmt.Errorf("%d 条记录在归档时已被修改", int64(len(ids))-result.RowsAffected)
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&histories).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		removeJobWorkspace(jobWorkspace(tool.Key, id))
+		removeJobLog(tool.Key, id)
+	}
+	return ids, nil
+}
+
+// archiveQueueJob 把已结束的队列记录归档并删除，用于重新排队前保留上一次执行的结果；返回记录是否已归档
+func archiveQueueJob(tool queueTool, id uint) bool {
+	ids, err := archiveJobs(tool, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", id)
+	})
+	if err != nil {
+		logger.Error("归档%s任务 %d 失败: %v", tool.Name, id, err)
+		return false
+	}
+	return len(ids) > 0
+}
+
+// archiveExpiredJobs 把超过保留时间的已结束记录写入历史表，并删除队列记录、工作目录和日志
+func archiveExpiredJobs(tool queueTool, now time.Time) {
+	for status, retention := range jobRetention {
+		if retention <= 0 {
+			continue
+		}
+		before := now.Add(-retention)
+		for {
+			ids, err := archiveJobs(tool, func(db *gorm.DB) *gorm.DB {
+				return db.Where("status = ? AND updated_at < ?", status, before)
+			})
+			if err != nil {
+				logger.Error("归档%s已结束任务失败: %v", tool.Name, err)
+				break
+			}
+			if len(ids) == 0 {
+				break
+			}
+			logger.Info("已归档 %d 条%s的%s任务", len(ids), status, tool.Name)
+			if len(ids) < jobArchiveBatch {
+				break
+			}
+		}
+	}
+}
+
+// purgeJobHistory 删除超过保留时间的历史记录
+func purgeJobHistory(now time.Time) {
+	if jobHistoryRetention <= 0 {
+		return
+	}
+	if err := database.Database.Unscoped().Where("created_at < ?", now.Add(-jobHistoryRetention)).Delete(&models.JobHistory{}).Error; err != nil {
+		logger.Error("清理任务历史失败: %v", err)
+	}
+}
+
+// JobHistoryQuery 查询任务历史的条件
+type JobHistoryQuery struct {
+	Predictor string `form:"predictor"`
+	Status    string `form:"status"`
+	UserId    uint   `form:"userId"`
+	Sequence  string `form:"sequence"`
+	From      int64  `form:"from"` // 完成时间范围，毫秒时间戳
+	To        int64  `form:"to"`
+	Current   int    `form:"current"`
+	PageSize  int    `form:"pageSize"`
+}
+
+// JobHistoryItem 任务历史中的一条记录
+type JobHistoryItem struct {
+	Id         uint    `json:"id"`
+	Predictor  string  `json:"predictor"`
+	QueueId    uint    `json:"queueId"`
+	Sequence   string  `json:"sequence"`
+	UserId     uint    `json:"userId"`
+	Priority   int     `json:"priority"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	Attempts   int     `json:"attempts"`
+	Version    string  `json:"version"`
+	LeaseOwner string  `json:"leaseOwner"`
+	QueuedAt   int64   `json:"queuedAt"` // 毫秒时间戳
+	StartedAt  *int64  `json:"startedAt"`
+	FinishedAt *int64  `json:"finishedAt"`
+	Duration   float64 `json:"duration"` // 秒
+	ArchivedAt int64   `json:"archivedAt"`
+}
+
+// JobHistoryListResult 任务历史分页结果
+type JobHistoryListResult struct {
+	List  []JobHistoryItem `json:"list"`
+	Total int64            `json:"total"`
+}
+
+func millis(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ms := t.UnixMilli()
+	return &ms
+}
+
+func toJobHistoryItem(history models.JobHistory) JobHistoryItem {
+	return JobHistoryItem{
+		Id:         history.ID,
+		Predictor:  history.Predictor,
+		QueueId:    history.QueueId,
+		Sequence:   history.Sequence,
+		UserId:     history.UserId,
+		Priority:   history.Priority,
+		Status:     history.Status,
+		Error:      history.ErrorMessage,
+		Attempts:   history.Attempts,
+		Version:    history.Version,
+		LeaseOwner: history.LeaseOwner,
+		QueuedAt:   history.QueuedAt.UnixMilli(),
+		StartedAt:  millis(history.StartedAt),
+		FinishedAt: millis(history.FinishedAt),
+		Duration:   history.Duration,
+		ArchivedAt: history.CreatedAt.UnixMilli(),
+	}
+}
+
+// ListJobHistory 按条件分页查询任务历史，按完成时间倒序
+func ListJobHistory(query JobHistoryQuery) (JobHistoryListResult, error) {
+	if query.Current < 1 {
+		query.Current = 1
+	}
+	if query.PageSize < 1 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+	if query.Status != "" && query.Status != JobStatusSucceeded && query.Status != JobStatusFailed && query.Status != JobStatusCancelled {
+		return JobHistoryListResult{}, fmt.Errorf("Invalid status.")
+	}
+
+	db := database.Database.Model(&models.JobHistory{})
+	if query.Predictor != "" {
+		db = db.Where("predictor = ?", query.Predictor)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.UserId != 0 {
+		db = db.Where("user_id = ?", query.UserId)
+	}
+	if query.Sequence != "" {
+		db = db.Where("sequence = ?", strings.ToUpper(strings.TrimSpace(query.Sequence)))
+	}
+	if query.From > 0 {
+		db = db.Where("finished_at >= ?", time.UnixMilli(query.From))
+	}
+	if query.To > 0 {
+		db = db.Where("finished_at < ?", time.UnixMilli(query.To))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return JobHistoryListResult{}, fmt.Errorf("Network error.")
+	}
+	var histories []models.JobHistory
+	if err := db.Order("finished_at DESC, id DESC").Offset((query.Current - 1) * query.PageSize).Limit(query.PageSize).Find(&histories).Error; err != nil {
+		return JobHistoryListResult{}, fmt.Errorf("Network error.")
+	}
+
+	list := make([]JobHistoryItem, 0, len(histories))
+	for _, history := range histories {
+		list = append(list, toJobHistoryItem(history))
+	}
+	return JobHistoryListResult{List: list, Total: total}, nil
+}
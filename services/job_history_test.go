@@ -0,0 +1,96 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"testing"
+	"time"
+)
+
+func TestArchiveExpiredJobs(t *testing.T) {
+	db := openTestDatabase(t)
+	tool := queueTools["fake"]
+	now := time.Now()
+
+	jobs := []models.PredictionQueue{
+		{Predictor: tool.Key, Sequence: "EXPIRED"},
+		{Predictor: tool.Key, Sequence: "RECENT"},
+		{Predictor: tool.Key, Sequence: "REQUEUED"},
+		{Predictor: tool.Key, Sequence: "ARCHIVED"},
+	}
+	jobs[0].Status, jobs[0].Attempts = JobStatusSucceeded, 1
+	jobs[1].Status, jobs[1].Attempts = JobStatusSucceeded, 1
+	jobs[2].Status = JobStatusPending
+	jobs[3].Status, jobs[3].Attempts = JobStatusFailed, 2
+	for i := range jobs {
+		if err := db.Create(&jobs[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired := now.Add(-30 * 24 * time.Hour)
+	db.Model(&models.PredictionQueue{}).Where("id IN ?", []uint{jobs[0].ID, jobs[2].ID, jobs[3].ID}).UpdateColumn("updated_at", expired)
+	// 同一次执行之前已经归档过
+	db.Create(&models.JobHistory{Predictor: tool.Key, QueueId: jobs[3].ID, Sequence: "ARCHIVED", Status: JobStatusFailed, Attempts: 2})
+
+	archiveExpiredJobs(tool, now)
+	archiveExpiredJobs(tool, now)
+
+	var remaining []string
+	db.Model(&models.PredictionQueue{}).Order("id").Pluck("sequence", &remaining)
+	if len(remaining) != 2 || remaining[0] != "RECENT" || remaining[1] != "REQUEUED" {
+		t.Errorf("remaining queue rows = %v, want [RECENT REQUEUED]", remaining)
+	}
+	var histories []models.JobHistory
+	db.Order("id").Find(&histories)
+	if len(histories) != 2 {
+		t.Fatalf("histories = %+v, want ARCHIVED and EXPIRED once each", histories)
+	}
+	if histories[1].Sequence != "EXPIRED" || histories[1].QueueId != jobs[0].ID || histories[1].Attempts != 1 {
+		t.Errorf("history = %+v", histories[1])
+	}
+}
+
+func TestEnqueueMissingPredictionArchivesPreviousRun(t *testing.T) {
+	db := openTestDatabase(t)
+	tool := queueTools["fake"]
+
+	proteinInfo := models.ProteinInformation{Sequence: "MKTAYIAKQR"}
+	if err := db.Create(&proteinInfo).Error; err != nil {
+		t.Fatal(err)
+	}
+	finished := time.Now()
+	job := models.PredictionQueue{Predictor: tool.Key, Sequence: proteinInfo.Sequence}
+	job.Status, job.Attempts, job.ErrorMessage, job.FinishedAt = JobStatusFailed, 3, "boom", &finished
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if !enqueueMissingPrediction(proteinInfo, 99, 0) {
+		t.Fatal("enqueueMissingPrediction should requeue the sequence")
+	}
+	// 上一次执行已归档并删除，记录不会再被归档
+	if archiveQueueJob(tool, job.ID) {
+		t.Error("the previous run was archived twice")
+	}
+
+	var histories []models.JobHistory
+	db.Find(&histories)
+	if len(histories) != 1 || histories[0].QueueId != job.ID || histories[0].Attempts != 3 || histories[0].ErrorMessage != "boom" {
+		t.Errorf("histories = %+v", histories)
+	}
+	var queued []models.PredictionQueue
+	db.Where("sequence = ?", proteinInfo.Sequence).Find(&queued)
+	if len(queued) != 1 || queued[0].ID == job.ID || queued[0].Status != JobStatusPending || queued[0].Attempts != 0 {
+		t.Errorf("queue rows = %+v, want one new pending row", queued)
+	}
+
+	// 排队中的记录不归档，也不重复排队
+	enqueueMissingPrediction(proteinInfo, 99, 0)
+	if archiveQueueJob(tool, queued[0].ID) {
+		t.Error("pending row was archived")
+	}
+	var count int64
+	db.Model(&models.JobHistory{}).Count(&count)
+	if count != 1 {
+		t.Errorf("history count = %d, want 1", count)
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jobLogRoot 预测任务日志的根目录
+var jobLogRoot = filepath.Join("logs", "jobs")
+
+// jobLogMaxBytes 单个日志文件的大小上限，超过后轮转为 .1 文件，每条记录最多保留两个文件
+var jobLogMaxBytes int64 = 10 << 20
+
+// 日志接口默认和最多返回的末尾行数
+const (
+	jobLogDefaultTail = 200
+	jobLogMaxTail     = 10000
+)
+
+func init() {
+	if value := os.Getenv("JOB_LOG_DIR"); value != "" {
+		jobLogRoot = value
+	}
+	if value, err := strconv.ParseInt(os.Getenv("JOB_LOG_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		jobLogMaxBytes = value
+	}
+}
+
+// jobLogPath 返回预测任务的日志文件路径，按工具和队列记录ID区分
+func jobLogPath(tool string, id uint) string {
+	return filepath.Join(jobLogRoot, tool, fmt.Sprintf("%d.log", id))
+}
+
+// jobLogWriter 追加写入任务日志，超过大小上限时轮转
+// 写入失败只记录错误，不影响正在执行的命令
+type jobLogWriter struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	size   int64
+	failed bool
+}
+
+// openJobLog 打开任务日志用于追加，重试的输出接在之前的执行之后
+func openJobLog(tool string, id uint) (*jobLogWriter, error) {
+	path := jobLogPath(tool, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %v", err)
+	}
+	w := &jobLogWriter{path: path}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *jobLogWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("创建日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件失败: %v", err)
+	}
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+// rotate 把当前日志移动为 .1 文件（覆盖更早的轮转文件）并重新开始
+func (w *jobLogWriter) rotate() error {
+	w.file.Close()
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func (w *jobLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(p)
+	if w.failed {
+		return n, nil
+	}
+
+	// 单次写入超过上限时只保留末尾部分
+	if int64(len(p)) > jobLogMaxBytes {
+		p = p[int64(len(p))-jobLogMaxBytes:]
+	}
+	if w.size > 0 && w.size+int64(len(p)) > jobLogMaxBytes {
+		if err := w.rotate(); err != nil {
+			w.fail(err)
+			return n, nil
+		}
+	}
+	written, err := w.file.Write(p)
+	w.size += int64(written)
+	if err != nil {
+		w.fail(err)
+	}
+	return n, nil
+}
+
+func (w *jobLogWriter) fail(err error) {
+	w.failed = true
+	logger.Error("写入任务日志 %s 失败: %v", w.path, err)
+}
+
+// Printf 写入一行带时间的说明，用于标记每次执行的开始和结果
+func (w *jobLogWriter) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(w, "[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func (w *jobLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+// saveJobLog 把远程 worker 上传的日志追加到预测任务的日志中
+func saveJobLog(tool string, id uint, content io.Reader) error {
+	w, err := openJobLog(tool, id)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	w.Printf("远程 worker 上传的日志")
+	if _, err := io.Copy(w, content); err != nil {
+		return fmt.Errorf("写入日志文件失败: %v", err)
+	}
+	return nil
+}
+
+// removeJobLog 删除任务的日志及其轮转文件
+func removeJobLog(tool string, id uint) {
+	path := jobLogPath(tool, id)
+	for _, file := range []string{path, path + ".1"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			logger.Error("删除任务日志 %s 失败: %v", file, err)
+		}
+	}
+}
+
+// tailBuffer 只保留最后 limit 字节的输出，用于错误信息和远程 worker 上传的日志
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf...)
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.Bytes())
+}
+
+// runLoggedCommand 执行外部命令，log 不为 nil 时实时写入命令的输出，返回输出的末尾部分
+func runLoggedCommand(cmd *exec.Cmd, log io.Writer) ([]byte, error) {
+	output := newTailBuffer(commandOutputTail)
+	var writer io.Writer = output
+	if log != nil {
+		fmt.Fprintf(log, "$ %s\n", strings.Join(cmd.Args, " "))
+		writer = io.MultiWriter(output, log)
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	err := cmd.Run()
+	return output.Bytes(), err
+}
+
+// JobLogRequest 查看任务日志的请求
+type JobLogRequest struct {
+	Tool   string `form:"tool" binding:"required"` // 预测工具，例如 "alpha"；"blast" 表示 blast 任务的结构域搜索，id 为任务ID
+	Id     uint   `form:"id" binding:"required"`
+	Tail   int    `form:"tail"`   // 返回末尾的行数，默认 200
+	Offset *int64 `form:"offset"` // 从当前日志文件的该位置继续读取，用于轮询新输出
+}
+
+// JobLog 任务日志的内容
+type JobLog struct {
+	Tool      string `json:"tool"`
+	Id        uint   `json:"id"`
+	Content   string `json:"content"`
+	Offset    int64  `json:"offset"`    // 当前日志文件的大小，下一次请求使用该 offset 只读取新输出
+	Truncated bool   `json:"truncated"` // 只返回了部分内容
+	Running   bool   `json:"running"`   // 任务仍在执行，日志可能继续增加
+}
+
+// jobLogAccess 检查用户是否可以查看任务日志，返回任务是否仍在执行
+// 预测记录的提交者、管理员以及可以查看该序列所属任务的用户可以查看；blast 日志按任务权限
+func jobLogAccess(userId uint, toolKey string, id uint) (bool, error) {
+	if toolKey == "blast" {
+		if !UserCanAccessTask(userId, id) {
+			return false, fmt.Errorf("Job not found.")
+		}
+		var running int64
+		database.Database.Model(&models.TaskStage{}).Where("task_id = ? AND stage IN ? AND status = ?", id, blastStages, JobStatusRunning).Count(&running)
+		return running > 0, nil
+	}
+
+	tool, ok := queueTools[toolKey]
+	if !ok {
+		return false, fmt.Errorf("Job not found.")
+	}
+	var job struct {
+		ID       uint
+		Sequence string
+		Status   string
+		UserId   uint
+	}
+	if err := tool.query().Select("id, sequence, status, user_id").Where("id = ?", id).Scan(&job).Error; err != nil || job.ID == 0 {
+		return false, fmt.Errorf("Job not found.")
+	}
+	if job.UserId != userId && !IsAdmin(userId) {
+		var proteinInfo models.ProteinInformation
+		database.Database.Select("id").Where("sequence = ?", job.Sequence).Find(&proteinInfo)
+		if proteinInfo.ID == 0 || !UserCanAccessProtein(userId, proteinInfo.ID) {
+			return false, fmt.Errorf("Job not found.")
+		}
+	}
+	for _, status := range jobActiveStatuses {
+		if job.Status == status {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetJobLog 返回任务日志的末尾部分，指定 offset 时返回该位置之后的新输出
+func GetJobLog(userId uint, req JobLogRequest) (*JobLog, error) {
+	running, err := jobLogAccess(userId, req.Tool, req.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	path := jobLogPath(req.Tool, req.Id)
+	result := &JobLog{Tool: req.Tool, Id: req.Id, Running: running}
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Failed to read log.")
+	}
+	result.Offset = int64(len(current))
+
+	if req.Offset != nil {
+		// offset 超过文件大小说明日志已经轮转，从新文件开头读取
+		offset := *req.Offset
+		if offset < 0 || offset > int64(len(current)) {
+			offset = 0
+			result.Truncated = true
+		}
+		result.Content = string(current[offset:])
+		return result, nil
+	}
+
+	tail := req.Tail
+	if tail <= 0 {
+		tail = jobLogDefaultTail
+	}
+	if tail > jobLogMaxTail {
+		tail = jobLogMaxTail
+	}
+	content := current
+	if rotated, err := os.ReadFile(path + ".1"); err == nil {
+		content = append(rotated, current...)
+		result.Truncated = true
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+		result.Truncated = true
+	}
+	result.Content = string(bytes.Join(lines, nil))
+	return result, nil
+}
+
+// JobLogFiles 返回可以下载的任务日志文件，按时间顺序排列（轮转文件在前）
+func JobLogFiles(userId uint, toolKey string, id uint) ([]string, error) {
+	if _, err := jobLogAccess(userId, toolKey, id); err != nil {
+		return nil, err
+	}
+	path := jobLogPath(toolKey, id)
+	var files []string
+	for _, file := range []string{path + ".1", path} {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("Log not found.")
+	}
+	return files, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runningJob 本实例中正在执行、可以被取消的队列任务
+type runningJob struct {
+	cancel    func()
+	cancelled bool
+}
+
+var (
+	runningJobsMu sync.Mutex
+	runningJobs   = make(map[string]*runningJob)
+)
+
+func runningJobKey(tool string, id uint) string {
+	return fmt.Sprintf("%s:%d", tool, id)
+}
+
+// trackRunningJob 登记正在执行的任务及其取消方法，返回的函数在执行结束时调用，并返回任务是否被取消
+func trackRunningJob(tool string, id uint, cancel func()) func() bool {
+	key := runningJobKey(tool, id)
+	job := &runningJob{cancel: cancel}
+
+	runningJobsMu.Lock()
+	runningJobs[key] = job
+	runningJobsMu.Unlock()
+
+	return func() bool {
+		runningJobsMu.Lock()
+		defer runningJobsMu.Unlock()
+		if runningJobs[key] == job {
+			delete(runningJobs, key)
+		}
+		return job.cancelled
+	}
+}
+
+// cancelRunningJob 取消本实例中正在执行的任务，返回是否找到该任务
+func cancelRunningJob(tool string, id uint) bool {
+	runningJobsMu.Lock()
+	job, ok := runningJobs[runningJobKey(tool, id)]
+	if ok {
+		job.cancelled = true
+	}
+	runningJobsMu.Unlock()
+
+	if ok {
+		logger.Info("正在终止%s任务 ID %d", tool, id)
+		job.cancel()
+	}
+	return ok
+}
+
+// queueJobActive 判断队列记录是否仍处于执行中的状态
+func queueJobActive(model interface{}, id uint) bool {
+	var count int64
+	if err := database.Database.Model(model).Where("id = ? AND status IN ?", id, jobActiveStatuses).Count(&count).Error; err != nil {
+		return true
+	}
+	return count > 0
+}
+
+// 命令输出在内存中保留的末尾字节数，用于错误信息
+const commandOutputTail = 64 << 10
+
+// runJobCommand 在独立进程组中执行预测命令并应用资源限制，log 不为 nil 时实时写入命令的输出
+// 返回输出的末尾部分；ctx 结束（任务被取消）时终止整个进程组并返回 errJobCancelled，超过 timeout 时终止进程组并返回 errJobTimeout
+func runJobCommand(ctx context.Context, tool string, id uint, cmd *exec.Cmd, limits predictorLimits, timeout time.Duration, log io.Writer) ([]byte, error) {
+	output := newTailBuffer(commandOutputTail)
+	var writer io.Writer = output
+	if log != nil {
+		fmt.Fprintf(log, "$ %s\n", strings.Join(cmd.Args, " "))
+		writer = io.MultiWriter(output, log)
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	kill := func() {
+		if err := killProcessGroup(cmd); err != nil {
+			logger.Error("终止%s任务 ID %d 的进程组失败: %v", tool, id, err)
+		}
+	}
+	// 通过 prlimit 启动的命令在 exec 之前已经设置了限制
+	if _, ok := limits.prlimitPath(); !ok {
+		if err := applyProcessLimits(cmd.Process.Pid, limits); err != nil {
+			logger.Error("设置%s任务 ID %d 的资源限制失败: %v", tool, id, err)
+		}
+	}
+
+	// 任务被取消时终止整个进程组
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			kill()
+		case <-done:
+		}
+	}()
+
+	// 超时后终止整个进程组
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			logger.Warn("%s任务 ID %d 运行超过 %v，终止进程", tool, id, timeout)
+			timedOut.Store(true)
+			kill()
+		})
+		defer timer.Stop()
+	}
+
+	err := cmd.Wait()
+	if ctx.Err() != nil {
+		return output.Bytes(), errJobCancelled
+	}
+	if timedOut.Load() {
+		// 超时可能是节点负载过高导致的，按可重试的错误处理
+		return output.Bytes(), fmt.Errorf("%w: %w: %s运行超过 %v", errJobTransient, errJobTimeout, tool, timeout)
+	}
+	return output.Bytes(), err
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package services
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunJobCommandErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		script        string
+		timeout       time.Duration
+		wantTransient bool
+		wantTimeout   bool
+		wantMessage   string
+	}{
+		{"超时后重试", "sleep 5", 100 * time.Millisecond, true, true, "运行超过"},
+		{"超过 CPU 时间限制", "kill -XCPU $$", 0, false, false, "超过 CPU 时间限制"},
+		{"没有取消或超时时被 SIGKILL 终止", "kill -KILL $$", 0, false, false, "可能超过了内存限制"},
+		{"其他信号", "kill -TERM $$", 0, false, false, "被信号"},
+		{"非零退出码", "echo failed; exit 3", 0, false, false, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := runJobCommand(context.Background(), "fake", 1, exec.Command("sh", "-c", tt.script), predictorLimits{}, tt.timeout, nil)
+			if err != nil && !errors.Is(err, errJobTimeout) {
+				err = commandJobError("fake", err, output)
+			}
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if errors.Is(err, errJobTransient) != tt.wantTransient || errors.Is(err, errJobTimeout) != tt.wantTimeout {
+				t.Errorf("err = %v, want transient %v timeout %v", err, tt.wantTransient, tt.wantTimeout)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("err = %v, want message containing %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
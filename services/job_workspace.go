@@ -0,0 +1,44 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// jobWorkspaceRoot 每个预测任务独立工作目录的根目录
+var jobWorkspaceRoot = "workspaces"
+
+func init() {
+	if value := os.Getenv("JOB_WORKSPACE_DIR"); value != "" {
+		jobWorkspaceRoot = value
+	}
+}
+
+// jobWorkspace 返回预测任务的工作目录（绝对路径），按工具和队列记录ID区分
+func jobWorkspace(tool string, id uint) string {
+	dir := filepath.Join(jobWorkspaceRoot, tool, fmt.Sprintf("%d", id))
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// prepareJobWorkspace 清空并重新创建任务的工作目录，避免重试时读到上一次执行的输出
+func prepareJobWorkspace(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("清空工作目录失败: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建工作目录失败: %v", err)
+	}
+	return nil
+}
+
+// removeJobWorkspace 删除任务的工作目录
+func removeJobWorkspace(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		logger.Error("删除工作目录 %s 失败: %v", dir, err)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	sqlitedriver "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// TestMain 在临时目录中运行测试（模型、工作目录和日志都写入相对路径），并注册假预测工具
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "protein-server-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// 预测工具在 init 中注册，测试进程启动之后才能设置环境变量
+	os.Setenv("FAKE_PREDICTOR", "true")
+	if fake, ok := newFakePredictorFromEnv(); ok {
+		registerSharedPredictor(fake)
+	}
+
+	// 耗时模型的查询使用 MySQL 的 CHAR_LENGTH
+	sqlitedriver.MustRegisterDeterministicScalarFunction("char_length", 1, func(ctx *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+		value, _ := args[0].(string)
+		return int64(utf8.RuneCountInString(value)), nil
+	})
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// openTestDatabase 为测试创建独立的 SQLite 数据库并建表，测试结束后恢复原来的数据库
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	// search_documents 的 FULLTEXT 索引只有 MySQL 支持，表本身已经创建
+	for _, model := range database.Models {
+		if err := db.AutoMigrate(model); err != nil && !strings.Contains(err.Error(), "FULLTEXT") {
+			t.Fatalf("建表失败: %v", err)
+		}
+	}
+
+	// 每个数据库的记录ID从 1 开始，清空之前测试保存的模型文件
+	os.RemoveAll("static")
+
+	previous := database.Database
+	database.Database = db
+	t.Cleanup(func() {
+		database.Database = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// stubProteinStages 后处理阶段调用外部脚本和 RCSB 接口，测试中替换为只记录执行过的阶段
+func stubProteinStages(t *testing.T) *[]string {
+	t.Helper()
+	var stages []string
+	steps := proteinStageSteps
+	t.Cleanup(func() { proteinStageSteps = steps })
+	proteinStageSteps = func(proteinInfo *models.ProteinInformation, force bool) map[string]func() error {
+		record := func(stage string) func() error {
+			return func() error {
+				stages = append(stages, stage)
+				return nil
+			}
+		}
+		return map[string]func() error{
+			StageParameters:   record(StageParameters),
+			StageRamachandran: record(StageRamachandran),
+			StageStructureNum: record(StageStructureNum),
+		}
+	}
+	return &stages
+}
@@ -3,29 +3,32 @@ package services
 import (
 	"Protein_Server/database"
 	"Protein_Server/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ViewNoteResult struct {
-	Data  string `json:"data,omitempty"`
-	Error string `json:"error,omitempty"`
+	Data    string `json:"data,omitempty"`
+	Version int64  `json:"version"`
+	Error   string `json:"error,omitempty"`
 }
 
-// ViewNote 查看用户对某个序列的注释
+// ViewNote 查看任务的注释，拥有者和可以查看该任务的协作者看到同一条注释
 func ViewNote(userId uint, sequenceId uint) ViewNoteResult {
-	var notes []models.Note
-	
-	// 查找指定用户对指定任务的注释记录（根据实际模型结构调整）
-	if err := database.Database.Where("user_id = ? AND task_id = ?", userId, sequenceId).Find(&notes).Error; err != nil {
-		return ViewNoteResult{Error: "Network error."}
+	if !UserCanAccessTask(userId, sequenceId) {
+		return ViewNoteResult{Error: "Task not found."}
 	}
-	
-	// 如果没有记录，返回空字符串
-	if len(notes) == 0 {
-		return ViewNoteResult{Data: ""}
+
+	var note models.Note
+	if err := database.Database.Where("task_id = ?", sequenceId).Find(&note).Error; err != nil {
+		return ViewNoteResult{Error: "Network error."}
 	}
-	
-	// 返回该结构的注释内容
-	return ViewNoteResult{Data: notes[0].Note}
+
+	// 返回该任务的注释内容及版本号（保存时用于检测并发修改），没有记录时为空字符串
+	return ViewNoteResult{Data: note.Note, Version: note.Version}
 }
 
 type ModelInfo struct {
@@ -60,36 +63,240 @@ func GetAllModelNotMe(seq string) GetAllModelNotMeResult {
 }
 
 type UpdateNoteResult struct {
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Version  int64  `json:"version"`
+	Conflict bool   `json:"conflict,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
-// UpdateNote 更新或创建用户对某个序列的注释
-func UpdateNote(noteContent string, userId uint, sequenceId uint) UpdateNoteResult {
-	// 查询是否已有该用户对该任务的记录
-	var noteCount int64
-	if err := database.Database.Model(&models.Note{}).Where("user_id = ? AND task_id = ?", userId, sequenceId).Count(&noteCount).Error; err != nil {
+// errNoteConflict 注释已被其他请求修改
+var errNoteConflict = errors.New("note version conflict")
+
+// UpdateNote 更新或创建任务的注释，每次保存生成一条记录编辑用户的历史版本
+// 需要任务的注释权限；expectedVersion 不为空时，如果注释已被修改（版本号不一致）则拒绝写入
+func UpdateNote(noteContent string, userId uint, sequenceId uint, expectedVersion *int64) UpdateNoteResult {
+	if !UserHasTaskPermission(userId, sequenceId, SharePermissionAnnotate) {
+		return UpdateNoteResult{Error: "Task not found."}
+	}
+	version, err := saveNote(noteContent, userId, sequenceId, expectedVersion)
+	if errors.Is(err, errNoteConflict) {
+		return UpdateNoteResult{Conflict: true, Error: "The note has been modified elsewhere, please reload."}
+	}
+	if err != nil {
 		return UpdateNoteResult{Error: "Network error."}
 	}
-	
-	if noteCount > 0 {
-		// 如果有记录，执行更新操作
-		if err := database.Database.Model(&models.Note{}).
-			Where("user_id = ? AND task_id = ?", userId, sequenceId).
-			Update("note", noteContent).Error; err != nil {
-			return UpdateNoteResult{Error: "Network error."}
+	IndexNote(userId, sequenceId, noteContent)
+	return UpdateNoteResult{Message: "Update successfully!", Version: version}
+}
+
+// saveNote 在事务中写入注释并生成历史版本，返回新的版本号
+func saveNote(noteContent string, userId uint, sequenceId uint, expectedVersion *int64) (int64, error) {
+	var newVersion int64
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		var note models.Note
+		if err := tx.Where("task_id = ?", sequenceId).Find(&note).Error; err != nil {
+			return err
 		}
-	} else {
-		// 如果没有记录，创建新的注释记录
-		newNote := models.Note{
-			Note:   noteContent,
-			UserId: int64(userId),
-			TaskId: int64(sequenceId),
+		if expectedVersion != nil && *expectedVersion != note.Version {
+			return errNoteConflict
 		}
-		if err := database.Database.Create(&newNote).Error; err != nil {
-			return UpdateNoteResult{Error: "Network error."}
+
+		if note.ID == 0 {
+			// 如果没有记录，创建新的注释记录
+			note = models.Note{
+				Note:    noteContent,
+				UserId:  int64(userId),
+				TaskId:  int64(sequenceId),
+				Version: 1,
+			}
+			// task_id 唯一，两个请求同时创建时只有一个成功，另一个按并发修改处理
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&note)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errNoteConflict
+			}
+		} else {
+			// 只有版本号未变化时才更新，防止覆盖并发写入
+			result := tx.Model(&models.Note{}).
+				Where("id = ? AND version = ?", note.ID, note.Version).
+				Updates(map[string]interface{}{"note": noteContent, "version": note.Version + 1, "user_id": userId})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errNoteConflict
+			}
+			note.Version++
+		}
+
+		revision := models.NoteRevision{
+			NoteId:  note.ID,
+			TaskId:  note.TaskId,
+			UserId:  int64(userId),
+			Version: note.Version,
+			Note:    noteContent,
+		}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		newVersion = note.Version
+		return nil
+	})
+	return newVersion, err
+}
+
+type NoteRevisionItem struct {
+	ID        uint   `json:"id"`
+	Version   int64  `json:"version"`
+	UserId    int64  `json:"userId"`
+	Author    string `json:"author"`
+	Length    int    `json:"length"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type NoteRevisionListResult struct {
+	Data  []NoteRevisionItem `json:"data,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
+
+// ListNoteRevisions 查询任务注释的历史版本（按版本号倒序），包含所有协作者保存的版本
+func ListNoteRevisions(userId uint, sequenceId uint) NoteRevisionListResult {
+	if !UserCanAccessTask(userId, sequenceId) {
+		return NoteRevisionListResult{Error: "Task not found."}
+	}
+	var revisions []models.NoteRevision
+	if err := database.Database.Where("task_id = ?", sequenceId).Order("version DESC, id DESC").Find(&revisions).Error; err != nil {
+		return NoteRevisionListResult{Error: "Network error."}
+	}
+
+	// 查询作者邮箱
+	authorIds := make([]int64, 0, len(revisions))
+	for _, revision := range revisions {
+		authorIds = append(authorIds, revision.UserId)
+	}
+	authors := make(map[int64]string)
+	if len(authorIds) > 0 {
+		var users []models.User
+		if err := database.Database.Where("id IN ?", authorIds).Find(&users).Error; err == nil {
+			for _, user := range users {
+				authors[int64(user.ID)] = user.Email
+			}
 		}
 	}
-	
-	return UpdateNoteResult{Message: "Update successfully!"}
-} 
\ No newline at end of file
+
+	result := make([]NoteRevisionItem, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, NoteRevisionItem{
+			ID:        revision.ID,
+			Version:   revision.Version,
+			UserId:    revision.UserId,
+			Author:    authors[revision.UserId],
+			Length:    len([]rune(revision.Note)),
+			CreatedAt: revision.CreatedAt.UnixMilli(),
+		})
+	}
+	return NoteRevisionListResult{Data: result}
+}
+
+// NoteDiffLine 行级差异
+type NoteDiffLine struct {
+	Op   string `json:"op"` // equal, insert, delete
+	Text string `json:"text"`
+}
+
+type NoteDiffResult struct {
+	From  int64          `json:"from"`
+	To    int64          `json:"to"`
+	Lines []NoteDiffLine `json:"lines"`
+	Error string         `json:"error,omitempty"`
+}
+
+// DiffNoteRevisions 比较注释的两个历史版本
+func DiffNoteRevisions(userId uint, sequenceId uint, fromId uint, toId uint) NoteDiffResult {
+	if !UserCanAccessTask(userId, sequenceId) {
+		return NoteDiffResult{Error: "Task not found."}
+	}
+	from, err := findNoteRevision(sequenceId, fromId)
+	if err != nil {
+		return NoteDiffResult{Error: err.Error()}
+	}
+	to, err := findNoteRevision(sequenceId, toId)
+	if err != nil {
+		return NoteDiffResult{Error: err.Error()}
+	}
+	return NoteDiffResult{
+		From:  from.Version,
+		To:    to.Version,
+		Lines: diffLines(strings.Split(from.Note, "\n"), strings.Split(to.Note, "\n")),
+	}
+}
+
+// RestoreNoteRevision 将注释恢复为指定历史版本（作为一个新版本保存）
+func RestoreNoteRevision(userId uint, sequenceId uint, revisionId uint, expectedVersion *int64) UpdateNoteResult {
+	if !UserHasTaskPermission(userId, sequenceId, SharePermissionAnnotate) {
+		return UpdateNoteResult{Error: "Task not found."}
+	}
+	revision, err := findNoteRevision(sequenceId, revisionId)
+	if err != nil {
+		return UpdateNoteResult{Error: err.Error()}
+	}
+	result := UpdateNote(revision.Note, userId, sequenceId, expectedVersion)
+	if result.Error == "" {
+		result.Message = "Restored successfully!"
+	}
+	return result
+}
+
+func findNoteRevision(sequenceId uint, revisionId uint) (models.NoteRevision, error) {
+	var revision models.NoteRevision
+	if err := database.Database.Where("id = ? AND task_id = ?", revisionId, sequenceId).First(&revision).Error; err != nil {
+		return revision, errors.New("Revision not found.")
+	}
+	return revision, nil
+}
+
+// diffLines 基于最长公共子序列计算行级差异
+func diffLines(a, b []string) []NoteDiffLine {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]NoteDiffLine, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, NoteDiffLine{Op: "equal", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, NoteDiffLine{Op: "delete", Text: a[i]})
+			i++
+		default:
+			lines = append(lines, NoteDiffLine{Op: "insert", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		lines = append(lines, NoteDiffLine{Op: "delete", Text: a[i]})
+	}
+	for ; j < m; j++ {
+		lines = append(lines, NoteDiffLine{Op: "insert", Text: b[j]})
+	}
+	return lines
+}
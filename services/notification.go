@@ -0,0 +1,173 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"fmt"
+	"time"
+)
+
+// 通知类型
+const (
+	NotificationShareNew     = "share_new"
+	NotificationShareAgreed  = "share_agreed"
+	NotificationShareRefused = "share_refused"
+	NotificationShareRevoked = "share_revoked"
+	NotificationJobCompleted = "job_completed"
+	NotificationJobFailed    = "job_failed"
+	NotificationQuotaWarning = "quota_warning"
+)
+
+var (
+	// 每个用户24小时内允许创建的任务数，达到 quotaWarningRatio 时发送提醒
+	dailyTaskQuota    int64 = 50
+	quotaWarningRatio       = 0.8
+)
+
+type NotificationItem struct {
+	ID        uint   `json:"id"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	TaskId    uint   `json:"taskId"`
+	IsRead    bool   `json:"isRead"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type NotificationListResult struct {
+	List   []NotificationItem `json:"list"`
+	Total  int64              `json:"total"`
+	Unread int64              `json:"unread"`
+}
+
+// CreateNotification 创建一条通知并同步用户的未读数量
+func CreateNotification(userId uint, notifyType, title, content string, taskId uint) {
+	if userId == 0 {
+		return
+	}
+	notification := models.Notification{
+		UserId:  userId,
+		Type:    notifyType,
+		Title:   title,
+		Content: content,
+		TaskId:  taskId,
+	}
+	if err := database.Database.Create(&notification).Error; err != nil {
+		logger.Error("创建通知失败: %v", err)
+		return
+	}
+	syncNewCount(userId)
+}
+
+// NotifyJobResult 结构预测任务结束后通知所有包含该序列的任务的拥有者
+func NotifyJobResult(sequence string, tool string, jobErr error) {
+	var proteinInfo models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInfo).Error; err != nil || proteinInfo.ID == 0 {
+		return
+	}
+
+	tasks, err := FindTasksByProtein(proteinInfo)
+	if err != nil {
+		logger.Error("查找通知相关任务失败: %v", err)
+		return
+	}
+
+	for _, task := range tasks {
+		if jobErr != nil {
+			CreateNotification(uint(task.UserId), NotificationJobFailed,
+				fmt.Sprintf("%s job failed", tool),
+				fmt.Sprintf("Structure prediction for model #%d in task \"%s\" failed: %v", proteinInfo.ID, task.Title, jobErr),
+				task.ID)
+		} else {
+			CreateNotification(uint(task.UserId), NotificationJobCompleted,
+				fmt.Sprintf("%s job completed", tool),
+				fmt.Sprintf("Model #%d in task \"%s\" is ready.", proteinInfo.ID, task.Title),
+				task.ID)
+		}
+	}
+}
+
+// CheckTaskQuota 用户最近24小时创建的任务接近配额时发送提醒（每24小时最多一次）
+func CheckTaskQuota(userId uint) {
+	since := time.Now().Add(-24 * time.Hour)
+
+	var taskCount int64
+	if err := database.Database.Model(&models.Task{}).Where("user_id = ? AND created_at > ?", userId, since).Count(&taskCount).Error; err != nil {
+		logger.Error("统计用户任务数量失败: %v", err)
+		return
+	}
+	if float64(taskCount) < float64(dailyTaskQuota)*quotaWarningRatio {
+		return
+	}
+
+	var warned int64
+	if err := database.Database.Model(&models.Notification{}).
+		Where("user_id = ? AND type = ? AND created_at > ?", userId, NotificationQuotaWarning, since).
+		Count(&warned).Error; err != nil || warned > 0 {
+		return
+	}
+
+	CreateNotification(userId, NotificationQuotaWarning, "Task quota warning",
+		fmt.Sprintf("You have created %d of %d tasks allowed in the last 24 hours.", taskCount, dailyTaskQuota), 0)
+}
+
+// ListNotifications 分页查询用户的通知
+func ListNotifications(userId uint, current, pageSize int, unreadOnly bool) (NotificationListResult, error) {
+	var notifications []models.Notification
+	var total, unread int64
+
+	db := database.Database.Model(&models.Notification{}).Where("user_id = ?", userId)
+	if unreadOnly {
+		db = db.Where("is_read = ?", false)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return NotificationListResult{}, err
+	}
+	if err := db.Order("created_at DESC").Offset((current - 1) * pageSize).Limit(pageSize).Find(&notifications).Error; err != nil {
+		return NotificationListResult{}, err
+	}
+	if err := database.Database.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userId, false).Count(&unread).Error; err != nil {
+		return NotificationListResult{}, err
+	}
+
+	list := make([]NotificationItem, 0, len(notifications))
+	for _, n := range notifications {
+		list = append(list, NotificationItem{
+			ID:        n.ID,
+			Type:      n.Type,
+			Title:     n.Title,
+			Content:   n.Content,
+			TaskId:    n.TaskId,
+			IsRead:    n.IsRead,
+			CreatedAt: n.CreatedAt.UnixMilli(),
+		})
+	}
+
+	return NotificationListResult{List: list, Total: total, Unread: unread}, nil
+}
+
+// MarkNotificationsRead 将指定通知标记为已读，ids 为空时标记全部
+func MarkNotificationsRead(userId uint, ids []uint) error {
+	db := database.Database.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userId, false)
+	if len(ids) > 0 {
+		db = db.Where("id IN ?", ids)
+	}
+	if err := db.Update("is_read", true).Error; err != nil {
+		return err
+	}
+	syncNewCount(userId)
+	return nil
+}
+
+// syncNewCount 根据未读通知数量更新 User.NewCount
+func syncNewCount(userId uint) {
+	var unread int64
+	if err := database.Database.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userId, false).Count(&unread).Error; err != nil {
+		logger.Error("统计未读通知失败: %v", err)
+		return
+	}
+	if err := database.Database.Model(&models.User{}).Where("id = ?", userId).Update("new_count", unread).Error; err != nil {
+		logger.Error("更新用户未读数量失败: %v", err)
+	}
+}
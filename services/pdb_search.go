@@ -334,8 +334,11 @@ func GetSeqTimeTable() GetSeqTimeTableResult {
 	var result []SeqTimeTableItem
 
 	for _, item := range proteinInformations {
-		// 计算处理时间（毫秒）
+		// 计算处理时间（毫秒），有记录的预测耗时时直接使用
 		durationMs := item.UpdatedAt.UnixMilli() - item.CreatedAt.UnixMilli()
+		if item.Predictor != "" && item.Duration > 0 {
+			durationMs = int64(item.Duration * 1000)
+		}
 
 		// 过滤掉处理时间超过100小时的记录
 		if durationMs/(1000*60*60) >= 100 {
@@ -345,11 +348,11 @@ func GetSeqTimeTable() GetSeqTimeTableResult {
 		// 格式化持续时间
 		duration := formatDuring(durationMs)
 
-		// 根据处理时间判断类型
-		var seqType string
-		if durationMs/(1000*60*60) > 2 {
+		// 记录了预测工具时直接使用，旧记录根据处理时间判断类型
+		seqType := item.Predictor
+		if seqType == "" && durationMs/(1000*60*60) > 2 {
 			seqType = "itasser"
-		} else {
+		} else if seqType == "" {
 			seqType = "alpha"
 		}
 
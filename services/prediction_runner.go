@@ -0,0 +1,193 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runPrediction 在独立的工作目录中执行预测工具，并完成模型的后处理
+func runPrediction(tool queueTool, id uint, sequence string) error {
+	if err := tool.predictor.Validate(sequence); err != nil {
+		return err
+	}
+
+	// 记录开始时间
+	startTime := time.Now()
+	logger.Info("%s任务 ID %d 开始处理，序列长度: %d", tool.Name, id, len(sequence))
+	if err := advanceQueueJob(tool.newModel(), tool.Name, id, sequence, JobStatusRunning); err != nil {
+		return err
+	}
+
+	// 每个任务使用独立的工作目录，多个任务可以并行执行
+	job := newPredictionJob(tool, id, sequence)
+	if err := prepareJobWorkspace(job.Workspace); err != nil {
+		return err
+	}
+	if err := writeFastaFile(job.FastaPath, "query", sequence); err != nil {
+		return fmt.Errorf("创建FASTA文件失败: %v", err)
+	}
+
+	// 登记可取消的任务，取消时结束 job 的 context
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job.ctx = ctx
+	finish := trackRunningJob(tool.Name, id, cancel)
+	if !queueJobActive(tool.newModel(), id) {
+		// 登记前记录可能已被取消
+		cancelRunningJob(tool.Name, id)
+	}
+
+	// 预测命令的输出实时写入任务日志
+	if jobLog, err := openJobLog(tool.Key, id); err != nil {
+		logger.Error("打开%s任务 ID %d 的日志失败: %v", tool.Name, id, err)
+	} else {
+		defer jobLog.Close()
+		job.log = jobLog
+		jobLog.Printf("%s任务开始执行，序列长度: %d", tool.Name, len(sequence))
+	}
+
+	err := tool.predictor.Run(job)
+	if finish() {
+		err = errJobCancelled
+	}
+	if jobLog, ok := job.log.(*jobLogWriter); ok {
+		if err != nil {
+			jobLog.Printf("执行结束: %v", err)
+		} else {
+			jobLog.Printf("执行完成，耗时: %.2f秒", time.Since(startTime).Seconds())
+		}
+	}
+	if errors.Is(err, errJobCancelled) || errors.Is(err, errJobTimeout) {
+		// 任务已取消或超时，清理工作目录
+		logger.Info("%s任务 ID %d 已终止，清理工作目录: %v", tool.Name, id, err)
+		removeJobWorkspace(job.Workspace)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// 计算处理时间
+	duration := time.Since(startTime)
+	logger.Info("%s任务 ID %d 执行完成，耗时: %.2f秒", tool.Name, id, duration.Seconds())
+
+	if err := collectPrediction(tool, job, duration); err != nil {
+		return fmt.Errorf("处理结果失败: %w", err)
+	}
+	return nil
+}
+
+// collectPrediction 把预测工具生成的模型保存到 static/models，并计算参数、更新相关任务
+func collectPrediction(tool queueTool, job PredictionJob, duration time.Duration) error {
+	// 进入后处理阶段
+	if err := advanceQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, JobStatusPostprocessing); err != nil {
+		return err
+	}
+
+	modelPath, err := tool.predictor.Collect(job)
+	if err != nil {
+		return err
+	}
+	return finishPrediction(tool, job.Sequence, modelPath, job.Workspace, duration)
+}
+
+// finishPrediction 后处理已生成的模型文件：保存处理时间、移动模型、删除工作目录，并计算参数、更新相关任务
+func finishPrediction(tool queueTool, sequence string, modelPath string, workspace string, duration time.Duration) error {
+	var proteinInformation models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInformation).Error; err != nil {
+		return fmt.Errorf("find protein information failed: %v", err)
+	}
+	if proteinInformation.ID == 0 {
+		return fmt.Errorf("protein information not found")
+	}
+
+	// Move the generated file to the static folder
+	// 先保存模型再记录预测工具，中断恢复时按预测工具判断模型是否已生成
+	if err := moveModelFile(modelPath, proteinInformation.ID, tool.Key); err != nil {
+		return fmt.Errorf("failed to move file: %v", err)
+	}
+	removeJobWorkspace(workspace)
+
+	// 保存处理时间和预测工具到数据库，并更新该工具的耗时估计
+	durationSeconds := duration.Seconds()
+	if err := database.Database.Model(&models.ProteinInformation{}).Where("id = ?", proteinInformation.ID).
+		Updates(map[string]interface{}{"duration": durationSeconds, "predictor": tool.Key}).Error; err != nil {
+		logger.Error("保存处理时间失败: %v", err)
+	} else {
+		logger.Info("已保存%s任务处理时间: %.2f秒", tool.Name, durationSeconds)
+		refreshDurationModel(tool.Key)
+	}
+
+	// 计算参数、生成Ramachandran图、保存RCSB PDB结构数量并更新相关主任务的ModelId
+	runProteinStages(proteinInformation)
+
+	return nil
+}
+
+// hasPartialResult 判断工作目录中是否有该序列已生成但未处理的模型
+func hasPartialResult(tool queueTool, id uint, sequence string) bool {
+	job := newPredictionJob(tool, id, sequence)
+	if !fastaMatches(job.FastaPath, sequence) {
+		return false
+	}
+	_, err := tool.predictor.Collect(job)
+	return err == nil
+}
+
+// predictorModelPath 返回预测工具为蛋白质生成的模型文件，每个预测工具的模型分别保存
+func predictorModelPath(id uint, toolKey string) string {
+	return filepath.Join("static", "models", fmt.Sprintf("%d_%s.pdb", id, toolKey))
+}
+
+// predictorModelExists 判断蛋白质是否已有该预测工具生成的模型
+// 按预测工具保存之前生成的模型只有 {id}.pdb，按 Predictor 列判断来源
+func predictorModelExists(info models.ProteinInformation, toolKey string) bool {
+	if _, err := os.Stat(predictorModelPath(info.ID, toolKey)); err == nil {
+		return true
+	}
+	return info.Predictor == toolKey && proteinModelExists(info.ID)
+}
+
+// moveModelFile 把模型保存为 static/models/{id}_{tool}.pdb，并复制为 {id}.pdb 作为蛋白质当前使用的模型
+func moveModelFile(sourcePath string, id uint, toolKey string) error {
+	// Define destination paths
+	destDir := filepath.Join("static", "models")
+	toolPath := predictorModelPath(id, toolKey)
+
+	// 确保目标目录存在
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	// Move the file
+	if err := os.Rename(sourcePath, toolPath); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	return useModelFile(id, toolKey)
+}
+
+// useModelFile 把预测工具生成的模型复制为 static/models/{id}.pdb，作为蛋白质当前使用的模型
+func useModelFile(id uint, toolKey string) error {
+	toolPath := predictorModelPath(id, toolKey)
+	destPath := filepath.Join("static", "models", fmt.Sprintf("%d.pdb", id))
+	// 先写入临时文件再替换，避免读取到不完整的模型
+	content, err := os.ReadFile(toolPath)
+	if err != nil {
+		return fmt.Errorf("failed to read model: %w", err)
+	}
+	tmpPath := destPath + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to copy model: %w", err)
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return fmt.Errorf("failed to copy model: %w", err)
+	}
+	return nil
+}
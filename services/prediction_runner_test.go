@@ -0,0 +1,97 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestFakePredictionCompletesTask 用假预测工具执行 fold 任务：排队、认领、预测、后处理，最后任务完成
+func TestFakePredictionCompletesTask(t *testing.T) {
+	db := openTestDatabase(t)
+	tool, ok := queueTools["fake"]
+	if !ok {
+		t.Fatal("FAKE_PREDICTOR=true 时应注册假预测工具")
+	}
+
+	stages := stubProteinStages(t)
+
+	user := models.User{Email: "fold@example.com", Password: "secret", QueuePriority: 3}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	sequence := "MKTAYIAKQRQISFVKSHFSRQ"
+	response := Fold([]string{sequence}, "fake fold", "fake", int64(user.ID))
+	if response.Error != "" {
+		t.Fatalf("Fold: %s", response.Error)
+	}
+
+	var task models.Task
+	if err := db.Where("user_id = ?", user.ID).First(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status := RefreshTaskStatus(task); status != TaskStatusPending {
+		t.Fatalf("排队后任务状态为 %s，want %s", status, TaskStatusPending)
+	}
+
+	// 新建的队列记录属于提交任务的用户，并使用该用户的默认优先级
+	var job models.PredictionQueue
+	if err := db.Where("predictor = ? AND sequence = ?", "fake", sequence).First(&job).Error; err != nil {
+		t.Fatalf("队列中没有假预测工具的记录: %v", err)
+	}
+	if job.Status != JobStatusPending || job.UserId != user.ID || job.Priority != user.QueuePriority {
+		t.Fatalf("队列记录 = status %s user %d priority %d", job.Status, job.UserId, job.Priority)
+	}
+
+	id, ok := claimNextJob(tool, "test")
+	if !ok || id != job.ID {
+		t.Fatalf("claimNextJob = %d, %v, want %d", id, ok, job.ID)
+	}
+	err := runPrediction(tool, id, sequence)
+	completeQueueJob(tool.newModel(), tool.Name, id, sequence, 1, err)
+	if err != nil {
+		t.Fatalf("runPrediction: %v", err)
+	}
+
+	if err := db.First(&job, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobStatusSucceeded {
+		t.Errorf("队列记录状态为 %s，want %s", job.Status, JobStatusSucceeded)
+	}
+
+	// 模型按预测工具保存，并复制为蛋白质当前使用的模型
+	var proteinInfo models.ProteinInformation
+	if err := db.Where("sequence = ?", sequence).First(&proteinInfo).Error; err != nil {
+		t.Fatal(err)
+	}
+	if proteinInfo.Predictor != "fake" || proteinInfo.Duration <= 0 {
+		t.Errorf("蛋白质信息 predictor = %q duration = %v", proteinInfo.Predictor, proteinInfo.Duration)
+	}
+	model, err := os.ReadFile(predictorModelPath(proteinInfo.ID, "fake"))
+	if err != nil {
+		t.Fatalf("没有保存假预测工具的模型: %v", err)
+	}
+	// 每个残基 4 个主链原子
+	if got := strings.Count(string(model), "\nATOM "); got != 4*len(sequence) {
+		t.Errorf("模型中有 %d 个原子，want %d", got, 4*len(sequence))
+	}
+	if !predictorModelExists(proteinInfo, "fake") || !proteinModelExists(proteinInfo.ID) {
+		t.Error("模型文件不存在")
+	}
+	if want := []string{StageParameters, StageRamachandran, StageStructureNum}; strings.Join(*stages, ",") != strings.Join(want, ",") {
+		t.Errorf("执行的阶段 = %v, want %v", *stages, want)
+	}
+
+	if err := db.First(&task, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != TaskStatusCompleted {
+		t.Errorf("任务状态为 %s，want %s", task.Status, TaskStatusCompleted)
+	}
+	if task.ModelId != fmt.Sprintf("%d", proteinInfo.ID) {
+		t.Errorf("任务 ModelId = %q, want %d", task.ModelId, proteinInfo.ID)
+	}
+}
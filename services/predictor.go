@@ -0,0 +1,251 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gorm.io/gorm"
+)
+
+// PredictorInfo 预测工具的元数据
+type PredictorInfo struct {
+	Key       string `json:"key"`       // 请求中使用的名称，例如 "alpha"
+	Name      string `json:"name"`      // 显示名称，例如 "AlphaFold"
+	Type      int64  `json:"type"`      // 保存在任务 StructurePredictionTool 中的编号
+	Kind      string `json:"kind"`      // "local"（本机进程）、"remote"（远程 API）或 "fake"（测试用）
+	MaxLength int    `json:"maxLength"` // 序列长度上限，0 表示不限制
+	Workers   int    `json:"workers"`   // 本实例的并行数
+	Version   string `json:"version"`   // 版本，记录在任务历史中，来自 {PREFIX}_VERSION 或配置
+}
+
+// PredictionJob 交给预测工具执行的一条队列记录
+type PredictionJob struct {
+	ID        uint
+	Sequence  string
+	Workspace string // 独立的工作目录，预测工具的输入和输出都放在其中
+	FastaPath string // 工作目录中的输入序列文件
+
+	ctx  context.Context
+	tool string
+	log  io.Writer // 不为 nil 时实时写入预测命令的输出：本实例写入任务日志，远程 worker 上传到服务器
+}
+
+// Context 任务被取消时结束
+func (j PredictionJob) Context() context.Context {
+	if j.ctx == nil {
+		return context.Background()
+	}
+	return j.ctx
+}
+
+// RunCommand 在独立进程组中执行预测命令，按序列长度计算超时时间并应用资源限制
+func (j PredictionJob) RunCommand(cmd *exec.Cmd, limits predictorLimits) error {
+	output, err := runJobCommand(j.Context(), j.tool, j.ID, cmd, limits, limits.timeoutFor(len(j.Sequence)), j.log)
+	if err == nil || errors.Is(err, errJobCancelled) || errors.Is(err, errJobTimeout) {
+		return err
+	}
+	logger.Error("执行%s失败: %v, 输出: %s", j.tool, err, output)
+	return commandJobError(j.tool, err, output)
+}
+
+// predictorVersion 读取环境变量 {prefix}_VERSION 中的预测工具版本
+func predictorVersion(prefix string) string {
+	return os.Getenv(prefix + "_VERSION")
+}
+
+// Predictor 结构预测工具
+type Predictor interface {
+	// Info 返回预测工具的元数据
+	Info() PredictorInfo
+	// Validate 检查序列是否可以用该工具预测
+	Validate(sequence string) error
+	// Run 执行预测，输出写入 job.Workspace；任务被取消时应尽快返回 errJobCancelled
+	Run(job PredictionJob) error
+	// Collect 返回工作目录中生成的模型文件，尚未生成时返回错误
+	Collect(job PredictionJob) (string, error)
+}
+
+// queueTool 已注册的预测工具及其队列
+type queueTool struct {
+	Key       string
+	Name      string
+	predictor Predictor
+	newModel  func() interface{}
+	shared    bool // 记录保存在共用的 prediction_queues 表中，按 predictor 列区分
+	enqueue   func(sequence string, parentId *int64, userId uint)
+}
+
+// query 返回该预测工具队列记录的查询
+func (t queueTool) query() *gorm.DB {
+	db := database.Database.Model(t.newModel())
+	if t.shared {
+		db = db.Where("predictor = ?", t.Key)
+	}
+	return db
+}
+
+// queueTools 按请求中的预测工具名称查找已注册的预测工具
+var queueTools = make(map[string]queueTool)
+
+// predictorKeys 预测工具的注册顺序
+var predictorKeys []string
+
+func init() {
+	// 内置预测工具使用各自的队列表
+	registerPredictor(newAlphaFoldPredictor(), func() interface{} { return &models.AlphaFoldQueue{} }, AddToAlphaFoldQueueWithParent)
+	registerPredictor(newItasserPredictor(), func() interface{} { return &models.ITasserQueue{} }, AddToITasserQueueWithParent)
+	registerPredictor(newESMFoldPredictor(), func() interface{} { return &models.ESMQueue{} }, AddToESMQueueWithParent)
+
+	// 通过配置添加的预测工具和测试用的假预测工具共用 prediction_queues 表
+	for _, predictor := range loadConfiguredPredictors() {
+		registerSharedPredictor(predictor)
+	}
+	if fake, ok := newFakePredictorFromEnv(); ok {
+		registerSharedPredictor(fake)
+	}
+}
+
+// registerSharedPredictor 注册使用共用队列表的预测工具
+func registerSharedPredictor(predictor Predictor) {
+	key := predictor.Info().Key
+	registerPredictor(predictor, func() interface{} { return &models.PredictionQueue{} }, func(sequence string, parentId *int64, userId uint) {
+		addToPredictionQueue(key, sequence, parentId, userId)
+	})
+}
+
+// registerPredictor 注册预测工具，名称或编号与已注册的工具重复时忽略
+func registerPredictor(predictor Predictor, newModel func() interface{}, enqueue func(sequence string, parentId *int64, userId uint)) {
+	info := predictor.Info()
+	if info.Key == "" || info.Name == "" || info.Type <= 0 {
+		logger.Error("预测工具 %q 缺少名称或编号，已忽略", info.Key)
+		return
+	}
+	for _, tool := range queueTools {
+		existing := tool.predictor.Info()
+		if existing.Key == info.Key || existing.Name == info.Name || existing.Type == info.Type {
+			logger.Error("预测工具 %s 与已注册的 %s 重复，已忽略", info.Key, existing.Key)
+			return
+		}
+	}
+
+	_, shared := newModel().(*models.PredictionQueue)
+	queueTools[info.Key] = queueTool{
+		Key:       info.Key,
+		Name:      info.Name,
+		predictor: predictor,
+		newModel:  newModel,
+		shared:    shared,
+		enqueue:   enqueue,
+	}
+	predictorKeys = append(predictorKeys, info.Key)
+}
+
+// registeredQueueTools 按注册顺序返回所有预测工具
+func registeredQueueTools() []queueTool {
+	tools := make([]queueTool, 0, len(predictorKeys))
+	for _, key := range predictorKeys {
+		tools = append(tools, queueTools[key])
+	}
+	return tools
+}
+
+// queueToolByType 按任务中保存的编号查找预测工具
+func queueToolByType(typeValue int64) (queueTool, bool) {
+	for _, tool := range queueTools {
+		if tool.predictor.Info().Type == typeValue {
+			return tool, true
+		}
+	}
+	return queueTool{}, false
+}
+
+// Predictors 返回所有已注册预测工具的元数据
+func Predictors() []PredictorInfo {
+	infos := make([]PredictorInfo, 0, len(predictorKeys))
+	for _, tool := range registeredQueueTools() {
+		infos = append(infos, tool.predictor.Info())
+	}
+	return infos
+}
+
+// ValidatePredictorInput 检查序列是否可以用指定编号的预测工具预测
+func ValidatePredictorInput(typeValue int64, sequence string) error {
+	tool, ok := queueToolByType(typeValue)
+	if !ok {
+		return errors.New("Invalid type.")
+	}
+	return tool.predictor.Validate(sequence)
+}
+
+// validateSequence 检查序列只包含标准氨基酸且不超过长度上限
+func validateSequence(sequence string, maxLength int) error {
+	if sequence == "" || !IsFasta(sequence) {
+		return errors.New("Invalid sequence.")
+	}
+	if maxLength > 0 && len(sequence) > maxLength {
+		return fmt.Errorf("Sequence is longer than %d residues.", maxLength)
+	}
+	return nil
+}
+
+// newPredictionJob 创建队列记录对应的预测任务
+func newPredictionJob(tool queueTool, id uint, sequence string) PredictionJob {
+	workspace := jobWorkspace(tool.Key, id)
+	return PredictionJob{
+		ID:        id,
+		Sequence:  sequence,
+		Workspace: workspace,
+		FastaPath: filepath.Join(workspace, "query.fasta"),
+		tool:      tool.Name,
+	}
+}
+
+// writeFastaFile 写入带头部的单条序列 FASTA 文件
+func writeFastaFile(path string, name string, sequence string) error {
+	if err := os.WriteFile(path, []byte(">"+name+"\n"+sequence+"\n"), 0644); err != nil {
+		return fmt.Errorf("write fasta file failed: %v", err)
+	}
+	return nil
+}
+
+// existingFile 文件存在时返回其路径
+func existingFile(path string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("模型文件不存在: %s", path)
+	}
+	return path, nil
+}
+
+// addToPredictionQueue 把序列加入共用队列表，同一预测工具的同一序列只排队一次
+func addToPredictionQueue(key string, sequence string, parentId *int64, userId uint) {
+	var queue models.PredictionQueue
+	if err := database.Database.Where("predictor = ? AND sequence = ?", key, sequence).Find(&queue).Error; err != nil {
+		logger.Error("查询%s队列失败: %v", key, err)
+		return
+	}
+	if queue.ID != 0 {
+		logger.Info("序列已存在于%s队列中，跳过添加，ID: %d", key, queue.ID)
+		return
+	}
+
+	queue = models.PredictionQueue{
+		Predictor: key,
+		Sequence:  sequence,
+		ParentId:  parentId,
+		QueueJob:  newQueueJob(userId),
+	}
+	if err := database.Database.Create(&queue).Error; err != nil {
+		logger.Error("创建%s队列失败: %v", key, err)
+		return
+	}
+	logger.Info("已添加序列到%s队列，ID: %d", key, queue.ID)
+	WakeQueueScheduler()
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// predictorConfig 配置文件中的一个外部预测工具
+// 参数中的 {fasta}、{workspace}、{sequence} 在运行时替换为输入文件、工作目录和序列
+type predictorConfig struct {
+	Key            string   `json:"key"`            // 请求中使用的名称，例如 "colabfold"
+	Name           string   `json:"name"`           // 显示名称，例如 "ColabFold"
+	Type           int64    `json:"type"`           // 保存在任务中的编号，不能与其他工具重复
+	Command        string   `json:"command"`        // 可执行文件
+	Args           []string `json:"args"`           // 命令参数
+	Output         string   `json:"output"`         // 生成的模型文件，相对工作目录的 glob 模式，例如 "output/*_rank_001*.pdb"
+	Workers        *int     `json:"workers"`        // 本实例的并行数，默认 1，可被 {KEY}_WORKERS 覆盖；0 表示只由远程 worker 执行
+	MaxLength      int      `json:"maxLength"`      // 序列长度上限，0 表示不限制
+	TimeoutMinutes int      `json:"timeoutMinutes"` // 基础超时时间，可被 {KEY}_TIMEOUT_MINUTES 等环境变量覆盖
+	Version        string   `json:"version"`        // 版本，记录在任务历史中，可被 {KEY}_VERSION 覆盖
+}
+
+// loadConfiguredPredictors 读取 PREDICTORS_CONFIG 指向的 JSON 文件中配置的预测工具
+func loadConfiguredPredictors() []Predictor {
+	path := os.Getenv("PREDICTORS_CONFIG")
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		logger.Error("读取预测工具配置 %s 失败: %v", path, err)
+		return nil
+	}
+	var configs []predictorConfig
+	if err := json.Unmarshal(content, &configs); err != nil {
+		logger.Error("解析预测工具配置 %s 失败: %v", path, err)
+		return nil
+	}
+
+	var predictors []Predictor
+	for _, config := range configs {
+		if config.Key == "" || config.Command == "" || config.Output == "" {
+			logger.Error("预测工具配置 %q 缺少 key、command 或 output，已忽略", config.Key)
+			continue
+		}
+		if config.Name == "" {
+			config.Name = config.Key
+		}
+		workers := 1
+		if config.Workers != nil && *config.Workers >= 0 {
+			workers = *config.Workers
+		}
+		limits := loadPredictorLimits(strings.ToUpper(config.Key), predictorLimits{
+			Timeout: time.Duration(config.TimeoutMinutes) * time.Minute,
+		})
+		predictors = append(predictors, &commandPredictor{
+			config:  config,
+			limits:  limits,
+			workers: workerCount(strings.ToUpper(config.Key)+"_WORKERS", workers),
+		})
+		logger.Info("已加载预测工具配置: %s（%s）", config.Name, config.Key)
+	}
+	return predictors
+}
+
+// commandPredictor 按配置在本机运行外部预测命令
+type commandPredictor struct {
+	config  predictorConfig
+	limits  predictorLimits
+	workers int
+}
+
+func (p *commandPredictor) Info() PredictorInfo {
+	return PredictorInfo{
+		Key:       p.config.Key,
+		Name:      p.config.Name,
+		Type:      p.config.Type,
+		Kind:      "local",
+		MaxLength: p.config.MaxLength,
+		Workers:   p.workers,
+		Version:   p.version(),
+	}
+}
+
+// version 环境变量 {KEY}_VERSION 优先于配置中的版本
+func (p *commandPredictor) version() string {
+	if version := predictorVersion(strings.ToUpper(p.config.Key)); version != "" {
+		return version
+	}
+	return p.config.Version
+}
+
+func (p *commandPredictor) Validate(sequence string) error {
+	return validateSequence(sequence, p.config.MaxLength)
+}
+
+func (p *commandPredictor) Run(job PredictionJob) error {
+	replacer := strings.NewReplacer("{fasta}", job.FastaPath, "{workspace}", job.Workspace, "{sequence}", job.Sequence)
+	args := make([]string, len(p.config.Args))
+	for i, arg := range p.config.Args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	logger.Info("开始执行%s命令，工作目录: %s", p.config.Name, job.Workspace)
+	cmd := p.limits.command(p.config.Command, args...)
+	cmd.Dir = job.Workspace
+	return job.RunCommand(cmd, p.limits)
+}
+
+// Collect 有多个文件匹配时取文件名排序后的第一个
+func (p *commandPredictor) Collect(job PredictionJob) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(job.Workspace, p.config.Output))
+	if err != nil {
+		return "", fmt.Errorf("无效的输出文件模式 %s: %v", p.config.Output, err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("模型文件不存在: %s", p.config.Output)
+	}
+	sort.Strings(matches)
+	return matches[0], nil
+}
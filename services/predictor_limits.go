@@ -0,0 +1,147 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// errJobTimeout 预测进程超过允许的运行时间
+var errJobTimeout = errors.New("job timed out")
+
+// predictorLimits 外部预测进程的运行时间和资源限制
+type predictorLimits struct {
+	Timeout           time.Duration // 基础超时时间
+	PerResidueTimeout time.Duration // 每个残基增加的超时时间
+	MaxTimeout        time.Duration // 按长度放大后的上限，0 表示不设上限
+	CPUSeconds        uint64        // 进程 CPU 时间上限（RLIMIT_CPU），0 表示不限制
+	MemoryBytes       uint64        // 进程地址空间上限（RLIMIT_AS），0 表示不限制
+	SystemdRun        bool          // 是否通过 systemd-run 放入独立的 cgroup
+	CPUQuota          string        // cgroup CPU 配额，例如 "400%"
+}
+
+var (
+	alphaFoldLimits = loadPredictorLimits("ALPHAFOLD", predictorLimits{
+		Timeout:           6 * time.Hour,
+		PerResidueTimeout: 30 * time.Second,
+		MaxTimeout:        48 * time.Hour,
+	})
+	itasserLimits = loadPredictorLimits("ITASSER", predictorLimits{
+		Timeout:           4 * time.Hour,
+		PerResidueTimeout: 10 * time.Second,
+		MaxTimeout:        24 * time.Hour,
+	})
+)
+
+// loadPredictorLimits 从环境变量读取限制，未设置的项使用默认值：
+// {PREFIX}_TIMEOUT_MINUTES、{PREFIX}_TIMEOUT_PER_RESIDUE_SECONDS、{PREFIX}_MAX_TIMEOUT_MINUTES、
+// {PREFIX}_CPU_SECONDS、{PREFIX}_MEMORY_MB、{PREFIX}_SYSTEMD_RUN、{PREFIX}_CPU_QUOTA
+func loadPredictorLimits(prefix string, limits predictorLimits) predictorLimits {
+	if value, ok := envInt(prefix + "_TIMEOUT_MINUTES"); ok {
+		limits.Timeout = time.Duration(value) * time.Minute
+	}
+	if value, ok := envInt(prefix + "_TIMEOUT_PER_RESIDUE_SECONDS"); ok {
+		limits.PerResidueTimeout = time.Duration(value) * time.Second
+	}
+	if value, ok := envInt(prefix + "_MAX_TIMEOUT_MINUTES"); ok {
+		limits.MaxTimeout = time.Duration(value) * time.Minute
+	}
+	if value, ok := envInt(prefix + "_CPU_SECONDS"); ok {
+		limits.CPUSeconds = uint64(value)
+	}
+	if value, ok := envInt(prefix + "_MEMORY_MB"); ok {
+		limits.MemoryBytes = uint64(value) * 1024 * 1024
+	}
+	if value, err := strconv.ParseBool(os.Getenv(prefix + "_SYSTEMD_RUN")); err == nil {
+		limits.SystemdRun = value
+	}
+	if value := os.Getenv(prefix + "_CPU_QUOTA"); value != "" {
+		limits.CPUQuota = value
+	}
+	return limits
+}
+
+// envInt 读取非负整数环境变量
+func envInt(name string) (int64, bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, false
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 0 {
+		logger.Warn("环境变量 %s 的值无效: %s", name, value)
+		return 0, false
+	}
+	return number, true
+}
+
+// timeoutFor 按序列长度计算运行超时时间，0 表示不限制
+func (l predictorLimits) timeoutFor(length int) time.Duration {
+	if l.Timeout == 0 {
+		return 0
+	}
+	timeout := l.Timeout + time.Duration(length)*l.PerResidueTimeout
+	if l.MaxTimeout > 0 && timeout > l.MaxTimeout {
+		timeout = l.MaxTimeout
+	}
+	return timeout
+}
+
+// command 创建预测命令，开启 SystemdRun 且系统中存在 systemd-run 时在独立的 cgroup 中限制内存和 CPU
+// 需要进程资源限制时通过 prlimit 启动，限制在 exec 之前生效，之后创建的子进程都会继承
+func (l predictorLimits) command(name string, args ...string) *exec.Cmd {
+	if prlimit, ok := l.prlimitPath(); ok {
+		wrapped := []string{}
+		if l.CPUSeconds > 0 {
+			wrapped = append(wrapped, fmt.Sprintf("--cpu=%d", l.CPUSeconds))
+		}
+		if l.memoryRlimit() {
+			wrapped = append(wrapped, fmt.Sprintf("--as=%d", l.MemoryBytes))
+		}
+		args = append(append(wrapped, "--", name), args...)
+		name = prlimit
+	}
+	if l.SystemdRun {
+		if systemdRun, ok := l.systemdRunPath(); ok {
+			wrapped := []string{"--scope", "--quiet", "--collect"}
+			if l.MemoryBytes > 0 {
+				wrapped = append(wrapped, "-p", fmt.Sprintf("MemoryMax=%d", l.MemoryBytes))
+			}
+			if l.CPUQuota != "" {
+				wrapped = append(wrapped, "-p", "CPUQuota="+l.CPUQuota)
+			}
+			wrapped = append(wrapped, "--", name)
+			return exec.Command(systemdRun, append(wrapped, args...)...)
+		}
+		logger.Warn("未找到systemd-run，%s 将只使用进程资源限制", name)
+	}
+	return exec.Command(name, args...)
+}
+
+// memoryRlimit 是否需要用 RLIMIT_AS 限制内存，使用 systemd-run 时内存由 cgroup 限制
+func (l predictorLimits) memoryRlimit() bool {
+	_, cgroup := l.systemdRunPath()
+	return l.MemoryBytes > 0 && !cgroup
+}
+
+// prlimitPath 需要进程资源限制时查找 prlimit（util-linux），找不到时在进程启动后再设置限制
+func (l predictorLimits) prlimitPath() (string, bool) {
+	if l.CPUSeconds == 0 && !l.memoryRlimit() {
+		return "", false
+	}
+	path, err := exec.LookPath("prlimit")
+	return path, err == nil
+}
+
+// systemdRunPath 开启 SystemdRun 时查找 systemd-run
+func (l predictorLimits) systemdRunPath() (string, bool) {
+	if !l.SystemdRun {
+		return "", false
+	}
+	path, err := exec.LookPath("systemd-run")
+	return path, err == nil
+}
@@ -7,12 +7,13 @@ import (
 )
 
 // Protein Information
-func ProteinInformation(sequence string, blastinformation string, structurePredictionTool int64) {
-	ProteinInformationWithParent(sequence, blastinformation, structurePredictionTool, 0)
+// userId 为提交任务的用户，新建的队列记录归属该用户
+func ProteinInformation(sequence string, blastinformation string, structurePredictionTool int64, userId uint) {
+	ProteinInformationWithParent(sequence, blastinformation, structurePredictionTool, 0, userId)
 }
 
 // Protein Information with parent ID
-func ProteinInformationWithParent(sequence string, blastinformation string, structurePredictionTool int64, parentId uint) {
+func ProteinInformationWithParent(sequence string, blastinformation string, structurePredictionTool int64, parentId uint, userId uint) {
 	var proteinInformation models.ProteinInformation
 	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInformation).Error; err != nil {
 		return
@@ -36,18 +37,8 @@ func ProteinInformationWithParent(sequence string, blastinformation string, stru
 			parentIdPtr = &parentIdInt64
 		}
 
-		if structurePredictionTool == 1 {
-			// AlphaFold2
-			AddToAlphaFoldQueueWithParent(sequence, parentIdPtr)
-		}
-		if structurePredictionTool == 2 {
-			// I-Tasser
-			AddToITasserQueueWithParent(sequence, parentIdPtr)
-		}
-		if structurePredictionTool == 3 {
-			// ESMFold
-			ESMFold(sequence)
-		}
+		// 按预测工具添加到队列
+		addSequenceToQueueWithParent(sequence, structurePredictionTool, parentIdPtr, userId)
 
 	}
 }
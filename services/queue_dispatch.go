@@ -0,0 +1,59 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 认领下一条待处理记录时，被其他实例抢先后的最大重试次数
+const maxClaimAttempts = 5
+
+// WakeQueueScheduler 通知本实例的队列调度器立即检查队列，不阻塞调用方
+// 其他实例写入的队列记录仍由调度器的定时轮询兜底
+func WakeQueueScheduler() {
+	if globalQueueScheduler == nil {
+		return
+	}
+	select {
+	case globalQueueScheduler.wakeChan <- struct{}{}:
+	default:
+		// 已有未处理的唤醒信号
+	}
+}
+
+// claimQueueRow 用带状态条件的更新认领队列记录，只有把 pending 改为 claimed 的实例认领成功
+// owner 写入租约，为本实例的 schedulerInstanceId 或远程 worker 的标识；version 为执行该记录的预测工具版本
+func claimQueueRow(model interface{}, tool string, id uint, sequence string, owner string, version string) (bool, error) {
+	now := time.Now()
+	updates := ownerLeaseUpdates(owner, now)
+	updates["status"] = JobStatusClaimed
+	updates["attempts"] = gorm.Expr("attempts + 1")
+	updates["started_at"] = now
+	updates["finished_at"] = nil
+	updates["version"] = version
+	return transitionQueueJob(model, tool, id, sequence, []string{JobStatusPending}, updates)
+}
+
+// claimedJob 已认领的队列记录
+type claimedJob struct {
+	ID       uint
+	Sequence string
+	Attempts int
+}
+
+// claimNextQueueJob 按公平调度顺序认领一条待处理记录
+func claimNextQueueJob(tool queueTool) (claimedJob, bool) {
+	var job claimedJob
+	id, ok := claimNextJob(tool, schedulerInstanceId)
+	if !ok {
+		return job, false
+	}
+	if err := database.Database.Model(tool.newModel()).Select("id, sequence, attempts").Where("id = ?", id).Scan(&job).Error; err != nil || job.ID == 0 {
+		logger.Error("读取%s任务 %d 失败: %v", tool.Name, id, err)
+		return job, false
+	}
+	return job, true
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 拟合耗时模型使用的最近记录数
+const durationModelSamples = 500
+
+// durationModel 按序列长度估计预测耗时的线性模型：耗时（秒）= Intercept + Slope × 长度
+type durationModel struct {
+	Intercept float64
+	Slope     float64
+	Samples   int
+}
+
+// estimate 返回长度为 length 的序列的预计耗时，至少 1 秒
+func (m durationModel) estimate(length int) time.Duration {
+	seconds := m.Intercept + m.Slope*float64(length)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// durationModels 各预测工具的耗时模型，首次使用时拟合，任务完成后重新拟合
+var durationModels = struct {
+	sync.RWMutex
+	models map[string]durationModel
+}{models: make(map[string]durationModel)}
+
+// fitDurationModel 用该预测工具最近完成的记录的耗时和序列长度做最小二乘拟合
+// 样本太少、长度都相同或斜率为负时使用平均耗时
+func fitDurationModel(predictor string) (durationModel, error) {
+	var samples []struct {
+		Length   int
+		Duration float64
+	}
+	if err := database.Database.Model(&models.ProteinInformation{}).
+		Select("CHAR_LENGTH(sequence) AS length, duration").
+		Where("predictor = ? AND duration > 0", predictor).
+		Order("id DESC").Limit(durationModelSamples).
+		Scan(&samples).Error; err != nil {
+		return durationModel{}, err
+	}
+
+	model := durationModel{Samples: len(samples)}
+	if len(samples) == 0 {
+		return model, nil
+	}
+	var sumX, sumY, sumXX, sumXY float64
+	for _, sample := range samples {
+		x, y := float64(sample.Length), sample.Duration
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(samples))
+	meanX, meanY := sumX/n, sumY/n
+	model.Intercept = meanY
+
+	variance := sumXX/n - meanX*meanX
+	if len(samples) >= 3 && variance > 0 {
+		slope := (sumXY/n - meanX*meanY) / variance
+		if slope >= 0 {
+			model.Slope = slope
+			model.Intercept = meanY - slope*meanX
+		}
+	}
+	return model, nil
+}
+
+// refreshDurationModel 重新拟合预测工具的耗时模型
+func refreshDurationModel(predictor string) {
+	model, err := fitDurationModel(predictor)
+	if err != nil {
+		logger.Error("拟合%s耗时模型失败: %v", predictor, err)
+		return
+	}
+	durationModels.Lock()
+	durationModels.models[predictor] = model
+	durationModels.Unlock()
+}
+
+// predictorDurationModel 返回预测工具的耗时模型，没有历史耗时时返回 false
+func predictorDurationModel(predictor string) (durationModel, bool) {
+	durationModels.RLock()
+	model, ok := durationModels.models[predictor]
+	durationModels.RUnlock()
+	if !ok {
+		refreshDurationModel(predictor)
+		durationModels.RLock()
+		model = durationModels.models[predictor]
+		durationModels.RUnlock()
+	}
+	return model, model.Samples > 0
+}
+
+// jobEstimate 队列记录预计的开始和完成时间
+type jobEstimate struct {
+	Id       uint
+	Sequence string
+	Start    time.Time
+	Finish   time.Time
+}
+
+// estimateQueue 按调度顺序模拟处理槽位，估计执行中和待处理记录的开始和完成时间
+// order 为 queueOrder 的结果；没有该预测工具的历史耗时时返回 nil
+func estimateQueue(tool queueTool, order []QueueOrderItem, now time.Time) ([]jobEstimate, error) {
+	model, ok := predictorDurationModel(tool.Key)
+	if !ok {
+		return nil, nil
+	}
+
+	var active []struct {
+		ID        uint
+		Sequence  string
+		StartedAt *time.Time
+	}
+	if err := tool.query().Select("id, sequence, started_at").
+		Where("status IN ?", jobActiveStatuses).Order("id").Scan(&active).Error; err != nil {
+		return nil, err
+	}
+
+	// 执行中的记录按已执行时间估计剩余耗时，超过预计耗时的视为即将完成
+	estimates := make([]jobEstimate, 0, len(active)+len(order))
+	var slots []time.Time
+	for _, job := range active {
+		start := now
+		if job.StartedAt != nil {
+			start = *job.StartedAt
+		}
+		finish := start.Add(model.estimate(len(job.Sequence)))
+		if finish.Before(now) {
+			finish = now
+		}
+		estimates = append(estimates, jobEstimate{Id: job.ID, Sequence: job.Sequence, Start: start, Finish: finish})
+		slots = append(slots, finish)
+	}
+
+	// 并行数为 0 的预测工具由远程 worker 执行，按至少一个处理槽位估计
+	capacity := tool.predictor.Info().Workers
+	if capacity < 1 {
+		capacity = 1
+	}
+	for len(slots) < capacity {
+		slots = append(slots, now)
+	}
+
+	schedule := func(id uint, sequence string, earliest time.Time) {
+		sort.Slice(slots, func(i, j int) bool { return slots[i].Before(slots[j]) })
+		start := slots[0]
+		if start.Before(earliest) {
+			start = earliest
+		}
+		finish := start.Add(model.estimate(len(sequence)))
+		slots[0] = finish
+		estimates = append(estimates, jobEstimate{Id: id, Sequence: sequence, Start: start, Finish: finish})
+	}
+	for _, item := range order {
+		schedule(item.Id, item.Sequence, now)
+	}
+
+	// 等待重试的记录在重试时间之后排入
+	var retries []struct {
+		ID            uint
+		Sequence      string
+		NextAttemptAt time.Time
+	}
+	if err := tool.query().Select("id, sequence, next_attempt_at").
+		Where("status = ? AND next_attempt_at > ?", JobStatusPending, now).
+		Order("next_attempt_at").Scan(&retries).Error; err != nil {
+		return nil, err
+	}
+	for _, job := range retries {
+		schedule(job.ID, job.Sequence, job.NextAttemptAt)
+	}
+	return estimates, nil
+}
+
+// sequenceEstimates 返回所有预测队列中未结束的序列预计的开始和完成时间
+func sequenceEstimates() map[string]jobEstimate {
+	now := time.Now()
+	result := make(map[string]jobEstimate)
+	for _, tool := range registeredQueueTools() {
+		order, err := queueOrder(tool)
+		if err != nil {
+			logger.Error("查询%s调度顺序失败: %v", tool.Name, err)
+			continue
+		}
+		estimates, err := estimateQueue(tool, order, now)
+		if err != nil {
+			logger.Error("估计%s完成时间失败: %v", tool.Name, err)
+			continue
+		}
+		for _, estimate := range estimates {
+			if existing, ok := result[estimate.Sequence]; !ok || estimate.Finish.After(existing.Finish) {
+				result[estimate.Sequence] = estimate
+			}
+		}
+	}
+	return result
+}
+
+// taskEstimate 按任务所有未结束序列的估计，返回最早的开始时间和最晚的完成时间（毫秒时间戳），没有估计时返回 nil
+func taskEstimate(task models.Task, estimates map[string]jobEstimate) (*int64, *int64) {
+	var start, finish time.Time
+	for _, sequence := range taskSequences(task) {
+		estimate, ok := estimates[sequence]
+		if !ok {
+			continue
+		}
+		if start.IsZero() || estimate.Start.Before(start) {
+			start = estimate.Start
+		}
+		if estimate.Finish.After(finish) {
+			finish = estimate.Finish
+		}
+	}
+	if finish.IsZero() {
+		return nil, nil
+	}
+	startMs, finishMs := start.UnixMilli(), finish.UnixMilli()
+	return &startMs, &finishMs
+}
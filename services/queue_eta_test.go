@@ -0,0 +1,82 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFitDurationModel(t *testing.T) {
+	type sample struct {
+		length    int
+		duration  float64
+		predictor string
+	}
+	tests := []struct {
+		name          string
+		samples       []sample
+		wantSamples   int
+		wantIntercept float64
+		wantSlope     float64
+	}{
+		{
+			name: "没有记录",
+		},
+		{
+			name:          "线性关系",
+			samples:       []sample{{10, 25, "fake"}, {20, 45, "fake"}, {40, 85, "fake"}},
+			wantSamples:   3,
+			wantIntercept: 5,
+			wantSlope:     2,
+		},
+		{
+			name:          "样本太少时使用平均耗时",
+			samples:       []sample{{10, 20, "fake"}, {30, 40, "fake"}},
+			wantSamples:   2,
+			wantIntercept: 30,
+		},
+		{
+			name:          "长度都相同时使用平均耗时",
+			samples:       []sample{{10, 10, "fake"}, {10, 20, "fake"}, {10, 30, "fake"}},
+			wantSamples:   3,
+			wantIntercept: 20,
+		},
+		{
+			name:          "斜率为负时使用平均耗时",
+			samples:       []sample{{10, 60, "fake"}, {20, 40, "fake"}, {30, 20, "fake"}},
+			wantSamples:   3,
+			wantIntercept: 40,
+		},
+		{
+			name:          "忽略其他预测工具和没有耗时的记录",
+			samples:       []sample{{10, 25, "fake"}, {20, 45, "fake"}, {40, 85, "fake"}, {30, 1000, "alpha"}, {50, 0, "fake"}, {60, 500, ""}},
+			wantSamples:   3,
+			wantIntercept: 5,
+			wantSlope:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDatabase(t)
+			for i, s := range tt.samples {
+				// 序列各不相同，长度为 s.length
+				sequence := strings.Repeat("A", s.length-1) + string(rune('C'+i))
+				if err := db.Create(&models.ProteinInformation{Sequence: sequence, Duration: s.duration, Predictor: s.predictor}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			model, err := fitDurationModel("fake")
+			if err != nil {
+				t.Fatalf("fitDurationModel: %v", err)
+			}
+			if model.Samples != tt.wantSamples {
+				t.Errorf("Samples = %d, want %d", model.Samples, tt.wantSamples)
+			}
+			if math.Abs(model.Intercept-tt.wantIntercept) > 1e-6 || math.Abs(model.Slope-tt.wantSlope) > 1e-6 {
+				t.Errorf("model = %+v, want intercept %v slope %v", model, tt.wantIntercept, tt.wantSlope)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 队列记录状态
+const (
+	JobStatusPending        = "pending"        // 等待认领
+	JobStatusClaimed        = "claimed"        // 已被调度器认领，尚未开始执行
+	JobStatusRunning        = "running"        // 预测工具执行中
+	JobStatusPostprocessing = "postprocessing" // 保存模型、计算参数等后处理
+	JobStatusSucceeded      = "succeeded"
+	JobStatusFailed         = "failed"
+	JobStatusCancelled      = "cancelled"
+)
+
+// jobStatuses 所有队列状态，用于统计
+var jobStatuses = []string{JobStatusPending, JobStatusClaimed, JobStatusRunning, JobStatusPostprocessing, JobStatusSucceeded, JobStatusFailed, JobStatusCancelled}
+
+// jobActiveStatuses 已认领、尚未结束的状态
+var jobActiveStatuses = []string{JobStatusClaimed, JobStatusRunning, JobStatusPostprocessing}
+
+// jobFinishedStatuses 已结束的状态
+var jobFinishedStatuses = []string{JobStatusSucceeded, JobStatusFailed, JobStatusCancelled}
+
+// errJobTransient 可重试的错误（网络错误、限流、执行超时等）
+var errJobTransient = errors.New("transient job error")
+
+// errJobCancelled 任务在执行过程中被取消
+var errJobCancelled = errors.New("job cancelled")
+
+// 失败重试配置
+var (
+	jobMaxAttempts  = 3                // 最大尝试次数（包括第一次）
+	jobRetryBackoff = 60 * time.Second // 第一次重试的等待时间，之后每次翻倍
+	jobMaxBackoff   = time.Hour
+)
+
+func init() {
+	if value := os.Getenv("JOB_MAX_ATTEMPTS"); value != "" {
+		if attempts, err := strconv.Atoi(value); err == nil && attempts > 0 {
+			jobMaxAttempts = attempts
+		}
+	}
+	if value := os.Getenv("JOB_RETRY_BACKOFF_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			jobRetryBackoff = time.Duration(seconds) * time.Second
+		}
+	}
+}
+
+// jobRetryDelay 第 attempts 次尝试失败后的重试等待时间
+func jobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryBackoff
+	for i := 1; i < attempts && delay < jobMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > jobMaxBackoff {
+		delay = jobMaxBackoff
+	}
+	return delay
+}
+
+// commandJobError 包装外部预测命令的错误，都不再重试：
+// 取消和超时已由 runJobCommand 处理，这里的 SIGXCPU 是超过了 CPU 时间限制，SIGKILL 通常是超过内存限制被系统终止，重试仍会失败
+func commandJobError(tool string, err error, output []byte) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			switch status.Signal() {
+			case syscall.SIGXCPU:
+				return fmt.Errorf("%s进程超过 CPU 时间限制，被信号 %v 终止", tool, status.Signal())
+			case syscall.SIGKILL:
+				return fmt.Errorf("%s进程被信号 %v 终止，可能超过了内存限制", tool, status.Signal())
+			}
+			return fmt.Errorf("%s进程被信号 %v 终止, 输出: %s", tool, status.Signal(), tailOutput(output, 2000))
+		}
+	}
+	return fmt.Errorf("执行%s失败: %v, 输出: %s", tool, err, tailOutput(output, 2000))
+}
+
+// tailOutput 截取命令输出的末尾部分，避免错误信息过长
+func tailOutput(output []byte, limit int) string {
+	if len(output) > limit {
+		output = output[len(output)-limit:]
+	}
+	return string(output)
+}
+
+// transitionQueueJob 只有记录当前处于 from 中的状态时才更新，返回是否更新成功
+func transitionQueueJob(model interface{}, tool string, id uint, sequence string, from []string, updates map[string]interface{}) (bool, error) {
+	result := database.Database.Model(model).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	refreshTasksForSequence(sequence)
+	PublishQueueStatus(tool, id, sequence, updates["status"].(string))
+	return true, nil
+}
+
+// advanceQueueJob 把执行中的记录推进到下一个执行阶段，记录已被取消时返回 errJobCancelled
+func advanceQueueJob(model interface{}, tool string, id uint, sequence string, status string) error {
+	ok, err := transitionQueueJob(model, tool, id, sequence, jobActiveStatuses, map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errJobCancelled
+	}
+	return nil
+}
+
+// finishQueueJob 记录执行结果并返回最终状态：
+// 成功标记为 succeeded；可重试的错误在未达到最大尝试次数时延迟后重新排队（pending）；其他错误标记为 failed
+func finishQueueJob(model interface{}, tool string, id uint, sequence string, attempts int, jobErr error) string {
+	now := time.Now()
+	status := JobStatusSucceeded
+	updates := map[string]interface{}{"status": status, "error_message": "", "finished_at": now}
+
+	if jobErr != nil {
+		if errors.Is(jobErr, errJobCancelled) {
+			return JobStatusCancelled
+		}
+		status = JobStatusFailed
+		updates = map[string]interface{}{"status": status, "error_message": jobErr.Error(), "finished_at": now}
+		if errors.Is(jobErr, errJobTransient) && attempts < jobMaxAttempts {
+			delay := jobRetryDelay(attempts)
+			status = JobStatusPending
+			updates = map[string]interface{}{"status": status, "error_message": jobErr.Error(), "next_attempt_at": now.Add(delay)}
+			logger.Warn("%s任务 ID %d 第 %d 次执行失败，%v 后重试: %v", tool, id, attempts, delay, jobErr)
+		}
+	}
+
+	// 结束执行，释放租约
+	updates["lease_owner"] = ""
+	updates["lease_expires_at"] = nil
+
+	ok, err := transitionQueueJob(model, tool, id, sequence, jobActiveStatuses, updates)
+	if err != nil {
+		logger.Error("更新%s任务 ID %d 状态失败: %v", tool, id, err)
+		return status
+	}
+	if !ok {
+		// 执行期间记录已被取消
+		return JobStatusCancelled
+	}
+	return status
+}
+
+// completeQueueJob 记录执行结果，任务最终结束时发送通知和回调
+func completeQueueJob(model interface{}, tool string, id uint, sequence string, attempts int, jobErr error) {
+	switch finishQueueJob(model, tool, id, sequence, attempts, jobErr) {
+	case JobStatusSucceeded, JobStatusFailed:
+		NotifyJobResult(sequence, tool, jobErr)
+		TriggerJobWebhooks(sequence, tool, jobErr)
+	}
+}
+
+// countJobsByStatus 统计预测工具队列中各状态的记录数
+func countJobsByStatus(tool queueTool) map[string]int64 {
+	counts := make(map[string]int64, len(jobStatuses))
+	for _, status := range jobStatuses {
+		counts[status] = 0
+	}
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := tool.query().Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		logger.Error("统计队列状态失败: %v", err)
+		return counts
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts
+}
+
+// SequenceJobState 序列最近一条队列记录的状态
+type SequenceJobState struct {
+	Tool     string
+	Status   string
+	Error    string
+	Attempts int
+}
+
+// latestSequenceJob 查询序列在所有预测队列中最近更新的记录
+func latestSequenceJob(sequence string) (SequenceJobState, bool) {
+	var state SequenceJobState
+	var latest time.Time
+	found := false
+
+	for _, tool := range registeredQueueTools() {
+		var job struct {
+			ID           uint
+			UpdatedAt    time.Time
+			Status       string
+			ErrorMessage string
+			Attempts     int
+		}
+		tool.query().Select("id, updated_at, status, error_message, attempts").
+			Where("sequence = ?", sequence).Order("updated_at DESC").Limit(1).Scan(&job)
+		if job.ID == 0 || (found && !job.UpdatedAt.After(latest)) {
+			continue
+		}
+		found = true
+		latest = job.UpdatedAt
+		state = SequenceJobState{Tool: tool.Name, Status: job.Status, Error: job.ErrorMessage, Attempts: job.Attempts}
+	}
+
+	return state, found
+}
+
+// MigrateLegacyQueueStatuses 将旧版本的队列状态（processing、completed）转换为新的状态
+func MigrateLegacyQueueStatuses() {
+	for _, model := range []interface{}{&models.AlphaFoldQueue{}, &models.ITasserQueue{}, &models.ESMQueue{}} {
+		if err := database.Database.Model(model).Where("status = ?", "processing").Update("status", JobStatusRunning).Error; err != nil {
+			logger.Error("转换旧队列状态失败: %v", err)
+		}
+		if err := database.Database.Model(model).Where("status = ?", "completed").Update("status", JobStatusSucceeded).Error; err != nil {
+			logger.Error("转换旧队列状态失败: %v", err)
+		}
+	}
+}
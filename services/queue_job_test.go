@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobRetryDelay(t *testing.T) {
+	backoff, maxBackoff := jobRetryBackoff, jobMaxBackoff
+	defer func() { jobRetryBackoff, jobMaxBackoff = backoff, maxBackoff }()
+	jobRetryBackoff, jobMaxBackoff = time.Minute, 10*time.Minute
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := jobRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("jobRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+
+	// 第一次重试的等待时间超过上限时使用上限
+	jobRetryBackoff = time.Hour
+	if got := jobRetryDelay(1); got != jobMaxBackoff {
+		t.Errorf("jobRetryDelay(1) = %v, want %v", got, jobMaxBackoff)
+	}
+}
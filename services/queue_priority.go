@@ -0,0 +1,285 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+// 队列优先级范围，数值越大越优先
+const (
+	QueuePriorityMin = -10
+	QueuePriorityMax = 10
+)
+
+// queueAgingInterval 低于默认优先级的记录等待时间每增加一个间隔，有效优先级加 1，保证低优先级任务最终能被执行
+var queueAgingInterval = 30 * time.Minute
+
+func init() {
+	if value, ok := envInt("QUEUE_AGING_MINUTES"); ok && value > 0 {
+		queueAgingInterval = time.Duration(value) * time.Minute
+	}
+}
+
+// queueCandidate 待处理的队列记录
+type queueCandidate struct {
+	ID        uint
+	Sequence  string
+	UserId    uint
+	Priority  int
+	CreatedAt time.Time
+}
+
+// QueueOrderItem 调度顺序中的一条记录
+type QueueOrderItem struct {
+	Tool              string `json:"tool"`
+	Id                uint   `json:"id"`
+	UserId            uint   `json:"userId"`
+	Priority          int    `json:"priority"`
+	EffectivePriority int    `json:"effectivePriority"` // 加上等待时间后的优先级
+	Position          int    `json:"position"`          // 从 1 开始的排队位置
+	WaitingSeconds    int64  `json:"waitingSeconds"`
+	EstimatedStart    *int64 `json:"estimatedStart"`  // 预计开始时间（毫秒时间戳），没有历史耗时时为 null
+	EstimatedFinish   *int64 `json:"estimatedFinish"` // 预计完成时间（毫秒时间戳）
+	Sequence          string `json:"-"`
+}
+
+// effectivePriority 低于默认优先级的记录，有效优先级 = 优先级 + 等待时间 / 老化间隔，最多提升到 QueuePriorityMax
+// 等待足够久后会超过持续到达的更高优先级任务，保证最终能被执行；
+// 默认及更高优先级的记录不随等待时间提升，同一优先级内用户之间的公平由轮流顺序保证
+func effectivePriority(priority int, createdAt time.Time, now time.Time) int {
+	if priority >= 0 {
+		return priority
+	}
+	aged := priority + int(now.Sub(createdAt)/queueAgingInterval)
+	if aged > QueuePriorityMax {
+		aged = QueuePriorityMax
+	}
+	return aged
+}
+
+// fairShareOrder 计算待处理记录的调度顺序：
+// 有效优先级高的先执行；有效优先级相同时按用户轮流，用户已在执行的任务越多越靠后；最后按提交顺序
+func fairShareOrder(tool string, candidates []queueCandidate, running map[uint]int64, now time.Time) []QueueOrderItem {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	type ranked struct {
+		candidate queueCandidate
+		effective int
+		share     int64
+	}
+	items := make([]ranked, 0, len(candidates))
+	userIndex := make(map[uint]int64)
+	for _, candidate := range candidates {
+		// 用户的第 n 条等待记录排在其他用户的前 n 条之后
+		share := running[candidate.UserId] + userIndex[candidate.UserId]
+		userIndex[candidate.UserId]++
+		items = append(items, ranked{
+			candidate: candidate,
+			effective: effectivePriority(candidate.Priority, candidate.CreatedAt, now),
+			share:     share,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].effective != items[j].effective {
+			return items[i].effective > items[j].effective
+		}
+		if items[i].share != items[j].share {
+			return items[i].share < items[j].share
+		}
+		return items[i].candidate.ID < items[j].candidate.ID
+	})
+
+	order := make([]QueueOrderItem, 0, len(items))
+	for i, item := range items {
+		order = append(order, QueueOrderItem{
+			Tool:              tool,
+			Id:                item.candidate.ID,
+			UserId:            item.candidate.UserId,
+			Priority:          item.candidate.Priority,
+			EffectivePriority: item.effective,
+			Position:          i + 1,
+			WaitingSeconds:    int64(now.Sub(item.candidate.CreatedAt).Seconds()),
+			Sequence:          item.candidate.Sequence,
+		})
+	}
+	return order
+}
+
+// queueOrder 查询队列中可以认领的记录并按公平调度排序
+func queueOrder(tool queueTool) ([]QueueOrderItem, error) {
+	now := time.Now()
+	var candidates []queueCandidate
+	if err := tool.query().Select("id, sequence, user_id, priority, created_at").
+		Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", JobStatusPending, now).
+		Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		UserId uint
+		Count  int64
+	}
+	if err := tool.query().Select("user_id, COUNT(*) AS count").
+		Where("status IN ?", jobActiveStatuses).Group("user_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	running := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		running[row.UserId] = row.Count
+	}
+
+	return fairShareOrder(tool.Key, candidates, running, now), nil
+}
+
+// claimNextJob 按公平调度顺序为 owner 认领一条待处理记录，返回记录ID
+func claimNextJob(tool queueTool, owner string) (uint, bool) {
+	order, err := queueOrder(tool)
+	if err != nil {
+		logger.Error("查询%s待处理任务失败: %v", tool.Name, err)
+		return 0, false
+	}
+
+	for i, item := range order {
+		if i >= maxClaimAttempts {
+			break
+		}
+		claimed, err := claimQueueRow(tool.newModel(), tool.Name, item.Id, item.Sequence, owner, tool.predictor.Info().Version)
+		if err != nil {
+			logger.Error("认领%s任务 %d 失败: %v", tool.Name, item.Id, err)
+			return 0, false
+		}
+		if claimed {
+			return item.Id, true
+		}
+		logger.Info("%s任务 %d 已被其他实例认领", tool.Name, item.Id)
+	}
+	return 0, false
+}
+
+// QueueOrder 返回各预测队列的调度顺序和预计开始、完成时间，非管理员只能看到自己的记录（位置仍为全局位置）
+func QueueOrder(viewerId uint) map[string][]QueueOrderItem {
+	admin := IsAdmin(viewerId)
+	now := time.Now()
+	result := make(map[string][]QueueOrderItem, len(queueTools))
+	for key, tool := range queueTools {
+		order, err := queueOrder(tool)
+		if err != nil {
+			logger.Error("查询%s调度顺序失败: %v", tool.Name, err)
+			continue
+		}
+		estimates, err := estimateQueue(tool, order, now)
+		if err != nil {
+			logger.Error("估计%s完成时间失败: %v", tool.Name, err)
+		}
+		estimateById := make(map[uint]jobEstimate, len(estimates))
+		for _, estimate := range estimates {
+			estimateById[estimate.Id] = estimate
+		}
+
+		visible := make([]QueueOrderItem, 0, len(order))
+		for _, item := range order {
+			if admin || item.UserId == viewerId {
+				if estimate, ok := estimateById[item.Id]; ok {
+					start, finish := estimate.Start.UnixMilli(), estimate.Finish.UnixMilli()
+					item.EstimatedStart, item.EstimatedFinish = &start, &finish
+				}
+				visible = append(visible, item)
+			}
+		}
+		result[key] = visible
+	}
+	return result
+}
+
+// newQueueJob 新建待处理记录的状态字段，创建时即设置所属用户和该用户的默认优先级，
+// 避免记录在归属用户之前被认领；userId 为 0 表示没有所属用户
+func newQueueJob(userId uint) models.QueueJob {
+	job := models.QueueJob{Status: JobStatusPending, UserId: userId}
+	if userId != 0 {
+		var user models.User
+		if err := database.Database.Select("id, queue_priority").Where("id = ?", userId).First(&user).Error; err == nil {
+			job.Priority = user.QueuePriority
+		}
+	}
+	return job
+}
+
+// assignQueueJobs 把任务相关的、尚无所属用户的待处理记录归属到任务的用户，并使用该用户的默认优先级
+// 新建的记录在创建时已设置所属用户，这里处理其他用户提交前已排队、尚无所属用户的记录
+func assignQueueJobs(task models.Task) {
+	var user models.User
+	if err := database.Database.Where("id = ?", task.UserId).First(&user).Error; err != nil {
+		return
+	}
+	sequences := taskSequences(task)
+	for _, tool := range queueTools {
+		if err := tool.query().
+			Where("sequence IN ? AND user_id = 0 AND status = ?", sequences, JobStatusPending).
+			Updates(map[string]interface{}{"user_id": user.ID, "priority": user.QueuePriority}).Error; err != nil {
+			logger.Error("设置%s队列记录所属用户失败: %v", tool.Name, err)
+		}
+	}
+}
+
+// IsAdmin 判断用户是否为管理员
+func IsAdmin(userId uint) bool {
+	var user models.User
+	if err := database.Database.Select("id, is_admin").Where("id = ?", userId).First(&user).Error; err != nil {
+		return false
+	}
+	return user.IsAdmin
+}
+
+type SetJobPriorityRequest struct {
+	Tool     string `json:"tool" binding:"required"` // "alpha", "itasser", "esm"
+	Id       uint   `json:"id" binding:"required"`
+	Priority int    `json:"priority"`
+}
+
+// SetJobPriority 管理员修改单条待处理记录的优先级
+func SetJobPriority(req SetJobPriorityRequest) error {
+	tool, ok := queueTools[req.Tool]
+	if !ok {
+		return errors.New("Invalid tool.")
+	}
+	if req.Priority < QueuePriorityMin || req.Priority > QueuePriorityMax {
+		return errors.New("Priority out of range.")
+	}
+	result := tool.query().Where("id = ? AND status = ?", req.Id, JobStatusPending).Update("priority", req.Priority)
+	if result.Error != nil {
+		return errors.New("Network error.")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Pending job not found.")
+	}
+	WakeQueueScheduler()
+	return nil
+}
+
+type SetUserPriorityRequest struct {
+	UserId   uint `json:"userId" binding:"required"`
+	Priority int  `json:"priority"`
+}
+
+// SetUserQueuePriority 管理员修改用户的默认优先级，只影响之后提交的任务
+func SetUserQueuePriority(req SetUserPriorityRequest) error {
+	if req.Priority < QueuePriorityMin || req.Priority > QueuePriorityMax {
+		return errors.New("Priority out of range.")
+	}
+	result := database.Database.Model(&models.User{}).Where("id = ?", req.UserId).Update("queue_priority", req.Priority)
+	if result.Error != nil {
+		return errors.New("Network error.")
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if database.Database.Model(&models.User{}).Where("id = ?", req.UserId).Count(&count); count == 0 {
+			return errors.New("User not found.")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEffectivePriority(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		priority int
+		waited   time.Duration
+		want     int
+	}{
+		{"默认优先级不随等待提升", 0, 10 * queueAgingInterval, 0},
+		{"高优先级不随等待提升", 5, 10 * queueAgingInterval, 5},
+		{"刚提交的低优先级", -3, 0, -3},
+		{"不足一个老化间隔", -3, queueAgingInterval - time.Second, -3},
+		{"等待两个老化间隔", -3, 2 * queueAgingInterval, -1},
+		{"可以超过默认优先级", -3, 6 * queueAgingInterval, 3},
+		{"最多提升到上限", QueuePriorityMin, 100 * queueAgingInterval, QueuePriorityMax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectivePriority(tt.priority, now.Add(-tt.waited), now); got != tt.want {
+				t.Errorf("effectivePriority(%d, 等待 %v) = %d, want %d", tt.priority, tt.waited, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFairShareOrder(t *testing.T) {
+	now := time.Now()
+	at := func(minutes int) time.Time { return now.Add(-time.Duration(minutes) * time.Minute) }
+
+	tests := []struct {
+		name       string
+		candidates []queueCandidate
+		running    map[uint]int64
+		want       []uint
+	}{
+		{
+			name: "同一优先级按用户轮流",
+			candidates: []queueCandidate{
+				{ID: 1, UserId: 1, CreatedAt: at(5)},
+				{ID: 2, UserId: 1, CreatedAt: at(4)},
+				{ID: 3, UserId: 1, CreatedAt: at(3)},
+				{ID: 4, UserId: 2, CreatedAt: at(2)},
+				{ID: 5, UserId: 2, CreatedAt: at(1)},
+			},
+			want: []uint{1, 4, 2, 5, 3},
+		},
+		{
+			name: "已在执行的任务多的用户靠后",
+			candidates: []queueCandidate{
+				{ID: 1, UserId: 1, CreatedAt: at(3)},
+				{ID: 2, UserId: 2, CreatedAt: at(2)},
+				{ID: 3, UserId: 1, CreatedAt: at(1)},
+			},
+			running: map[uint]int64{1: 2},
+			want:    []uint{2, 1, 3},
+		},
+		{
+			name: "高优先级先执行",
+			candidates: []queueCandidate{
+				{ID: 1, UserId: 1, CreatedAt: at(3)},
+				{ID: 2, UserId: 2, Priority: 5, CreatedAt: at(2)},
+				{ID: 3, UserId: 3, Priority: -1, CreatedAt: at(1)},
+			},
+			want: []uint{2, 1, 3},
+		},
+		{
+			name: "等待足够久的低优先级超过默认优先级",
+			candidates: []queueCandidate{
+				{ID: 1, UserId: 1, Priority: -1, CreatedAt: now.Add(-2 * queueAgingInterval)},
+				{ID: 2, UserId: 2, CreatedAt: at(1)},
+			},
+			want: []uint{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := fairShareOrder("fake", tt.candidates, tt.running, now)
+			if len(order) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(order), len(tt.want))
+			}
+			for i, item := range order {
+				if item.Id != tt.want[i] || item.Position != i+1 {
+					t.Errorf("position %d: got id %d (position %d), want id %d", i+1, item.Id, item.Position, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// schedulerInstanceId 本实例的标识，写入执行中记录的租约
+var schedulerInstanceId = newSchedulerInstanceId()
+
+// jobLeaseDuration 执行中记录的租约时长，实例在租约到期前通过心跳续期
+var jobLeaseDuration = 2 * time.Minute
+
+func init() {
+	if value, ok := envInt("JOB_LEASE_SECONDS"); ok && value > 0 {
+		jobLeaseDuration = time.Duration(value) * time.Second
+	}
+}
+
+func newSchedulerInstanceId() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// leaseUpdates 本实例认领或续期记录时写入的租约字段
+func leaseUpdates(now time.Time) map[string]interface{} {
+	return ownerLeaseUpdates(schedulerInstanceId, now)
+}
+
+// ownerLeaseUpdates 为 owner 认领或续期记录时写入的租约字段
+func ownerLeaseUpdates(owner string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"lease_owner":      owner,
+		"lease_expires_at": now.Add(jobLeaseDuration),
+		"heartbeat_at":     now,
+	}
+}
+
+// renewJobLeases 续期本实例正在执行的所有记录
+func renewJobLeases() {
+	updates := leaseUpdates(time.Now())
+	for _, tool := range queueTools {
+		if err := tool.query().
+			Where("lease_owner = ? AND status IN ?", schedulerInstanceId, jobActiveStatuses).
+			Updates(updates).Error; err != nil {
+			logger.Error("续期%s任务租约失败: %v", tool.Name, err)
+		}
+	}
+}
+
+// stopCancelledJobs 终止已被取消、仍由本实例执行的记录
+// 取消请求可能由其他实例处理，取消时保留租约，执行该记录的实例在心跳时终止进程并清除租约到期时间
+func stopCancelledJobs() {
+	for _, tool := range queueTools {
+		var ids []uint
+		if err := tool.query().
+			Where("lease_owner = ? AND status = ? AND lease_expires_at IS NOT NULL", schedulerInstanceId, JobStatusCancelled).
+			Pluck("id", &ids).Error; err != nil {
+			logger.Error("查询已取消的%s任务失败: %v", tool.Name, err)
+			continue
+		}
+		for _, id := range ids {
+			cancelRunningJob(tool.Name, id)
+			if err := tool.query().Where("id = ? AND status = ?", id, JobStatusCancelled).
+				Update("lease_expires_at", nil).Error; err != nil {
+				logger.Error("清除%s任务 ID %d 的租约失败: %v", tool.Name, id, err)
+			}
+		}
+	}
+}
+
+// orphanedJob 租约已过期的执行中记录
+type orphanedJob struct {
+	ID        uint
+	Sequence  string
+	Status    string
+	Attempts  int
+	StartedAt *time.Time
+}
+
+// recoverOrphanedJobs 恢复租约已过期（执行实例重启或崩溃）的记录：
+// 模型文件已生成的继续后处理；预测工具的输出已存在的处理输出；否则重新排队
+func (qs *QueueScheduler) recoverOrphanedJobs() {
+	now := time.Now()
+	for _, tool := range queueTools {
+		var jobs []orphanedJob
+		if err := tool.query().Select("id, sequence, status, attempts, started_at").
+			Where("status IN ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)", jobActiveStatuses, now).
+			Scan(&jobs).Error; err != nil {
+			logger.Error("查询中断的%s任务失败: %v", tool.Name, err)
+			continue
+		}
+		for _, job := range jobs {
+			qs.recoverOrphanedJob(tool, job)
+		}
+	}
+}
+
+func (qs *QueueScheduler) recoverOrphanedJob(tool queueTool, job orphanedJob) {
+	// 接管租约，避免多个实例同时恢复同一条记录
+	now := time.Now()
+	result := database.Database.Model(tool.newModel()).
+		Where("id = ? AND status IN ? AND (lease_expires_at IS NULL OR lease_expires_at < ?)", job.ID, jobActiveStatuses, now).
+		Updates(leaseUpdates(now))
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	logger.Warn("发现中断的%s任务 ID %d（状态: %s），开始恢复", tool.Name, job.ID, job.Status)
+
+	var err error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		completeQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, job.Attempts, err)
+	}()
+
+	var proteinInfo models.ProteinInformation
+	if e := database.Database.Where("sequence = ?", job.Sequence).Find(&proteinInfo).Error; e != nil || proteinInfo.ID == 0 {
+		err = fmt.Errorf("%w: 任务执行中断", errJobTransient)
+		return
+	}
+
+	duration := time.Duration(0)
+	if job.StartedAt != nil {
+		duration = now.Sub(*job.StartedAt)
+	}
+
+	switch {
+	case predictorModelExists(proteinInfo, tool.Key):
+		// 该预测工具的模型文件已生成，只需要完成后处理；其他预测工具之前生成的模型不算
+		logger.Info("%s任务 ID %d 的模型文件已存在，继续后处理", tool.Name, job.ID)
+		if err = advanceQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, JobStatusPostprocessing); err != nil {
+			return
+		}
+		if err = resumeSavedModel(tool, &proteinInfo); err != nil {
+			return
+		}
+		runProteinStages(proteinInfo)
+	case uploadedModelExists(tool, job.ID):
+		// 远程 worker 已上传模型，后处理被中断
+		logger.Info("%s任务 ID %d 的上传模型已存在，继续后处理", tool.Name, job.ID)
+		if err = advanceQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, JobStatusPostprocessing); err != nil {
+			return
+		}
+		err = finishPrediction(tool, job.Sequence, uploadedModelPath(tool, job.ID), jobWorkspace(tool.Key, job.ID), duration)
+	case hasPartialResult(tool, job.ID, job.Sequence):
+		logger.Info("%s任务 ID %d 的预测输出已存在，继续处理结果", tool.Name, job.ID)
+		err = collectPrediction(tool, newPredictionJob(tool, job.ID, job.Sequence), duration)
+	default:
+		// 没有可用的输出，按可重试错误重新排队
+		err = fmt.Errorf("%w: 任务执行中断", errJobTransient)
+	}
+}
+
+// resumeSavedModel 保存模型之后、记录预测工具之前中断时，重新复制为蛋白质当前使用的模型并记录预测工具
+func resumeSavedModel(tool queueTool, proteinInfo *models.ProteinInformation) error {
+	if proteinInfo.Predictor == tool.Key {
+		return nil
+	}
+	if err := useModelFile(proteinInfo.ID, tool.Key); err != nil {
+		return err
+	}
+	if err := database.Database.Model(&models.ProteinInformation{}).Where("id = ?", proteinInfo.ID).
+		Update("predictor", tool.Key).Error; err != nil {
+		return err
+	}
+	proteinInfo.Predictor = tool.Key
+	return nil
+}
+
+// fastaMatches 判断工作目录中的 FASTA 文件是否为该序列
+func fastaMatches(path string, sequence string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var body strings.Builder
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ">") {
+			continue
+		}
+		body.WriteString(line)
+	}
+	return body.Len() > 0 && body.String() == sequence
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecoverOrphanedJob(t *testing.T) {
+	tool := queueTools["fake"]
+	sequence := "MKTAYIAKQRQISFVKSHFSRQ"
+	oldModel := "REMARK OLD MODEL\n"
+
+	tests := []struct {
+		name          string
+		toolModel     bool // 中断前已保存假预测工具的模型
+		wantStatus    string
+		wantPredictor string
+		wantModel     string
+		wantStages    int
+	}{
+		// 其他预测工具之前生成的模型不能当作本次预测的结果
+		{"只有其他预测工具的模型时重新排队", false, JobStatusPending, "alpha", oldModel, 0},
+		{"已保存该预测工具的模型时继续后处理", true, JobStatusSucceeded, "fake", fakeHelixPDB(sequence), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDatabase(t)
+			stages := stubProteinStages(t)
+
+			proteinInfo := models.ProteinInformation{Sequence: sequence, Predictor: "alpha", Duration: 10}
+			if err := db.Create(&proteinInfo).Error; err != nil {
+				t.Fatal(err)
+			}
+			modelPath := filepath.Join("static", "models", fmt.Sprintf("%d.pdb", proteinInfo.ID))
+			os.MkdirAll(filepath.Dir(modelPath), 0755)
+			if err := os.WriteFile(modelPath, []byte(oldModel), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.toolModel {
+				os.WriteFile(predictorModelPath(proteinInfo.ID, tool.Key), []byte(fakeHelixPDB(sequence)), 0644)
+			}
+
+			started := time.Now().Add(-time.Minute)
+			job := models.PredictionQueue{Predictor: tool.Key, Sequence: sequence}
+			job.Status, job.Attempts, job.StartedAt = JobStatusRunning, 1, &started
+			if err := db.Create(&job).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			qs := &QueueScheduler{}
+			qs.recoverOrphanedJob(tool, orphanedJob{ID: job.ID, Sequence: sequence, Status: JobStatusRunning, Attempts: 1, StartedAt: &started})
+
+			db.First(&job, job.ID)
+			if job.Status != tt.wantStatus {
+				t.Errorf("队列记录状态为 %s，want %s（%s）", job.Status, tt.wantStatus, job.ErrorMessage)
+			}
+			db.First(&proteinInfo, proteinInfo.ID)
+			if proteinInfo.Predictor != tt.wantPredictor {
+				t.Errorf("predictor = %q, want %q", proteinInfo.Predictor, tt.wantPredictor)
+			}
+			if content, _ := os.ReadFile(modelPath); string(content) != tt.wantModel {
+				t.Errorf("当前模型不是预期的模型:\n%.80s", content)
+			}
+			if len(*stages) != tt.wantStages {
+				t.Errorf("执行了 %d 个后处理阶段，want %d", len(*stages), tt.wantStages)
+			}
+		})
+	}
+}
@@ -1,9 +1,8 @@
 package services
 
 import (
-	"Protein_Server/database"
 	"Protein_Server/logger"
-	"Protein_Server/models"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -14,28 +13,45 @@ var globalSchedulerOnce sync.Once
 
 // QueueScheduler 统一队列调度器
 type QueueScheduler struct {
-	alphaProcessor *AlphaProcessor
-	itasserProcessor *ItasserProcessor
+	workers        map[string]chan struct{} // 每个预测工具在本实例的处理槽位
 	stopChan       chan struct{}
 	wg             sync.WaitGroup
 	isRunning      bool
 	mu             sync.Mutex
+	lastTrashPurge time.Time
+	wakeChan       chan struct{} // 有新的队列记录或处理槽位释放时唤醒调度器
 }
 
 // GetGlobalQueueScheduler 获取全局队列调度器实例
 func GetGlobalQueueScheduler() *QueueScheduler {
 	globalSchedulerOnce.Do(func() {
-		globalQueueScheduler = NewQueueScheduler(1, 1)
+		globalQueueScheduler = NewQueueScheduler()
 	})
 	return globalQueueScheduler
 }
 
-// NewQueueScheduler 创建新的队列调度器
-func NewQueueScheduler(maxAlphaWorkers, maxItasserWorkers int) *QueueScheduler {
+// workerCount 从环境变量读取预测工具的并行数
+// 每个任务使用独立的工作目录，可以在大内存机器上增加并行数；设为 0 时本实例不执行该工具，只由远程 worker 执行
+func workerCount(name string, defaultValue int) int {
+	if value, ok := envInt(name); ok && value >= 0 {
+		return int(value)
+	}
+	return defaultValue
+}
+
+// NewQueueScheduler 创建新的队列调度器，按已注册预测工具的并行数分配处理槽位
+// 并行数为 0 的预测工具不分配槽位，其记录只由远程 worker 租用执行
+func NewQueueScheduler() *QueueScheduler {
+	workers := make(map[string]chan struct{}, len(queueTools))
+	for key, tool := range queueTools {
+		if count := tool.predictor.Info().Workers; count > 0 {
+			workers[key] = make(chan struct{}, count)
+		}
+	}
 	return &QueueScheduler{
-		alphaProcessor:   NewAlphaProcessor(maxAlphaWorkers),
-		itasserProcessor: NewItasserProcessor(maxItasserWorkers),
-		stopChan:         make(chan struct{}),
+		workers:  workers,
+		stopChan: make(chan struct{}),
+		wakeChan: make(chan struct{}, 1),
 	}
 }
 
@@ -50,9 +66,11 @@ func (qs *QueueScheduler) Start() {
 	qs.mu.Unlock()
 
 	logger.Info("队列调度器启动...")
+	MigrateLegacyQueueStatuses()
 	
-	qs.wg.Add(1)
+	qs.wg.Add(2)
 	go qs.run()
+	go qs.heartbeat()
 }
 
 // Stop 停止队列调度器
@@ -75,194 +93,140 @@ func (qs *QueueScheduler) Stop() {
 func (qs *QueueScheduler) run() {
 	defer qs.wg.Done()
 	
-	ticker := time.NewTicker(1 * time.Minute) // 每1分钟检查一次队列
+	ticker := time.NewTicker(1 * time.Minute) // 每1分钟检查一次队列，作为唤醒信号之外的兜底
 	defer ticker.Stop()
 
+	// 启动时先恢复中断的任务，再处理积压的队列
+	qs.processQueues()
+
 	for {
 		select {
 		case <-qs.stopChan:
 			return
+		case <-qs.wakeChan:
+			qs.dispatchQueues()
 		case <-ticker.C:
 			qs.processQueues()
 		}
 	}
 }
 
+// heartbeat 定期续期本实例正在执行的任务的租约，并终止在其他实例上被取消的任务
+func (qs *QueueScheduler) heartbeat() {
+	defer qs.wg.Done()
+
+	ticker := time.NewTicker(jobLeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-qs.stopChan:
+			return
+		case <-ticker.C:
+			renewJobLeases()
+			stopCancelledJobs()
+		}
+	}
+}
+
 // processQueues 处理所有队列
 func (qs *QueueScheduler) processQueues() {
-	// 处理AlphaFold队列
-	qs.processAlphaFoldQueue()
-	
-	// 处理I-TASSER队列
-	qs.processItasserQueue()
-	
+	// 恢复租约过期（执行实例重启或崩溃）的任务
+	qs.recoverOrphanedJobs()
+
+	qs.dispatchQueues()
+
 	// 清理已完成的任务
 	qs.cleanupCompletedTasks()
-}
 
-// processAlphaFoldQueue 处理AlphaFold队列
-func (qs *QueueScheduler) processAlphaFoldQueue() {
-	var pendingTasks []models.AlphaFoldQueue
-	
-	// 查找待处理的任务
-	if err := database.Database.Where("status = ?", "pending").Find(&pendingTasks).Error; err != nil {
-		logger.Error("查询AlphaFold待处理任务失败: %v", err)
-		return
+	// 每小时清理一次超过保留时间的回收站任务
+	if time.Since(qs.lastTrashPurge) >= time.Hour {
+		qs.lastTrashPurge = time.Now()
+		PurgeExpiredTasks()
 	}
+}
 
-	for _, task := range pendingTasks {
-		// 检查是否有正在处理的任务
-		var processingCount int64
-		if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("status = ?", "processing").Count(&processingCount).Error; err != nil {
-			logger.Error("查询AlphaFold处理中任务数量失败: %v", err)
-			continue
-		}
-
-		// 如果当前没有处理中的任务，开始处理新任务
-		if processingCount == 0 {
-			// 更新任务状态为处理中
-			if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("id = ?", task.ID).Update("status", "processing").Error; err != nil {
-				logger.Error("更新AlphaFold任务状态失败: %v", err)
-				continue
-			}
-
-			logger.Info("开始处理AlphaFold任务 ID: %d", task.ID)
-			
-			// 启动处理任务
-			go qs.processAlphaFoldTask(task)
-		} else {
-			logger.Info("AlphaFold队列中有任务正在处理中，跳过新任务")
-			break
-		}
+// dispatchQueues 在本实例有空闲处理槽位时认领并启动待处理任务
+func (qs *QueueScheduler) dispatchQueues() {
+	for _, tool := range registeredQueueTools() {
+		qs.processQueue(tool)
 	}
 }
 
-// processItasserQueue 处理I-TASSER队列
-func (qs *QueueScheduler) processItasserQueue() {
-	var pendingTasks []models.ITasserQueue
-	
-	// 查找待处理的任务
-	if err := database.Database.Where("status = ?", "pending").Find(&pendingTasks).Error; err != nil {
-		logger.Error("查询I-TASSER待处理任务失败: %v", err)
+// processQueue 处理一个预测工具的队列
+func (qs *QueueScheduler) processQueue(tool queueTool) {
+	workerChan, ok := qs.workers[tool.Key]
+	if !ok {
+		// 本实例不执行该工具
 		return
 	}
-
-	for _, task := range pendingTasks {
-		// 检查是否有正在处理的任务
-		var processingCount int64
-		if err := database.Database.Model(&models.ITasserQueue{}).Where("status = ?", "processing").Count(&processingCount).Error; err != nil {
-			logger.Error("查询I-TASSER处理中任务数量失败: %v", err)
-			continue
+	for {
+		// 本实例的处理槽位已满
+		select {
+		case workerChan <- struct{}{}:
+		default:
+			return
 		}
 
-		// 如果当前没有处理中的任务，开始处理新任务
-		if processingCount == 0 {
-			// 更新任务状态为处理中
-			if err := database.Database.Model(&models.ITasserQueue{}).Where("id = ?", task.ID).Update("status", "processing").Error; err != nil {
-				logger.Error("更新I-TASSER任务状态失败: %v", err)
-				continue
-			}
-
-			logger.Info("开始处理I-TASSER任务 ID: %d", task.ID)
-			
-			// 启动处理任务
-			go qs.processItasserTask(task)
-		} else {
-			logger.Info("I-TASSER队列中有任务正在处理中，跳过新任务")
-			break
+		job, ok := claimNextQueueJob(tool)
+		if !ok {
+			<-workerChan
+			return
 		}
-	}
-}
 
-// processAlphaFoldTask 处理单个AlphaFold任务
-func (qs *QueueScheduler) processAlphaFoldTask(task models.AlphaFoldQueue) {
-	defer func() {
-		// 任务完成后，将状态更新为已完成
-		if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("id = ?", task.ID).Update("status", "completed").Error; err != nil {
-			logger.Error("更新AlphaFold任务完成状态失败: %v", err)
-		}
-	}()
+		logger.Info("开始处理%s任务 ID: %d", tool.Name, job.ID)
 
-	// 验证FASTA格式
-	if !IsFasta(task.Sequence) {
-		logger.Error("AlphaFold任务序列格式无效，跳过处理")
-		if err := database.Database.Model(&models.AlphaFoldQueue{}).Where("id = ?", task.ID).Update("status", "failed").Error; err != nil {
-			logger.Error("更新AlphaFold任务失败状态失败: %v", err)
-		}
-		return
+		// 启动处理任务
+		go func() {
+			defer func() {
+				<-workerChan
+				WakeQueueScheduler()
+			}()
+			qs.processTask(tool, job)
+		}()
 	}
-
-	// 使用现有的AlphaProcessor处理任务
-	qs.alphaProcessor.buildModel(task.ID, task.Sequence)
 }
 
-// processItasserTask 处理单个I-TASSER任务
-func (qs *QueueScheduler) processItasserTask(task models.ITasserQueue) {
+// processTask 处理单条队列记录
+func (qs *QueueScheduler) processTask(tool queueTool, job claimedJob) {
+	var err error
 	defer func() {
-		// 任务完成后，将状态更新为已完成
-		if err := database.Database.Model(&models.ITasserQueue{}).Where("id = ?", task.ID).Update("status", "completed").Error; err != nil {
-			logger.Error("更新I-TASSER任务完成状态失败: %v", err)
+		// 执行过程中发生 panic 时记录为失败
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
 		}
-	}()
-
-	// 验证FASTA格式
-	if !IsFasta(task.Sequence) {
-		logger.Error("I-TASSER任务序列格式无效，跳过处理")
-		if err := database.Database.Model(&models.ITasserQueue{}).Where("id = ?", task.ID).Update("status", "failed").Error; err != nil {
-			logger.Error("更新I-TASSER任务失败状态失败: %v", err)
+		if err != nil {
+			logger.Error("%s任务 ID %d 处理失败: %v", tool.Name, job.ID, err)
 		}
-		return
-	}
+		completeQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, job.Attempts, err)
+	}()
 
-	// 使用现有的ItasserProcessor处理任务
-	qs.itasserProcessor.buildModel(task.ID, task.Sequence)
+	err = runPrediction(tool, job.ID, job.Sequence)
 }
 
-// cleanupCompletedTasks 清理已完成和失败的任务
+// cleanupCompletedTasks 把超过保留时间的已结束任务归档到任务历史，并清理过期的历史记录
 func (qs *QueueScheduler) cleanupCompletedTasks() {
-	// 清理AlphaFold已完成和失败任务（保留最近24小时的任务用于调试）
-	yesterday := time.Now().Add(-24 * time.Hour)
-	
-	if err := database.Database.Where("(status = ? OR status = ?) AND updated_at < ?", "completed", "failed", yesterday).Delete(&models.AlphaFoldQueue{}).Error; err != nil {
-		logger.Error("清理AlphaFold已完成和失败任务失败: %v", err)
-	}
-
-	// 清理I-TASSER已完成和失败任务
-	if err := database.Database.Where("(status = ? OR status = ?) AND updated_at < ?", "completed", "failed", yesterday).Delete(&models.ITasserQueue{}).Error; err != nil {
-		logger.Error("清理I-TASSER已完成和失败任务失败: %v", err)
+	now := time.Now()
+	for _, tool := range registeredQueueTools() {
+		archiveExpiredJobs(tool, now)
 	}
+	purgeJobHistory(now)
 }
 
+// queueStatusKeys 队列状态中内置预测工具沿用的名称
+var queueStatusKeys = map[string]string{"alpha": "alphafold", "esm": "esmfold"}
+
 // GetQueueStatus 获取队列状态
 func (qs *QueueScheduler) GetQueueStatus() map[string]interface{} {
-	var alphaPending, alphaProcessing, alphaCompleted, alphaFailed int64
-	var itasserPending, itasserProcessing, itasserCompleted, itasserFailed int64
-
-	// 统计AlphaFold队列状态
-	database.Database.Model(&models.AlphaFoldQueue{}).Where("status = ?", "pending").Count(&alphaPending)
-	database.Database.Model(&models.AlphaFoldQueue{}).Where("status = ?", "processing").Count(&alphaProcessing)
-	database.Database.Model(&models.AlphaFoldQueue{}).Where("status = ?", "completed").Count(&alphaCompleted)
-	database.Database.Model(&models.AlphaFoldQueue{}).Where("status = ?", "failed").Count(&alphaFailed)
-
-	// 统计I-TASSER队列状态
-	database.Database.Model(&models.ITasserQueue{}).Where("status = ?", "pending").Count(&itasserPending)
-	database.Database.Model(&models.ITasserQueue{}).Where("status = ?", "processing").Count(&itasserProcessing)
-	database.Database.Model(&models.ITasserQueue{}).Where("status = ?", "completed").Count(&itasserCompleted)
-	database.Database.Model(&models.ITasserQueue{}).Where("status = ?", "failed").Count(&itasserFailed)
-
-	return map[string]interface{}{
-		"alphafold": map[string]int64{
-			"pending":    alphaPending,
-			"processing": alphaProcessing,
-			"completed":  alphaCompleted,
-			"failed":     alphaFailed,
-		},
-		"itasser": map[string]int64{
-			"pending":    itasserPending,
-			"processing": itasserProcessing,
-			"completed":  itasserCompleted,
-			"failed":     itasserFailed,
-		},
+	status := map[string]interface{}{
 		"is_running": qs.isRunning,
 	}
-} 
\ No newline at end of file
+	for _, tool := range registeredQueueTools() {
+		key := tool.Key
+		if legacy, ok := queueStatusKeys[key]; ok {
+			key = legacy
+		}
+		status[key] = countJobsByStatus(tool)
+	}
+	return status
+}
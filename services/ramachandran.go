@@ -8,11 +8,19 @@ import (
 )
 
 func Ramachandran(protein_id string) {
+	if err := generateRamachandran(protein_id); err != nil {
+		logger.Error("%v", err)
+	} else {
+		logger.Info("成功生成Ramachandran图: %s.png", protein_id)
+	}
+}
+
+// generateRamachandran 根据 static/models 中的模型生成 static/imgs/{id}.png
+func generateRamachandran(protein_id string) error {
 	// 确保输出目录存在
 	outputDir := "static/imgs"
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		logger.Error("创建Ramachandran输出目录失败: %v", err)
-		return
+		return fmt.Errorf("创建Ramachandran输出目录失败: %v", err)
 	}
 
 	// 构建输入和输出路径
@@ -25,8 +33,7 @@ func Ramachandran(protein_id string) {
 	cmd := exec.Command("bash", "-c", cmdStr)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		logger.Error("运行Ramachandran失败: %v, 输出: %s", err, output)
-	} else {
-		logger.Info("成功生成Ramachandran图: %s.png", protein_id)
+		return fmt.Errorf("运行Ramachandran失败: %v, 输出: %s", err, output)
 	}
+	return nil
 }
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RerunRequest 重新运行或克隆任务的请求
+type RerunRequest struct {
+	TaskId uint     `json:"taskId" binding:"required"`
+	Type   string   `json:"type"`   // "alpha", "itasser", "esm"，为空时沿用原任务的预测工具
+	Title  string   `json:"title"`  // 为空时在原标题后追加 (rerun)
+	Evalue *float64 `json:"evalue"` // 仅 blast 任务，指定后按新的 E-value 重新进行结构域搜索
+}
+
+// RerunResponse 重新运行的结果
+type RerunResponse struct {
+	ID     uint   `json:"id"`
+	Queued int    `json:"queued"` // 新加入预测的序列数量
+	Reused int    `json:"reused"` // 直接复用该预测工具已有模型的序列数量
+	Error  string `json:"error,omitempty"`
+}
+
+// RerunTask 基于已有任务创建关联的新任务，复用已计算的结构域和模型，只为缺少该预测工具模型的序列排队预测
+// 每个预测工具的模型分别保存，换用其他预测工具时会重新预测
+func RerunTask(userId uint, req RerunRequest) RerunResponse {
+	var source models.Task
+	if err := database.Database.Where("id = ?", req.TaskId).First(&source).Error; err != nil || !UserHasTaskPermission(userId, req.TaskId, SharePermissionRerun) {
+		return RerunResponse{Error: "Task not found."}
+	}
+	if source.Type != 1 && source.Type != 2 {
+		return RerunResponse{Error: "Only blast and fold tasks can be rerun."}
+	}
+
+	typeValue := int64(0)
+	if req.Type != "" {
+		typeValue = BlastTypeStringToInt(req.Type)
+	} else if source.StructurePredictionTool != nil {
+		typeValue = *source.StructurePredictionTool
+	}
+	if typeValue == 0 {
+		return RerunResponse{Error: "Invalid type."}
+	}
+	if err := ValidatePredictorInput(typeValue, source.Sequence); err != nil {
+		return RerunResponse{Error: err.Error()}
+	}
+	tool, ok := queueToolByType(typeValue)
+	if !ok {
+		return RerunResponse{Error: "Invalid type."}
+	}
+	if req.Evalue != nil && (source.Type != 1 || *req.Evalue <= 0 || *req.Evalue > 10) {
+		return RerunResponse{Error: "Invalid evalue."}
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = source.Title + " (rerun)"
+	}
+
+	// 主序列
+	var mainProteinInfo models.ProteinInformation
+	database.Database.Where("sequence = ?", source.Sequence).Find(&mainProteinInfo)
+	mainIsNew := mainProteinInfo.ID == 0
+	if mainIsNew {
+		ProteinInformation(source.Sequence, "", typeValue, userId)
+		database.Database.Where("sequence = ?", source.Sequence).Find(&mainProteinInfo)
+		if mainProteinInfo.ID == 0 {
+			return RerunResponse{Error: "无法获取主序列蛋白质信息ID"}
+		}
+	}
+
+	// 子序列：指定新的 E-value 时重新搜索结构域，否则复用原任务的结果
+	var subSequences []string
+	subInformations := make(map[string]string)
+	if req.Evalue != nil {
+		domains, blastInformations := BlastProcessingWithEvalue(source.Sequence, *req.Evalue)
+		if domains == nil {
+			return RerunResponse{Error: "BLAST 处理失败"}
+		}
+		for i := range domains {
+			description := getDescription(blastInformations[i])
+			fasta := source.Sequence[description.From-1 : description.To]
+			subSequences = append(subSequences, fasta)
+			if infoJSON, err := json.Marshal(blastInformations[i]); err == nil {
+				subInformations[fasta] = string(infoJSON)
+			}
+		}
+	} else if source.SubSequence != "" {
+		for _, sub := range strings.Split(source.SubSequence, "|") {
+			if sub != "" {
+				subSequences = append(subSequences, sub)
+			}
+		}
+	}
+
+	response := RerunResponse{}
+	modelIds := []string{strconv.FormatUint(uint64(mainProteinInfo.ID), 10)}
+	proteinIdSet := map[uint]bool{mainProteinInfo.ID: true}
+	if mainIsNew {
+		response.Queued++
+	} else if queued := enqueueMissingPrediction(mainProteinInfo, typeValue, userId); queued {
+		response.Queued++
+	} else {
+		response.Reused++
+	}
+
+	for _, sub := range subSequences {
+		var subProteinInfo models.ProteinInformation
+		database.Database.Where("sequence = ?", sub).Find(&subProteinInfo)
+		if subProteinInfo.ID == 0 {
+			// 新的结构域序列，创建记录时会按预测工具排队
+			ProteinInformationWithParent(sub, subInformations[sub], typeValue, mainProteinInfo.ID, userId)
+			database.Database.Where("sequence = ?", sub).Find(&subProteinInfo)
+			if subProteinInfo.ID == 0 {
+				continue
+			}
+			response.Queued++
+		} else if proteinIdSet[subProteinInfo.ID] {
+			continue
+		} else if enqueueMissingPrediction(subProteinInfo, typeValue, userId) {
+			response.Queued++
+		} else {
+			response.Reused++
+		}
+		proteinIdSet[subProteinInfo.ID] = true
+		// 只加入已有所选预测工具模型的子序列，其余的预测完成后再加入
+		if predictorModelExists(subProteinInfo, tool.Key) {
+			modelIds = append(modelIds, strconv.FormatUint(uint64(subProteinInfo.ID), 10))
+		}
+	}
+
+	task := models.Task{
+		Title:                   title,
+		Sequence:                source.Sequence,
+		SubSequence:             strings.Join(subSequences, "|"),
+		Type:                    source.Type,
+		StructurePredictionTool: &typeValue,
+		UserId:                  int64(userId),
+		ModelId:                 strings.Join(modelIds, ","),
+		FolderId:                source.FolderId,
+		SourceTaskId:            source.ID,
+		Evalue:                  source.Evalue,
+	}
+	if req.Evalue != nil {
+		task.Evalue = *req.Evalue
+	}
+	if uint(source.UserId) != userId {
+		task.FolderId = 0
+	}
+	if err := database.Database.Create(&task).Error; err != nil {
+		return RerunResponse{Error: "创建任务失败"}
+	}
+	logger.Info("任务 %d 由任务 %d 重新运行创建，新排队 %d 条，复用 %d 条", task.ID, source.ID, response.Queued, response.Reused)
+
+	assignQueueJobs(task)
+	RefreshTaskStatus(task)
+	IndexTask(task.ID)
+	response.ID = task.ID
+	return response
+}
+
+// enqueueMissingPrediction 该预测工具的模型不存在时按预测工具重新排队，返回是否排队
+// 已有的模型来自其他预测工具时同样排队，完成后该预测工具的模型成为蛋白质当前使用的模型
+// 排队的记录归属 userId 并使用其默认优先级
+func enqueueMissingPrediction(proteinInfo models.ProteinInformation, typeValue int64, userId uint) bool {
+	var parentId *int64
+	if proteinInfo.ParentId != 0 {
+		id := int64(proteinInfo.ParentId)
+		parentId = &id
+	}
+
+	tool, ok := queueToolByType(typeValue)
+	if !ok {
+		return false
+	}
+	if predictorModelExists(proteinInfo, tool.Key) {
+		return false
+	}
+
+	var queue queueRow
+	if tool.query().Select("id, sequence, status").Where("sequence = ?", proteinInfo.Sequence).Order("id DESC").Limit(1).Scan(&queue); queue.ID != 0 {
+		if queue.Status != JobStatusFailed && queue.Status != JobStatusSucceeded && queue.Status != JobStatusCancelled {
+			return true
+		}
+		// 上一次执行的结果归档后删除记录，重新排队的执行使用新的记录；
+		// 没有归档时记录已被其他请求重新排队
+		if !archiveQueueJob(tool, queue.ID) {
+			return true
+		}
+	}
+	tool.enqueue(proteinInfo.Sequence, parentId, userId)
+	return true
+}
+
+// proteinModelExists 判断蛋白质的模型文件是否已生成
+func proteinModelExists(proteinId uint) bool {
+	_, err := os.Stat(fmt.Sprintf("static/models/%d.pdb", proteinId))
+	return err == nil
+}
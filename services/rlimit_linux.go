@@ -0,0 +1,25 @@
+//go:build linux
+
+package services
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// applyProcessLimits 系统中没有 prlimit 时，为已启动的预测进程设置 CPU 时间和地址空间上限
+// 只是尽力而为：设置之前已创建的子进程不受限制，只有之后创建的子进程会继承
+func applyProcessLimits(pid int, limits predictorLimits) error {
+	if limits.CPUSeconds > 0 {
+		rlimit := unix.Rlimit{Cur: limits.CPUSeconds, Max: limits.CPUSeconds}
+		if err := unix.Prlimit(pid, unix.RLIMIT_CPU, &rlimit, nil); err != nil {
+			return err
+		}
+	}
+	if limits.memoryRlimit() {
+		rlimit := unix.Rlimit{Cur: limits.MemoryBytes, Max: limits.MemoryBytes}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, &rlimit, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package services
+
+// applyProcessLimits 当前平台不支持为其他进程设置资源限制，只使用运行超时
+func applyProcessLimits(pid int, limits predictorLimits) error {
+	return nil
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 检索文档类型
+const (
+	SearchKindTask     = "task"
+	SearchKindNote     = "note"
+	SearchKindDomain   = "domain"
+	SearchKindSequence = "sequence" // 序列片段直接匹配任务序列，不建索引
+)
+
+// 片段前后保留的字符数
+const searchSnippetRadius = 60
+
+var (
+	// 去掉全文检索布尔模式中的运算符
+	searchOperatorPattern = regexp.MustCompile(`[+\-<>()~*"@]+`)
+	// 可能是氨基酸序列片段的查询
+	searchSequencePattern = regexp.MustCompile(`^[ACDEFGHIKLMNPQRSTVWYacdefghiklmnpqrstvwy]{5,}$`)
+)
+
+// SearchResultItem 检索结果
+type SearchResultItem struct {
+	Kind      string  `json:"kind"`
+	TaskId    uint    `json:"taskId,omitempty"`
+	TaskTitle string  `json:"taskTitle,omitempty"`
+	RefId     uint    `json:"refId"` // 任务ID、注释的 sequenceId 或结构域所在的蛋白质ID
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"` // HTML 转义后用 <mark> 标记命中词
+	Score     float64 `json:"score"`
+}
+
+type SearchResult struct {
+	List  []SearchResultItem `json:"list"`
+	Total int64              `json:"total"` // 全文检索结果的总数，不包括 sequences
+	// 查询像序列片段时直接匹配任务序列的结果，不分页，每页都返回相同的内容
+	Sequences []SearchResultItem `json:"sequences"`
+}
+
+// 序列片段匹配最多返回的任务数
+const searchSequenceLimit = 20
+
+// Search 在用户可访问的任务标题、序列、注释和结构域信息中全文检索
+func Search(userId uint, keyword string, current, pageSize int) (SearchResult, error) {
+	terms := searchTerms(keyword)
+	if len(terms) == 0 {
+		return SearchResult{List: []SearchResultItem{}, Sequences: []SearchResultItem{}}, nil
+	}
+
+	// 布尔模式：所有词都必须出现，支持前缀匹配
+	booleanQuery := "+" + strings.Join(terms, "* +") + "*"
+
+	accessibleTasks := database.Database.Model(&models.Task{}).Select("id").
+		Where(database.Database.Where("user_id = ?", userId).Or("id IN (?)", sharedTaskIds(userId)))
+	db := database.Database.Model(&models.SearchDocument{}).
+		Where("MATCH(title, content) AGAINST(? IN BOOLEAN MODE)", booleanQuery).
+		Where("task_id IN (?)", accessibleTasks)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return SearchResult{}, err
+	}
+
+	type scoredDocument struct {
+		models.SearchDocument
+		Score float64
+	}
+	var documents []scoredDocument
+	if err := db.Select("*, MATCH(title, content) AGAINST(? IN BOOLEAN MODE) AS score", booleanQuery).
+		Order("score DESC").Offset((current - 1) * pageSize).Limit(pageSize).
+		Scan(&documents).Error; err != nil {
+		return SearchResult{}, err
+	}
+
+	list := make([]SearchResultItem, 0, len(documents)+1)
+	for _, document := range documents {
+		text := document.Content
+		if text == "" {
+			text = document.Title
+		}
+		list = append(list, SearchResultItem{
+			Kind:    document.Kind,
+			TaskId:  document.TaskId,
+			RefId:   document.RefId,
+			Title:   document.Title,
+			Snippet: highlightSnippet(text, terms),
+			Score:   document.Score,
+		})
+	}
+
+	// 查询像序列片段时，单独返回直接匹配任务序列的结果
+	sequences := []SearchResultItem{}
+	if len(terms) == 1 && searchSequencePattern.MatchString(terms[0]) {
+		fragment := strings.ToUpper(terms[0])
+		var tasks []models.Task
+		database.Database.Where("id IN (?)", accessibleTasks).Where("sequence LIKE ?", "%"+fragment+"%").
+			Order("updated_at DESC").Limit(searchSequenceLimit).Find(&tasks)
+		for _, task := range tasks {
+			sequences = append(sequences, SearchResultItem{
+				Kind:    SearchKindSequence,
+				TaskId:  task.ID,
+				RefId:   task.ID,
+				Title:   task.Title,
+				Snippet: highlightSnippet(task.Sequence, []string{fragment}),
+			})
+		}
+	}
+
+	fillSearchTaskTitles(list)
+	return SearchResult{List: list, Total: total, Sequences: sequences}, nil
+}
+
+// searchTerms 拆分查询词并去掉全文检索运算符
+func searchTerms(keyword string) []string {
+	var terms []string
+	for _, field := range strings.Fields(searchOperatorPattern.ReplaceAllString(keyword, " ")) {
+		if len([]rune(field)) >= 2 {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+// highlightSnippet 截取第一个命中词附近的片段，并用 <mark> 标记所有命中词
+func highlightSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	first := -1
+	for _, term := range terms {
+		if i := runeIndex(lower, []rune(strings.ToLower(term))); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	start, end := 0, len(runes)
+	if first >= 0 {
+		start = first - searchSnippetRadius
+		end = first + searchSnippetRadius
+	} else {
+		end = 2 * searchSnippetRadius
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	// 在片段中标记命中词，标记前先做 HTML 转义
+	window, windowLower := runes[start:end], lower[start:end]
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+	for i := 0; i < len(window); {
+		matched := 0
+		for _, term := range terms {
+			termRunes := []rune(strings.ToLower(term))
+			if len(termRunes) > matched && hasRunePrefix(windowLower[i:], termRunes) {
+				matched = len(termRunes)
+			}
+		}
+		if matched > 0 {
+			builder.WriteString("<mark>" + html.EscapeString(string(window[i:i+matched])) + "</mark>")
+			i += matched
+			continue
+		}
+		builder.WriteString(html.EscapeString(string(window[i])))
+		i++
+	}
+	if end < len(runes) {
+		builder.WriteString("…")
+	}
+	return builder.String()
+}
+
+func runeIndex(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if hasRunePrefix(s[i:], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasRunePrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fillSearchTaskTitles 补充结果所属任务的标题
+func fillSearchTaskTitles(list []SearchResultItem) {
+	var taskIds []uint
+	for _, item := range list {
+		if item.TaskId != 0 {
+			taskIds = append(taskIds, item.TaskId)
+		}
+	}
+	if len(taskIds) == 0 {
+		return
+	}
+	var tasks []models.Task
+	database.Database.Select("id", "title").Where("id IN ?", taskIds).Find(&tasks)
+	titles := make(map[uint]string, len(tasks))
+	for _, task := range tasks {
+		titles[task.ID] = task.Title
+	}
+	for i := range list {
+		list[i].TaskTitle = titles[list[i].TaskId]
+	}
+}
+
+// IndexTask 重建任务标题和结构域的检索文档
+func IndexTask(taskId uint) {
+	var task models.Task
+	if err := database.Database.Where("id = ?", taskId).First(&task).Error; err != nil {
+		return
+	}
+
+	documents := []models.SearchDocument{{
+		Kind:   SearchKindTask,
+		UserId: uint(task.UserId),
+		TaskId: task.ID,
+		RefId:  task.ID,
+		Title:  task.Title,
+	}}
+
+	if sequences := taskSequences(task)[1:]; len(sequences) > 0 {
+		var proteinInfos []models.ProteinInformation
+		database.Database.Where("sequence IN ?", sequences).Find(&proteinInfos)
+		for _, domain := range proteinDomainDescriptions(proteinInfos) {
+			documents = append(documents, models.SearchDocument{
+				Kind:    SearchKindDomain,
+				UserId:  uint(task.UserId),
+				TaskId:  task.ID,
+				RefId:   domain.proteinId,
+				Title:   strings.TrimSpace(domain.Accession + " " + domain.Title),
+				Content: domain.Comment,
+			})
+		}
+	}
+
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("task_id = ? AND kind IN ?", task.ID, []string{SearchKindTask, SearchKindDomain}).
+			Delete(&models.SearchDocument{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&documents).Error
+	})
+	if err != nil {
+		logger.Error("更新任务 %d 的检索索引失败: %v", task.ID, err)
+	}
+}
+
+// IndexNote 更新任务注释的检索文档，可以访问该任务的用户都能检索到
+func IndexNote(userId uint, sequenceId uint, content string) {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("kind = ? AND ref_id = ?", SearchKindNote, sequenceId).
+			Delete(&models.SearchDocument{}).Error; err != nil {
+			return err
+		}
+		if strings.TrimSpace(content) == "" {
+			return nil
+		}
+		return tx.Create(&models.SearchDocument{
+			Kind:    SearchKindNote,
+			UserId:  userId,
+			TaskId:  sequenceId,
+			RefId:   sequenceId,
+			Content: content,
+		}).Error
+	})
+	if err != nil {
+		logger.Error("更新注释检索索引失败: %v", err)
+	}
+}
+
+// removeTaskFromIndex 删除任务的检索文档
+func removeTaskFromIndex(taskId uint) {
+	if err := database.Database.Unscoped().Where("task_id = ?", taskId).Delete(&models.SearchDocument{}).Error; err != nil {
+		logger.Error("删除任务 %d 的检索索引失败: %v", taskId, err)
+	}
+}
+
+// RebuildSearchIndexIfEmpty 索引为空时（首次部署）为已有任务和注释建立索引
+func RebuildSearchIndexIfEmpty() {
+	var count int64
+	if err := database.Database.Model(&models.SearchDocument{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	logger.Info("检索索引为空，开始为已有数据建立索引...")
+	var taskIds []uint
+	database.Database.Model(&models.Task{}).Pluck("id", &taskIds)
+	for _, taskId := range taskIds {
+		IndexTask(taskId)
+	}
+	var notes []models.Note
+	database.Database.Find(&notes)
+	for _, note := range notes {
+		IndexNote(uint(note.UserId), uint(note.TaskId), note.Note)
+	}
+	logger.Info("检索索引建立完成：%d 个任务，%d 条注释", len(taskIds), len(notes))
+}
+
+// proteinDomain 蛋白质记录中解析出的结构域描述
+type proteinDomain struct {
+	BlastDescription
+	proteinId uint
+}
+
+// proteinDomainDescriptions 从蛋白质记录保存的 BlastInformation 中解析结构域，没有 accession 的记录跳过
+func proteinDomainDescriptions(proteinInfos []models.ProteinInformation) []proteinDomain {
+	var domains []proteinDomain
+	for _, info := range proteinInfos {
+		if info.BlastInformation == "" {
+			continue
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(info.BlastInformation), &raw); err != nil {
+			continue
+		}
+		blastInfo := make(map[string]string, len(raw))
+		for key, value := range raw {
+			blastInfo[key] = fmt.Sprint(value)
+		}
+		description := getDescription(blastInfo)
+		if description.Accession == "" {
+			continue
+		}
+		domains = append(domains, proteinDomain{BlastDescription: description, proteinId: info.ID})
+	}
+	return domains
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// 全局比对的仿射空位罚分：空位第一个残基和后续每个残基的得分
+const (
+	alignGapOpen   = -11
+	alignGapExtend = -1
+	// 比对使用 O(n*m) 的回溯矩阵，限制序列长度避免占用过多内存
+	maxAlignLength = 3000
+)
+
+// blosum62Order BLOSUM62 矩阵的行列顺序
+const blosum62Order = "ARNDCQEGHILKMFPSTWYVBZX*"
+
+var blosum62 = [24][24]int{
+	{4, -1, -2, -2, 0, -1, -1, 0, -2, -1, -1, -1, -1, -2, -1, 1, 0, -3, -2, 0, -2, -1, 0, -4},
+	{-1, 5, 0, -2, -3, 1, 0, -2, 0, -3, -2, 2, -1, -3, -2, -1, -1, -3, -2, -3, -1, 0, -1, -4},
+	{-2, 0, 6, 1, -3, 0, 0, 0, 1, -3, -3, 0, -2, -3, -2, 1, 0, -4, -2, -3, 3, 0, -1, -4},
+	{-2, -2, 1, 6, -3, 0, 2, -1, -1, -3, -4, -1, -3, -3, -1, 0, -1, -4, -3, -3, 4, 1, -1, -4},
+	{0, -3, -3, -3, 9, -3, -4, -3, -3, -1, -1, -3, -1, -2, -3, -1, -1, -2, -2, -1, -3, -3, -2, -4},
+	{-1, 1, 0, 0, -3, 5, 2, -2, 0, -3, -2, 1, 0, -3, -1, 0, -1, -2, -1, -2, 0, 3, -1, -4},
+	{-1, 0, 0, 2, -4, 2, 5, -2, 0, -3, -3, 1, -2, -3, -1, 0, -1, -3, -2, -2, 1, 4, -1, -4},
+	{0, -2, 0, -1, -3, -2, -2, 6, -2, -4, -4, -2, -3, -3, -2, 0, -2, -2, -3, -3, -1, -2, -1, -4},
+	{-2, 0, 1, -1, -3, 0, 0, -2, 8, -3, -3, -1, -2, -1, -2, -1, -2, -2, 2, -3, 0, 0, -1, -4},
+	{-1, -3, -3, -3, -1, -3, -3, -4, -3, 4, 2, -3, 1, 0, -3, -2, -1, -3, -1, 3, -3, -3, -1, -4},
+	{-1, -2, -3, -4, -1, -2, -3, -4, -3, 2, 4, -2, 2, 0, -3, -2, -1, -2, -1, 1, -4, -3, -1, -4},
+	{-1, 2, 0, -1, -3, 1, 1, -2, -1, -3, -2, 5, -1, -3, -1, 0, -1, -3, -2, -2, 0, 1, -1, -4},
+	{-1, -1, -2, -3, -1, 0, -2, -3, -2, 1, 2, -1, 5, 0, -2, -1, -1, -1, -1, 1, -3, -1, -1, -4},
+	{-2, -3, -3, -3, -2, -3, -3, -3, -1, 0, 0, -3, 0, 6, -4, -2, -2, 1, 3, -1, -3, -3, -1, -4},
+	{-1, -2, -2, -1, -3, -1, -1, -2, -2, -3, -3, -1, -2, -4, 7, -1, -1, -4, -3, -2, -2, -1, -2, -4},
+	{1, -1, 1, 0, -1, 0, 0, 0, -1, -2, -2, 0, -1, -2, -1, 4, 1, -3, -2, -2, 0, 0, 0, -4},
+	{0, -1, 0, -1, -1, -1, -1, -2, -2, -1, -1, -1, -1, -2, -1, 1, 5, -2, -2, 0, -1, -1, 0, -4},
+	{-3, -3, -4, -4, -2, -2, -3, -2, -2, -3, -2, -3, -1, 1, -4, -3, -2, 11, 2, -3, -4, -3, -2, -4},
+	{-2, -2, -2, -3, -2, -1, -2, -3, 2, -1, -1, -2, -1, 3, -3, -2, -2, 2, 7, -1, -3, -2, -1, -4},
+	{0, -3, -3, -3, -1, -2, -2, -3, -3, 3, 1, -2, 1, -1, -2, -2, 0, -3, -1, 4, -3, -2, -1, -4},
+	{-2, -1, 3, 4, -3, 0, 1, -1, 0, -3, -4, 0, -3, -3, -2, 0, -1, -4, -3, -3, 4, 1, -1, -4},
+	{-1, 0, 0, 1, -3, 3, 4, -2, 0, -3, -3, 1, -1, -3, -1, 0, -1, -3, -2, -2, 1, 4, -1, -4},
+	{0, -1, -1, -1, -2, -1, -1, -1, -1, -1, -1, -1, -1, -1, -2, 0, 0, -2, -1, -1, -1, -1, -1, -4},
+	{-4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, -4, 1},
+}
+
+// SequenceAlignment 两条序列的全局比对结果
+type SequenceAlignment struct {
+	AlignedA   string  `json:"alignedA"`
+	AlignedB   string  `json:"alignedB"`
+	Midline    string  `json:"midline"` // "|" 相同，":" 相似（BLOSUM62 得分为正），空格为不同或空位
+	Score      int     `json:"score"`
+	Length     int     `json:"length"`
+	Identity   float64 `json:"identity"`   // 相同残基占比对长度的百分比
+	Similarity float64 `json:"similarity"` // 相同或相似残基占比对长度的百分比
+	Gaps       int     `json:"gaps"`
+}
+
+// blosum62Index 返回残基在 BLOSUM62 矩阵中的下标，未知残基按 X 处理
+func blosum62Index(residue byte) int {
+	if residue >= 'a' && residue <= 'z' {
+		residue -= 'a' - 'A'
+	}
+	if i := strings.IndexByte(blosum62Order, residue); i >= 0 {
+		return i
+	}
+	return strings.IndexByte(blosum62Order, 'X')
+}
+
+// 回溯时的来源状态
+const (
+	alignStateMatch byte = iota // 对角线，a 和 b 各消耗一个残基
+	alignStateGapB              // b 中为空位，只消耗 a 的残基
+	alignStateGapA              // a 中为空位，只消耗 b 的残基
+)
+
+// AlignSequences 使用 Needleman-Wunsch（Gotoh 仿射空位）算法和 BLOSUM62 矩阵做全局比对
+func AlignSequences(a, b string) (SequenceAlignment, error) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return SequenceAlignment{}, errors.New("Sequence is empty.")
+	}
+	if n > maxAlignLength || m > maxAlignLength {
+		return SequenceAlignment{}, errors.New("Sequence is too long to align.")
+	}
+
+	const negInf = -1 << 29
+	idxA := make([]int, n)
+	for i := 0; i < n; i++ {
+		idxA[i] = blosum62Index(a[i])
+	}
+	idxB := make([]int, m)
+	for j := 0; j < m; j++ {
+		idxB[j] = blosum62Index(b[j])
+	}
+
+	// 三个状态的得分只保留上一行，回溯矩阵保存完整
+	cols := m + 1
+	prevM, prevX, prevY := make([]int, cols), make([]int, cols), make([]int, cols)
+	curM, curX, curY := make([]int, cols), make([]int, cols), make([]int, cols)
+	tbM := make([]byte, (n+1)*cols)
+	tbX := make([]byte, (n+1)*cols)
+	tbY := make([]byte, (n+1)*cols)
+
+	prevM[0], prevX[0], prevY[0] = 0, negInf, negInf
+	for j := 1; j <= m; j++ {
+		prevM[j], prevX[j] = negInf, negInf
+		prevY[j] = alignGapOpen + (j-1)*alignGapExtend
+		if j > 1 {
+			tbY[j] = alignStateGapA
+		}
+	}
+
+	for i := 1; i <= n; i++ {
+		curM[0], curY[0] = negInf, negInf
+		curX[0] = alignGapOpen + (i-1)*alignGapExtend
+		if i > 1 {
+			tbX[i*cols] = alignStateGapB
+		}
+		for j := 1; j <= m; j++ {
+			k := i*cols + j
+
+			// 对角线
+			best, from := prevM[j-1], alignStateMatch
+			if prevX[j-1] > best {
+				best, from = prevX[j-1], alignStateGapB
+			}
+			if prevY[j-1] > best {
+				best, from = prevY[j-1], alignStateGapA
+			}
+			curM[j] = best + blosum62[idxA[i-1]][idxB[j-1]]
+			tbM[k] = from
+
+			// b 中开启或延长空位
+			best, from = prevM[j]+alignGapOpen, alignStateMatch
+			if prevX[j]+alignGapExtend > best {
+				best, from = prevX[j]+alignGapExtend, alignStateGapB
+			}
+			if prevY[j]+alignGapOpen > best {
+				best, from = prevY[j]+alignGapOpen, alignStateGapA
+			}
+			curX[j] = best
+			tbX[k] = from
+
+			// a 中开启或延长空位
+			best, from = curM[j-1]+alignGapOpen, alignStateMatch
+			if curX[j-1]+alignGapOpen > best {
+				best, from = curX[j-1]+alignGapOpen, alignStateGapB
+			}
+			if curY[j-1]+alignGapExtend > best {
+				best, from = curY[j-1]+alignGapExtend, alignStateGapA
+			}
+			curY[j] = best
+			tbY[k] = from
+		}
+		prevM, curM = curM, prevM
+		prevX, curX = curX, prevX
+		prevY, curY = curY, prevY
+	}
+
+	score, state := prevM[m], alignStateMatch
+	if prevX[m] > score {
+		score, state = prevX[m], alignStateGapB
+	}
+	if prevY[m] > score {
+		score, state = prevY[m], alignStateGapA
+	}
+
+	// 回溯
+	var rowA, rowB []byte
+	i, j := n, m
+	for i > 0 || j > 0 {
+		k := i*cols + j
+		switch state {
+		case alignStateMatch:
+			rowA = append(rowA, a[i-1])
+			rowB = append(rowB, b[j-1])
+			state = tbM[k]
+			i--
+			j--
+		case alignStateGapB:
+			rowA = append(rowA, a[i-1])
+			rowB = append(rowB, '-')
+			state = tbX[k]
+			i--
+		case alignStateGapA:
+			rowA = append(rowA, '-')
+			rowB = append(rowB, b[j-1])
+			state = tbY[k]
+			j--
+		}
+	}
+
+	length := len(rowA)
+	midline := make([]byte, length)
+	var identical, similar, gaps int
+	for p := 0; p < length; p++ {
+		// 回溯得到的是逆序结果
+		x, y := rowA[length-1-p], rowB[length-1-p]
+		switch {
+		case x == '-' || y == '-':
+			midline[p] = ' '
+			gaps++
+		case x == y:
+			midline[p] = '|'
+			identical++
+			similar++
+		case blosum62[blosum62Index(x)][blosum62Index(y)] > 0:
+			midline[p] = ':'
+			similar++
+		default:
+			midline[p] = ' '
+		}
+	}
+	reverseBytes(rowA)
+	reverseBytes(rowB)
+
+	return SequenceAlignment{
+		AlignedA:   string(rowA),
+		AlignedB:   string(rowB),
+		Midline:    string(midline),
+		Score:      score,
+		Length:     length,
+		Identity:   float64(identical) * 100 / float64(length),
+		Similarity: float64(similar) * 100 / float64(length),
+		Gaps:       gaps,
+	}, nil
+}
+
+func reverseBytes(s []byte) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAlignSequences(t *testing.T) {
+	tests := []struct {
+		name           string
+		a, b           string
+		wantA, wantB   string
+		wantMidline    string
+		wantScore      int
+		wantIdentity   float64
+		wantSimilarity float64
+		wantGaps       int
+	}{
+		{
+			name:           "相同序列",
+			a:              "MKTAYIAKQR",
+			b:              "MKTAYIAKQR",
+			wantA:          "MKTAYIAKQR",
+			wantB:          "MKTAYIAKQR",
+			wantMidline:    "||||||||||",
+			wantScore:      49,
+			wantIdentity:   100,
+			wantSimilarity: 100,
+		},
+		{
+			name:           "缺失一个残基",
+			a:              "MKTAYIAKQRQISF",
+			b:              "MKTAIAKQRQISF",
+			wantA:          "MKTAYIAKQRQISF",
+			wantB:          "MKTA-IAKQRQISF",
+			wantMidline:    "|||| |||||||||",
+			wantScore:      68 - 7 + alignGapOpen,
+			wantIdentity:   100 * 13.0 / 14,
+			wantSimilarity: 100 * 13.0 / 14,
+			wantGaps:       1,
+		},
+		{
+			name:           "相似残基",
+			a:              "KKKK",
+			b:              "RRRR",
+			wantA:          "KKKK",
+			wantB:          "RRRR",
+			wantMidline:    "::::",
+			wantScore:      8,
+			wantIdentity:   0,
+			wantSimilarity: 100,
+		},
+		{
+			name:           "不同残基",
+			a:              "WWW",
+			b:              "DDD",
+			wantA:          "WWW",
+			wantB:          "DDD",
+			wantMidline:    "   ",
+			wantScore:      -12,
+			wantIdentity:   0,
+			wantSimilarity: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AlignSequences(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("AlignSequences: %v", err)
+			}
+			if got.AlignedA != tt.wantA || got.AlignedB != tt.wantB || got.Midline != tt.wantMidline {
+				t.Errorf("alignment =\n%s\n%s\n%s\nwant\n%s\n%s\n%s", got.AlignedA, got.Midline, got.AlignedB, tt.wantA, tt.wantMidline, tt.wantB)
+			}
+			if got.Score != tt.wantScore {
+				t.Errorf("Score = %d, want %d", got.Score, tt.wantScore)
+			}
+			if got.Length != len(tt.wantA) || got.Gaps != tt.wantGaps {
+				t.Errorf("Length = %d Gaps = %d, want %d and %d", got.Length, got.Gaps, len(tt.wantA), tt.wantGaps)
+			}
+			if math.Abs(got.Identity-tt.wantIdentity) > 1e-9 || math.Abs(got.Similarity-tt.wantSimilarity) > 1e-9 {
+				t.Errorf("Identity = %v Similarity = %v, want %v and %v", got.Identity, got.Similarity, tt.wantIdentity, tt.wantSimilarity)
+			}
+		})
+	}
+}
+
+func TestAlignSequencesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"空序列", "", "MKT"},
+		{"序列太长", strings.Repeat("A", maxAlignLength+1), "MKT"},
+	}
+	for _, tt := range tests {
+		if _, err := AlignSequences(tt.a, tt.b); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+	"errors"
+	"fmt"
+)
+
+// 分享状态
+const (
+	ShareStatusPending = 0
+	ShareStatusAgreed  = 1
+	ShareStatusRefused = 2
+	ShareStatusRevoked = 3
+)
+
+// 分享权限，后一级包含前一级的全部权限
+const (
+	SharePermissionView     = "view"
+	SharePermissionAnnotate = "annotate"
+	SharePermissionRerun    = "rerun"
+	// 任务拥有者拥有全部权限
+	SharePermissionOwner = "owner"
+)
+
+var sharePermissionLevels = map[string]int{
+	SharePermissionView:     1,
+	SharePermissionAnnotate: 2,
+	SharePermissionRerun:    3,
+	SharePermissionOwner:    4,
+}
+
+// ShareItem 分享记录
+type ShareItem struct {
+	ID         uint   `json:"id"`
+	TaskId     uint   `json:"taskId"`
+	TaskTitle  string `json:"taskTitle"`
+	FromId     uint   `json:"fromId"`
+	FromEmail  string `json:"fromEmail"`
+	ToId       uint   `json:"toId"`
+	ToEmail    string `json:"toEmail"`
+	Status     int64  `json:"status"`
+	Permission string `json:"permission"`
+	CreatedAt  int64  `json:"createdAt"`
+	UpdatedAt  int64  `json:"updatedAt"`
+}
+
+// CreateShare 将自己的任务分享给其他用户，已有未处理或已同意的分享时只更新权限
+func CreateShare(fromId uint, fromEmail string, toId uint, taskId uint, permission string) error {
+	if permission == "" {
+		permission = SharePermissionView
+	}
+	if level := sharePermissionLevels[permission]; level == 0 || permission == SharePermissionOwner {
+		return errors.New("Invalid permission.")
+	}
+	if toId == fromId {
+		return errors.New("Cannot share with yourself.")
+	}
+
+	var task models.Task
+	if err := database.Database.Where("id = ? AND user_id = ?", taskId, fromId).First(&task).Error; err != nil {
+		return errors.New("Task not found.")
+	}
+	var count int64
+	if database.Database.Model(&models.User{}).Where("id = ?", toId).Count(&count); count == 0 {
+		return errors.New("User not found.")
+	}
+
+	var existing models.Share
+	database.Database.Where("task_id = ? AND from_id = ? AND to_id = ? AND status IN ?", taskId, fromId, toId,
+		[]int64{ShareStatusPending, ShareStatusAgreed}).Find(&existing)
+	if existing.ID != 0 {
+		if err := database.Database.Model(&existing).Update("permission", permission).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		return nil
+	}
+
+	share := models.Share{
+		FromId:     fromId,
+		ToId:       toId,
+		TaskId:     taskId,
+		SeqId:      taskId,
+		Status:     ShareStatusPending,
+		Permission: permission,
+	}
+	if err := database.Database.Create(&share).Error; err != nil {
+		return errors.New("Network error.")
+	}
+
+	CreateNotification(toId, NotificationShareNew, "New share",
+		fmt.Sprintf("%s shared a task with you.", fromEmail), taskId)
+	return nil
+}
+
+// AgreeShare 接收者同意分享，之后可按权限访问原任务
+func AgreeShare(userId uint, shareId uint) error {
+	share, err := findShare(shareId, "to_id = ?", userId)
+	if err != nil {
+		return err
+	}
+	if share.Status != ShareStatusPending {
+		return errors.New("Share has already been handled.")
+	}
+	if err := database.Database.Model(&share).Update("status", ShareStatusAgreed).Error; err != nil {
+		return errors.New("Network error.")
+	}
+
+	var task models.Task
+	database.Database.Where("id = ?", share.TaskId).Find(&task)
+	CreateNotification(share.FromId, NotificationShareAgreed, "Share accepted",
+		fmt.Sprintf("Your share of task \"%s\" was accepted.", task.Title), share.TaskId)
+	return nil
+}
+
+// RefuseShare 接收者拒绝分享
+func RefuseShare(userId uint, shareId uint) error {
+	share, err := findShare(shareId, "to_id = ?", userId)
+	if err != nil {
+		return err
+	}
+	if share.Status != ShareStatusPending {
+		return errors.New("Share has already been handled.")
+	}
+	if err := database.Database.Model(&share).Update("status", ShareStatusRefused).Error; err != nil {
+		return errors.New("Network error.")
+	}
+
+	CreateNotification(share.FromId, NotificationShareRefused, "Share refused",
+		fmt.Sprintf("Your share of task #%d was refused.", share.TaskId), share.TaskId)
+	return nil
+}
+
+// RevokeShare 发送者撤销分享，或接收者退出分享，撤销后接收者立即失去访问权限
+func RevokeShare(userId uint, shareId uint) error {
+	share, err := findShare(shareId, "from_id = ? OR to_id = ?", userId, userId)
+	if err != nil {
+		return err
+	}
+	if share.Status != ShareStatusPending && share.Status != ShareStatusAgreed {
+		return errors.New("Share is no longer active.")
+	}
+	if err := database.Database.Model(&share).Update("status", ShareStatusRevoked).Error; err != nil {
+		return errors.New("Network error.")
+	}
+
+	if share.FromId == userId {
+		CreateNotification(share.ToId, NotificationShareRevoked, "Share revoked",
+			fmt.Sprintf("Access to shared task #%d was revoked.", share.TaskId), share.TaskId)
+	} else {
+		CreateNotification(share.FromId, NotificationShareRevoked, "Share removed",
+			fmt.Sprintf("The recipient left your share of task #%d.", share.TaskId), share.TaskId)
+	}
+	return nil
+}
+
+// UpdateSharePermission 发送者修改分享权限
+func UpdateSharePermission(userId uint, shareId uint, permission string) error {
+	if level := sharePermissionLevels[permission]; level == 0 || permission == SharePermissionOwner {
+		return errors.New("Invalid permission.")
+	}
+	share, err := findShare(shareId, "from_id = ?", userId)
+	if err != nil {
+		return err
+	}
+	if share.Status != ShareStatusPending && share.Status != ShareStatusAgreed {
+		return errors.New("Share is no longer active.")
+	}
+	if err := database.Database.Model(&share).Update("permission", permission).Error; err != nil {
+		return errors.New("Network error.")
+	}
+	return nil
+}
+
+// ListOutgoingShares 查询用户发出的分享
+func ListOutgoingShares(userId uint, status *int64) ([]ShareItem, error) {
+	return listShares("from_id = ?", userId, status)
+}
+
+// ListIncomingShares 查询用户收到的分享
+func ListIncomingShares(userId uint, status *int64) ([]ShareItem, error) {
+	return listShares("to_id = ?", userId, status)
+}
+
+func listShares(condition string, userId uint, status *int64) ([]ShareItem, error) {
+	db := database.Database.Where(condition, userId)
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	var shares []models.Share
+	if err := db.Order("created_at DESC").Find(&shares).Error; err != nil {
+		return nil, err
+	}
+
+	var taskIds, userIds []uint
+	for _, share := range shares {
+		taskIds = append(taskIds, share.TaskId)
+		userIds = append(userIds, share.FromId, share.ToId)
+	}
+	titles := make(map[uint]string)
+	if len(taskIds) > 0 {
+		var tasks []models.Task
+		database.Database.Unscoped().Select("id", "title").Where("id IN ?", taskIds).Find(&tasks)
+		for _, task := range tasks {
+			titles[task.ID] = task.Title
+		}
+	}
+	emails := make(map[uint]string)
+	if len(userIds) > 0 {
+		var users []models.User
+		database.Database.Where("id IN ?", userIds).Find(&users)
+		for _, user := range users {
+			emails[user.ID] = user.Email
+		}
+	}
+
+	result := make([]ShareItem, 0, len(shares))
+	for _, share := range shares {
+		permission := share.Permission
+		if permission == "" {
+			permission = SharePermissionView
+		}
+		result = append(result, ShareItem{
+			ID:         share.ID,
+			TaskId:     share.TaskId,
+			TaskTitle:  titles[share.TaskId],
+			FromId:     share.FromId,
+			FromEmail:  emails[share.FromId],
+			ToId:       share.ToId,
+			ToEmail:    emails[share.ToId],
+			Status:     share.Status,
+			Permission: permission,
+			CreatedAt:  share.CreatedAt.UnixMilli(),
+			UpdatedAt:  share.UpdatedAt.UnixMilli(),
+		})
+	}
+	return result, nil
+}
+
+// findShare 按条件查找与用户相关的分享记录
+func findShare(shareId uint, condition string, args ...interface{}) (models.Share, error) {
+	var share models.Share
+	if err := database.Database.Where("id = ?", shareId).Where(condition, args...).First(&share).Error; err != nil {
+		return models.Share{}, errors.New("Share record not found")
+	}
+	return share, nil
+}
+
+// sharedTaskIds 返回用户已同意的分享中的任务ID子查询
+func sharedTaskIds(userId uint) interface{} {
+	return database.Database.Model(&models.Share{}).Select("task_id").Where("to_id = ? AND status = ?", userId, ShareStatusAgreed)
+}
+
+// sharedTaskPermissions 返回用户通过分享获得的各任务的最高权限
+func sharedTaskPermissions(userId uint, taskIds []uint) map[uint]string {
+	result := make(map[uint]string)
+	if len(taskIds) == 0 {
+		return result
+	}
+	var shares []models.Share
+	database.Database.Where("to_id = ? AND status = ? AND task_id IN ?", userId, ShareStatusAgreed, taskIds).Find(&shares)
+	for _, share := range shares {
+		permission := share.Permission
+		if permission == "" {
+			permission = SharePermissionView
+		}
+		if sharePermissionLevels[permission] > sharePermissionLevels[result[share.TaskId]] {
+			result[share.TaskId] = permission
+		}
+	}
+	return result
+}
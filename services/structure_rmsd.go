@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// caAtom 残基的 Cα 原子
+type caAtom struct {
+	Residue string
+	X, Y, Z float64
+}
+
+// readCAAtoms 读取 PDB 文件第一个模型中每个残基的 Cα 原子
+func readCAAtoms(pdbPath string) ([]caAtom, error) {
+	file, err := os.Open(pdbPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开PDB文件: %v", err)
+	}
+	defer file.Close()
+
+	var atoms []caAtom
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "ENDMDL") {
+			break
+		}
+		if !strings.HasPrefix(line, "ATOM") || len(line) < 54 || strings.TrimSpace(line[12:16]) != "CA" {
+			continue
+		}
+		// 同一残基的备用位置只取第一个
+		residueKey := line[21:27]
+		if seen[residueKey] {
+			continue
+		}
+		residue, ok := atomMap[strings.TrimSpace(line[17:20])]
+		if !ok {
+			continue
+		}
+		x, errX := strconv.ParseFloat(strings.TrimSpace(line[30:38]), 64)
+		y, errY := strconv.ParseFloat(strings.TrimSpace(line[38:46]), 64)
+		z, errZ := strconv.ParseFloat(strings.TrimSpace(line[46:54]), 64)
+		if errX != nil || errY != nil || errZ != nil {
+			continue
+		}
+		seen[residueKey] = true
+		atoms = append(atoms, caAtom{Residue: residue, X: x, Y: y, Z: z})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取PDB文件时出错: %v", err)
+	}
+	return atoms, nil
+}
+
+// StructureRMSD 两个模型最优叠合后的 Cα RMSD
+type StructureRMSD struct {
+	RMSD         float64 `json:"rmsd"`
+	AlignedAtoms int     `json:"alignedAtoms"`
+}
+
+// CalculateCARMSD 按两个模型 Cα 序列的全局比对配对原子，计算最优叠合后的 RMSD
+func CalculateCARMSD(pdbPathA, pdbPathB string) (StructureRMSD, error) {
+	atomsA, err := readCAAtoms(pdbPathA)
+	if err != nil {
+		return StructureRMSD{}, err
+	}
+	atomsB, err := readCAAtoms(pdbPathB)
+	if err != nil {
+		return StructureRMSD{}, err
+	}
+
+	var seqA, seqB strings.Builder
+	for _, atom := range atomsA {
+		seqA.WriteString(atom.Residue)
+	}
+	for _, atom := range atomsB {
+		seqB.WriteString(atom.Residue)
+	}
+	alignment, err := AlignSequences(seqA.String(), seqB.String())
+	if err != nil {
+		return StructureRMSD{}, err
+	}
+
+	// 比对中两边都不是空位的列即为配对的原子
+	var pairsA, pairsB [][3]float64
+	i, j := 0, 0
+	for p := 0; p < alignment.Length; p++ {
+		gapA, gapB := alignment.AlignedA[p] == '-', alignment.AlignedB[p] == '-'
+		if !gapA && !gapB {
+			pairsA = append(pairsA, [3]float64{atomsA[i].X, atomsA[i].Y, atomsA[i].Z})
+			pairsB = append(pairsB, [3]float64{atomsB[j].X, atomsB[j].Y, atomsB[j].Z})
+		}
+		if !gapA {
+			i++
+		}
+		if !gapB {
+			j++
+		}
+	}
+	if len(pairsA) < 3 {
+		return StructureRMSD{}, errors.New("Too few aligned residues.")
+	}
+
+	return StructureRMSD{RMSD: superposeRMSD(pairsA, pairsB), AlignedAtoms: len(pairsA)}, nil
+}
+
+// superposeRMSD 使用 Horn 四元数方法求两组已配对坐标最优叠合后的 RMSD
+func superposeRMSD(a, b [][3]float64) float64 {
+	n := float64(len(a))
+	var centerA, centerB [3]float64
+	for k := range a {
+		for d := 0; d < 3; d++ {
+			centerA[d] += a[k][d] / n
+			centerB[d] += b[k][d] / n
+		}
+	}
+
+	// 协方差矩阵 S 和两组坐标的平方和
+	var s [3][3]float64
+	var sumA, sumB float64
+	for k := range a {
+		var p, q [3]float64
+		for d := 0; d < 3; d++ {
+			p[d] = a[k][d] - centerA[d]
+			q[d] = b[k][d] - centerB[d]
+			sumA += p[d] * p[d]
+			sumB += q[d] * q[d]
+		}
+		for r := 0; r < 3; r++ {
+			for c := 0; c < 3; c++ {
+				s[r][c] += p[r] * q[c]
+			}
+		}
+	}
+
+	sxx, sxy, sxz := s[0][0], s[0][1], s[0][2]
+	syx, syy, syz := s[1][0], s[1][1], s[1][2]
+	szx, szy, szz := s[2][0], s[2][1], s[2][2]
+	key := [4][4]float64{
+		{sxx + syy + szz, syz - szy, szx - sxz, sxy - syx},
+		{syz - szy, sxx - syy - szz, sxy + syx, szx + sxz},
+		{szx - sxz, sxy + syx, -sxx + syy - szz, syz + szy},
+		{sxy - syx, szx + sxz, syz + szy, -sxx - syy + szz},
+	}
+
+	eigenvalues := jacobiEigenvalues4(key)
+	maxEigen := eigenvalues[0]
+	for _, value := range eigenvalues[1:] {
+		if value > maxEigen {
+			maxEigen = value
+		}
+	}
+
+	msd := (sumA + sumB - 2*maxEigen) / n
+	if msd < 0 {
+		msd = 0
+	}
+	return math.Sqrt(msd)
+}
+
+// jacobiEigenvalues4 用 Jacobi 旋转法求 4x4 对称矩阵的特征值
+func jacobiEigenvalues4(m [4][4]float64) [4]float64 {
+	for sweep := 0; sweep < 50; sweep++ {
+		var off float64
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				off += m[p][q] * m[p][q]
+			}
+		}
+		if off < 1e-18 {
+			break
+		}
+
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if math.Abs(m[p][q]) < 1e-30 {
+					continue
+				}
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				// 对第 p、q 行列做旋转，使 m[p][q] 变为 0
+				for k := 0; k < 4; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p] = c*mkp - s*mkq
+					m[k][q] = s*mkp + c*mkq
+				}
+				for k := 0; k < 4; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k] = c*mpk - s*mqk
+					m[q][k] = s*mpk + c*mqk
+				}
+			}
+		}
+	}
+	return [4]float64{m[0][0], m[1][1], m[2][2], m[3][3]}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// transformPDB 对 PDB 中所有原子绕 z 轴旋转 angle 度、绕 x 轴旋转 angle/2 度后平移
+func transformPDB(t *testing.T, pdb string, angle float64, shift vec3) string {
+	t.Helper()
+	cz, sz := math.Cos(degrees(angle)), math.Sin(degrees(angle))
+	cx, sx := math.Cos(degrees(angle/2)), math.Sin(degrees(angle/2))
+
+	lines := strings.Split(pdb, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "ATOM") {
+			continue
+		}
+		var p vec3
+		for d := 0; d < 3; d++ {
+			value, err := strconv.ParseFloat(strings.TrimSpace(line[30+8*d:38+8*d]), 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p[d] = value
+		}
+		p = vec3{cz*p[0] - sz*p[1], sz*p[0] + cz*p[1], p[2]}
+		p = vec3{p[0], cx*p[1] - sx*p[2], sx*p[1] + cx*p[2]}
+		p = p.add(shift)
+		lines[i] = line[:30] + fmt.Sprintf("%8.3f%8.3f%8.3f", p[0], p[1], p[2]) + line[54:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCalculateCARMSD(t *testing.T) {
+	sequence := "MKTAYIAKQRQISFVKSHFSRQ"
+	helix := fakeHelixPDB(sequence)
+
+	tests := []struct {
+		name        string
+		a, b        string
+		wantRMSD    float64
+		wantAligned int
+	}{
+		{"相同模型", helix, helix, 0, len(sequence)},
+		{"旋转平移后的模型", helix, transformPDB(t, helix, 73, vec3{12.5, -4, 30}), 0, len(sequence)},
+		{"多出残基的模型只配对相同部分", helix, fakeHelixPDB(sequence + "GGGG"), 0, len(sequence)},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pathA := filepath.Join(dir, fmt.Sprintf("%d_a.pdb", i))
+			pathB := filepath.Join(dir, fmt.Sprintf("%d_b.pdb", i))
+			if err := os.WriteFile(pathA, []byte(tt.a), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(pathB, []byte(tt.b), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := CalculateCARMSD(pathA, pathB)
+			if err != nil {
+				t.Fatalf("CalculateCARMSD: %v", err)
+			}
+			// PDB 坐标保留 3 位小数
+			if math.Abs(got.RMSD-tt.wantRMSD) > 0.01 {
+				t.Errorf("RMSD = %v, want %v", got.RMSD, tt.wantRMSD)
+			}
+			if got.AlignedAtoms != tt.wantAligned {
+				t.Errorf("AlignedAtoms = %d, want %d", got.AlignedAtoms, tt.wantAligned)
+			}
+		})
+	}
+
+	// 少于 3 个残基无法叠合
+	short := filepath.Join(dir, "short.pdb")
+	os.WriteFile(short, []byte(fakeHelixPDB("MK")), 0644)
+	if _, err := CalculateCARMSD(short, short); err == nil {
+		t.Error("expected error for too few aligned residues")
+	}
+}
+
+func TestSuperposeRMSD(t *testing.T) {
+	square := [][3]float64{{1, 1, 0}, {-1, 1, 0}, {-1, -1, 0}, {1, -1, 0}}
+	tests := []struct {
+		name string
+		b    [][3]float64
+		want float64
+	}{
+		{"相同坐标", square, 0},
+		{"平移", [][3]float64{{11, 1, 5}, {9, 1, 5}, {9, -1, 5}, {11, -1, 5}}, 0},
+		{"旋转 90 度", [][3]float64{{-1, 1, 0}, {-1, -1, 0}, {1, -1, 0}, {1, 1, 0}}, 0},
+		// 放大一倍后每个点偏离 |a|，旋转不能减小距离
+		{"放大一倍", [][3]float64{{2, 2, 0}, {-2, 2, 0}, {-2, -2, 0}, {2, -2, 0}}, math.Sqrt2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := superposeRMSD(square, tt.b); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("superposeRMSD = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -98,6 +98,7 @@ func Superimpose(paths []string, title string, userId int64) SuperimposeResult {
 	if err := database.Database.Create(&mainTask).Error; err != nil {
 		return SuperimposeResult{Error: "DB error."}
 	}
+	IndexTask(mainTask.ID)
 
 	return SuperimposeResult{ID: mainTask.ID}
 }
@@ -142,6 +143,7 @@ func Single(path string, title string, userId int64) SuperimposeResult {
 	if err := database.Database.Create(&mainTask).Error; err != nil {
 		return SuperimposeResult{Error: "DB error."}
 	}
+	IndexTask(mainTask.ID)
 	return SuperimposeResult{ID: mainTask.ID}
 }
 
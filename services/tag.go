@@ -0,0 +1,164 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// TagItem 标签及其任务数量
+type TagItem struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	TaskCount int64  `json:"taskCount"`
+}
+
+// ListTags 查询用户的所有标签
+func ListTags(userId uint) ([]TagItem, error) {
+	var tags []models.Tag
+	if err := database.Database.Where("user_id = ?", userId).Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	// 统计每个标签下的任务数量
+	type tagCount struct {
+		TagId uint
+		Count int64
+	}
+	var counts []tagCount
+	database.Database.Model(&models.TaskTag{}).
+		Select("task_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN tasks ON tasks.id = task_tags.task_id AND tasks.deleted_at IS NULL").
+		Where("tasks.user_id = ?", userId).
+		Group("task_tags.tag_id").
+		Scan(&counts)
+	countMap := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		countMap[c.TagId] = c.Count
+	}
+
+	result := make([]TagItem, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, TagItem{
+			ID:        tag.ID,
+			Name:      tag.Name,
+			Color:     tag.Color,
+			TaskCount: countMap[tag.ID],
+		})
+	}
+	return result, nil
+}
+
+// CreateTag 创建标签，同一用户下标签名不能重复
+func CreateTag(userId uint, name string, color string) (TagItem, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 64 {
+		return TagItem{}, errors.New("Invalid tag name.")
+	}
+	color = strings.TrimSpace(color)
+	if color != "" && !annotationColorPattern.MatchString(color) {
+		return TagItem{}, errors.New("Invalid color, expected #RRGGBB.")
+	}
+
+	var count int64
+	database.Database.Model(&models.Tag{}).Where("user_id = ? AND name = ?", userId, name).Count(&count)
+	if count > 0 {
+		return TagItem{}, errors.New("Tag already exists.")
+	}
+
+	tag := models.Tag{UserId: userId, Name: name, Color: color}
+	if err := database.Database.Create(&tag).Error; err != nil {
+		return TagItem{}, errors.New("Network error.")
+	}
+	return TagItem{ID: tag.ID, Name: tag.Name, Color: tag.Color}, nil
+}
+
+// DeleteTag 删除标签及其与任务的关联
+func DeleteTag(userId uint, tagId uint) error {
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("id = ? AND user_id = ?", tagId, userId).Delete(&models.Tag{})
+		if result.Error != nil {
+			return errors.New("Network error.")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("Tag not found.")
+		}
+		if err := tx.Where("tag_id = ?", tagId).Delete(&models.TaskTag{}).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		return nil
+	})
+}
+
+// TagTasks 批量为任务添加或移除标签，只处理属于该用户的任务和标签
+func TagTasks(userId uint, taskIds []uint, tagIds []uint, remove bool) error {
+	ownedTasks, err := ownedTaskIds(userId, taskIds)
+	if err != nil {
+		return errors.New("Network error.")
+	}
+	var ownedTags []uint
+	if err := database.Database.Model(&models.Tag{}).Where("id IN ? AND user_id = ?", tagIds, userId).Pluck("id", &ownedTags).Error; err != nil {
+		return errors.New("Network error.")
+	}
+	if len(ownedTasks) == 0 || len(ownedTags) == 0 {
+		return errors.New("Task or tag not found.")
+	}
+
+	if remove {
+		if err := database.Database.Where("task_id IN ? AND tag_id IN ?", ownedTasks, ownedTags).Delete(&models.TaskTag{}).Error; err != nil {
+			return errors.New("Network error.")
+		}
+		return nil
+	}
+
+	return database.Database.Transaction(func(tx *gorm.DB) error {
+		for _, taskId := range ownedTasks {
+			for _, tagId := range ownedTags {
+				taskTag := models.TaskTag{TaskId: taskId, TagId: tagId}
+				if err := tx.Where(taskTag).FirstOrCreate(&taskTag).Error; err != nil {
+					return errors.New("Network error.")
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// tagsForTasks 查询一组任务的标签，按任务ID分组
+func tagsForTasks(taskIds []uint) map[uint][]TagItem {
+	result := make(map[uint][]TagItem)
+	if len(taskIds) == 0 {
+		return result
+	}
+	type taskTagRow struct {
+		TaskId uint
+		ID     uint
+		Name   string
+		Color  string
+	}
+	var rows []taskTagRow
+	database.Database.Model(&models.TaskTag{}).
+		Select("task_tags.task_id, tags.id, tags.name, tags.color").
+		Joins("JOIN tags ON tags.id = task_tags.tag_id AND tags.deleted_at IS NULL").
+		Where("task_tags.task_id IN ?", taskIds).
+		Order("tags.name").
+		Scan(&rows)
+	for _, row := range rows {
+		result[row.TaskId] = append(result[row.TaskId], TagItem{ID: row.ID, Name: row.Name, Color: row.Color})
+	}
+	return result
+}
+
+// ownedTaskIds 过滤出属于该用户的任务ID
+func ownedTaskIds(userId uint, taskIds []uint) ([]uint, error) {
+	var ids []uint
+	if len(taskIds) == 0 {
+		return ids, nil
+	}
+	err := database.Database.Model(&models.Task{}).Where("id IN ? AND user_id = ?", taskIds, userId).Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/models"
+)
+
+// TaskPermission 返回用户对任务的权限，拥有者为 owner，已同意的分享按分享权限，无权访问时返回空字符串
+func TaskPermission(userId uint, taskId uint) string {
+	var task models.Task
+	if err := database.Database.Select("id", "user_id").Where("id = ?", taskId).First(&task).Error; err != nil {
+		return ""
+	}
+	if uint(task.UserId) == userId {
+		return SharePermissionOwner
+	}
+
+	return sharedTaskPermissions(userId, []uint{taskId})[taskId]
+}
+
+// taskViewerIds 返回可以查看任务的所有用户：拥有者和已同意分享的用户
+func taskViewerIds(task models.Task) []uint {
+	candidates := []uint{uint(task.UserId)}
+	var sharedIds []uint
+	database.Database.Model(&models.Share{}).Distinct("to_id").Where("task_id = ? AND status = ?", task.ID, ShareStatusAgreed).Pluck("to_id", &sharedIds)
+	candidates = append(candidates, sharedIds...)
+
+	viewers := make([]uint, 0, len(candidates))
+	seen := make(map[uint]bool, len(candidates))
+	for _, userId := range candidates {
+		if seen[userId] {
+			continue
+		}
+		seen[userId] = true
+		if UserCanAccessTask(userId, task.ID) {
+			viewers = append(viewers, userId)
+		}
+	}
+	return viewers
+}
+
+// UserHasTaskPermission 判断用户对任务是否至少拥有指定权限
+func UserHasTaskPermission(userId uint, taskId uint, permission string) bool {
+	granted := TaskPermission(userId, taskId)
+	return granted != "" && sharePermissionLevels[granted] >= sharePermissionLevels[permission]
+}
+
+// UserCanAccessTask 判断用户是否可以查看任务
+func UserCanAccessTask(userId uint, taskId uint) bool {
+	return UserHasTaskPermission(userId, taskId, SharePermissionView)
+}
+
+// UserHasProteinPermission 判断用户是否对 ModelId 中包含该蛋白质的某个任务拥有指定权限
+func UserHasProteinPermission(userId uint, proteinId uint, permission string) bool {
+	query, args := modelIdMatch(proteinId)
+	var count int64
+	if err := database.Database.Model(&models.Task{}).Where("user_id = ?", userId).Where(query, args...).Count(&count).Error; err != nil {
+		return false
+	}
+	if count > 0 {
+		return true
+	}
+
+	// 通过分享获得的任务
+	var taskIds []uint
+	database.Database.Model(&models.Task{}).Where("id IN (?)", sharedTaskIds(userId)).Where(query, args...).Pluck("id", &taskIds)
+	for _, taskId := range taskIds {
+		if UserHasTaskPermission(userId, taskId, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserCanAccessProtein 判断用户是否可以查看该蛋白质模型
+func UserCanAccessProtein(userId uint, proteinId uint) bool {
+	return UserHasProteinPermission(userId, proteinId, SharePermissionView)
+}
@@ -0,0 +1,590 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 任务流水线的阶段
+const (
+	StageRpsblast     = "rpsblast"
+	StageRpsbproc     = "rpsbproc"
+	StageDomains      = "domains"
+	StageFold         = "fold"
+	StageParameters   = "parameters"
+	StageRamachandran = "ramachandran"
+	StageStructureNum = "structure_num"
+	StageModelId      = "model_id"
+)
+
+// 执行中超过该时间的阶段视为已中断（如服务重启），允许重新运行
+const taskStageStaleAfter = 6 * time.Hour
+
+// pipelineStage 流水线中的一个阶段
+type pipelineStage struct {
+	Name       string
+	DependsOn  []string
+	PerProtein bool // 任务中的每个蛋白质执行一次
+	BlastOnly  bool // 只有 blast 任务有该阶段
+}
+
+// taskPipeline blast 和 fold 任务的流水线，按执行顺序排列，依赖关系构成有向无环图
+// 按蛋白质执行的阶段依赖同一蛋白质的上游阶段；子序列的蛋白质由结构域提取阶段创建，因此 fold 依赖 domains
+var taskPipeline = []pipelineStage{
+	{Name: StageRpsblast, BlastOnly: true},
+	{Name: StageRpsbproc, DependsOn: []string{StageRpsblast}, BlastOnly: true},
+	{Name: StageDomains, DependsOn: []string{StageRpsbproc}, BlastOnly: true},
+	{Name: StageFold, DependsOn: []string{StageDomains}, PerProtein: true},
+	{Name: StageParameters, DependsOn: []string{StageFold}, PerProtein: true},
+	{Name: StageRamachandran, DependsOn: []string{StageFold}, PerProtein: true},
+	{Name: StageStructureNum, DependsOn: []string{StageFold}, PerProtein: true},
+	{Name: StageModelId, DependsOn: []string{StageFold}},
+}
+
+// blastStages 在任务工作目录中依次执行的结构域搜索阶段
+var blastStages = []string{StageRpsblast, StageRpsbproc, StageDomains}
+
+// findPipelineStage 按名称查找流水线阶段
+func findPipelineStage(name string) (pipelineStage, bool) {
+	for _, stage := range taskPipeline {
+		if stage.Name == name {
+			return stage, true
+		}
+	}
+	return pipelineStage{}, false
+}
+
+// isPipelineTask 判断任务是否按流水线执行（blast 和 fold 任务）
+func isPipelineTask(task models.Task) bool {
+	return task.Type == 1 || task.Type == 2
+}
+
+// taskPipelineStages 返回任务适用的流水线阶段
+func taskPipelineStages(task models.Task) []pipelineStage {
+	var stages []pipelineStage
+	for _, stage := range taskPipeline {
+		if stage.BlastOnly && task.Type != 1 {
+			continue
+		}
+		stages = append(stages, stage)
+	}
+	return stages
+}
+
+// setTaskStageStatus 更新任务阶段的状态，记录不存在时创建
+// 进入 running 时记录开始时间并增加执行次数，结束时记录完成时间
+func setTaskStageStatus(taskId uint, proteinId uint, stage string, status string, errMsg string) {
+	// 使用 map 条件，ProteinId 为 0 时也作为查询条件
+	var record models.TaskStage
+	if err := database.Database.Where(map[string]interface{}{"task_id": taskId, "stage": stage, "protein_id": proteinId}).
+		Attrs(models.TaskStage{Status: JobStatusPending}).FirstOrCreate(&record).Error; err != nil {
+		logger.Error("创建任务 %d 的%s阶段记录失败: %v", taskId, stage, err)
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status, "error_message": errMsg}
+	switch status {
+	case JobStatusRunning:
+		updates["started_at"] = now
+		updates["finished_at"] = nil
+		updates["attempts"] = gorm.Expr("attempts + 1")
+	case JobStatusPending:
+		updates["started_at"] = nil
+		updates["finished_at"] = nil
+	default:
+		updates["finished_at"] = now
+	}
+	if err := database.Database.Model(&record).Updates(updates).Error; err != nil {
+		logger.Error("更新任务 %d 的%s阶段状态失败: %v", taskId, stage, err)
+	}
+}
+
+// runStage 执行一个阶段并为其中的流水线任务记录状态、耗时和错误，返回阶段的执行结果
+// proteinId 为 0 表示任务级别的阶段
+func runStage(tasks []models.Task, proteinId uint, stage string, fn func() error) (err error) {
+	var recorded []models.Task
+	for _, task := range tasks {
+		if isPipelineTask(task) {
+			recorded = append(recorded, task)
+			setTaskStageStatus(task.ID, proteinId, stage, JobStatusRunning, "")
+		}
+	}
+	refreshTaskStatuses(recorded)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		status, message := JobStatusSucceeded, ""
+		if err != nil {
+			status, message = JobStatusFailed, err.Error()
+			logger.Error("%s阶段执行失败（蛋白质 %d）: %v", stage, proteinId, err)
+		}
+		for _, task := range recorded {
+			setTaskStageStatus(task.ID, proteinId, stage, status, message)
+		}
+		refreshTaskStatuses(recorded)
+	}()
+	return fn()
+}
+
+// refreshTaskStatuses 重新读取任务并刷新状态
+func refreshTaskStatuses(tasks []models.Task) {
+	for _, task := range tasks {
+		var current models.Task
+		if err := database.Database.Where("id = ?", task.ID).First(&current).Error; err != nil {
+			continue
+		}
+		RefreshTaskStatus(current)
+	}
+}
+
+// proteinStageSteps 返回模型生成之后按蛋白质执行的阶段，force 为 true 时重新查询 RCSB 结构数量
+// 这些阶段调用外部脚本和 RCSB 接口，测试中替换为不依赖外部环境的实现
+var proteinStageSteps = func(proteinInfo *models.ProteinInformation, force bool) map[string]func() error {
+	proteinId := fmt.Sprintf("%d", proteinInfo.ID)
+	return map[string]func() error{
+		StageParameters:   func() error { return calculateProteinParameters(proteinInfo) },
+		StageRamachandran: func() error { return generateRamachandran(proteinId) },
+		StageStructureNum: func() error { return saveStructureNum(proteinInfo.ID, force) },
+	}
+}
+
+// runProteinStages 模型生成后依次执行参数计算、Ramachandran图、RCSB结构数量阶段，并更新相关任务的 ModelId
+func runProteinStages(proteinInfo models.ProteinInformation) {
+	tasks, err := FindTasksByProtein(proteinInfo)
+	if err != nil {
+		logger.Error("查找蛋白质 %d 相关任务失败: %v", proteinInfo.ID, err)
+	}
+	steps := proteinStageSteps(&proteinInfo, false)
+
+	parametersErr := runStage(tasks, proteinInfo.ID, StageParameters, steps[StageParameters])
+	runStage(tasks, proteinInfo.ID, StageRamachandran, steps[StageRamachandran])
+	if parametersErr == nil {
+		// 参数和Ramachandran图已生成，通知相关任务的拥有者
+		PublishProteinReady(proteinInfo)
+	}
+	runStage(tasks, proteinInfo.ID, StageStructureNum, steps[StageStructureNum])
+
+	// 异步任务完成后，更新相关主任务的ModelId
+	UpdateTaskModelIdAfterAsyncCompletion(proteinInfo.ID)
+}
+
+// blastWorkspace 任务结构域搜索阶段的工作目录，失败时保留以便从中间阶段重新运行
+func blastWorkspace(taskId uint) string {
+	return jobWorkspace("blast", taskId)
+}
+
+// runBlastStages 从 from 阶段开始依次执行任务的结构域搜索阶段，某个阶段失败时停止
+func runBlastStages(task models.Task, from string) {
+	workspace := blastWorkspace(task.ID)
+	evalue := task.Evalue
+	if evalue <= 0 {
+		evalue = defaultBlastEvalue
+	}
+
+	// rpsblast 和 rpsbproc 的输出写入任务的 blast 日志
+	var log io.Writer
+	if jobLog, err := openJobLog("blast", task.ID); err != nil {
+		logger.Error("打开任务 %d 的结构域搜索日志失败: %v", task.ID, err)
+	} else {
+		defer jobLog.Close()
+		jobLog.Printf("从%s阶段开始结构域搜索，E-value: %g", from, evalue)
+		log = jobLog
+	}
+
+	steps := map[string]func() error{
+		StageRpsblast: func() error {
+			if err := prepareJobWorkspace(workspace); err != nil {
+				return err
+			}
+			return runRpsblast(workspace, task.Sequence, evalue, log)
+		},
+		StageRpsbproc: func() error {
+			return runRpsbproc(workspace, evalue, log)
+		},
+		StageDomains: func() error {
+			subSequences, blastInformations, err := extractDomains(workspace, task.Sequence)
+			if err != nil {
+				return err
+			}
+			return addTaskDomains(task, subSequences, blastInformations)
+		},
+	}
+
+	// 从 from 开始的阶段都会重新执行，先标记为等待
+	start := 0
+	for i, stage := range blastStages {
+		if stage == from {
+			start = i
+		}
+	}
+	for _, stage := range blastStages[start:] {
+		setTaskStageStatus(task.ID, 0, stage, JobStatusPending, "")
+	}
+
+	for i, stage := range blastStages[start:] {
+		if err := runStage([]models.Task{task}, 0, stage, steps[stage]); err != nil {
+			logger.Error("任务 %d 的结构域搜索在%s阶段失败，工作目录 %s 已保留", task.ID, stage, workspace)
+			// 后续阶段不再执行
+			for _, skipped := range blastStages[start+i+1:] {
+				setTaskStageStatus(task.ID, 0, skipped, JobStatusCancelled, fmt.Sprintf("%s阶段失败", stage))
+			}
+			refreshTaskStatuses([]models.Task{task})
+			return
+		}
+	}
+	removeJobWorkspace(workspace)
+}
+
+// TaskStageItem 任务进度中的一个阶段
+type TaskStageItem struct {
+	Stage      string   `json:"stage"`
+	ProteinId  uint     `json:"proteinId,omitempty"` // 按蛋白质执行的阶段对应的蛋白质
+	Status     string   `json:"status"`              // pending, running, succeeded, failed, cancelled
+	Attempts   int      `json:"attempts"`
+	StartedAt  *int64   `json:"startedAt"`  // 毫秒时间戳
+	FinishedAt *int64   `json:"finishedAt"` // 毫秒时间戳
+	Duration   float64  `json:"duration"`   // 秒
+	Error      string   `json:"error,omitempty"`
+	DependsOn  []string `json:"dependsOn"`
+}
+
+// TaskProgress 任务流水线的进度
+type TaskProgress struct {
+	TaskId    uint            `json:"taskId"`
+	Status    string          `json:"status"`
+	Completed int             `json:"completed"` // 已成功的阶段数
+	Total     int             `json:"total"`
+	Percent   float64         `json:"percent"`
+	Stages    []TaskStageItem `json:"stages"`
+}
+
+// taskProteins 返回任务主序列和子序列对应的蛋白质信息，按任务中的序列顺序排列
+func taskProteins(task models.Task) []models.ProteinInformation {
+	sequences := taskSequences(task)
+	var infos []models.ProteinInformation
+	if err := database.Database.Where("sequence IN ?", sequences).Find(&infos).Error; err != nil {
+		logger.Error("查询任务 %d 的蛋白质信息失败: %v", task.ID, err)
+		return nil
+	}
+	bySequence := make(map[string]models.ProteinInformation, len(infos))
+	for _, info := range infos {
+		bySequence[info.Sequence] = info
+	}
+
+	var proteins []models.ProteinInformation
+	seen := make(map[uint]bool)
+	for _, sequence := range sequences {
+		if info, ok := bySequence[sequence]; ok && !seen[info.ID] {
+			seen[info.ID] = true
+			proteins = append(proteins, info)
+		}
+	}
+	return proteins
+}
+
+// stageKey 任务阶段记录的键
+func stageKey(stage string, proteinId uint) string {
+	return fmt.Sprintf("%s:%d", stage, proteinId)
+}
+
+// newStageItem 由阶段记录或推断的状态构造进度项
+func newStageItem(stage pipelineStage, proteinId uint, status string, attempts int, startedAt, finishedAt *time.Time, errMsg string) TaskStageItem {
+	item := TaskStageItem{
+		Stage:     stage.Name,
+		ProteinId: proteinId,
+		Status:    status,
+		Attempts:  attempts,
+		Error:     errMsg,
+		DependsOn: stage.DependsOn,
+	}
+	if item.DependsOn == nil {
+		item.DependsOn = []string{}
+	}
+	if startedAt != nil {
+		ms := startedAt.UnixMilli()
+		item.StartedAt = &ms
+		end := time.Now()
+		if finishedAt != nil {
+			end = *finishedAt
+		}
+		item.Duration = end.Sub(*startedAt).Seconds()
+	}
+	if finishedAt != nil {
+		ms := finishedAt.UnixMilli()
+		item.FinishedAt = &ms
+	}
+	return item
+}
+
+// foldStageItem 按序列最近的预测队列记录构造 fold 阶段；没有队列记录时按模型文件是否存在推断
+func foldStageItem(stage pipelineStage, info models.ProteinInformation) TaskStageItem {
+	type foldJob struct {
+		UpdatedAt    time.Time
+		Status       string
+		ErrorMessage string
+		Attempts     int
+		StartedAt    *time.Time
+		FinishedAt   *time.Time
+	}
+	var latest foldJob
+	for _, tool := range registeredQueueTools() {
+		var job foldJob
+		if err := tool.query().Select("updated_at, status, error_message, attempts, started_at, finished_at").
+			Where("sequence = ?", info.Sequence).Order("updated_at DESC").Limit(1).Scan(&job).Error; err != nil || job.Status == "" {
+			continue
+		}
+		if latest.Status == "" || job.UpdatedAt.After(latest.UpdatedAt) {
+			latest = job
+		}
+	}
+
+	if latest.Status == "" {
+		status := JobStatusPending
+		if proteinModelExists(info.ID) {
+			status = JobStatusSucceeded
+		}
+		return newStageItem(stage, info.ID, status, 0, nil, nil, "")
+	}
+
+	// 队列记录的后处理阶段对应流水线中模型生成之后的阶段
+	status := latest.Status
+	switch status {
+	case JobStatusClaimed:
+		status = JobStatusRunning
+	case JobStatusPostprocessing:
+		status = JobStatusSucceeded
+	}
+	finishedAt := latest.FinishedAt
+	if status == JobStatusSucceeded && finishedAt == nil {
+		finishedAt = &latest.UpdatedAt
+	}
+	return newStageItem(stage, info.ID, status, latest.Attempts, latest.StartedAt, finishedAt, latest.ErrorMessage)
+}
+
+// derivedStageStatus 推断没有阶段记录的阶段状态（流水线记录之前创建的任务，或尚未开始的阶段）
+func derivedStageStatus(task models.Task, stage string, info models.ProteinInformation, proteins []models.ProteinInformation) string {
+	done := func(ok bool) string {
+		if ok {
+			return JobStatusSucceeded
+		}
+		return JobStatusPending
+	}
+	switch stage {
+	case StageRpsblast, StageRpsbproc, StageDomains:
+		// 旧任务在创建时同步完成结构域搜索
+		return JobStatusSucceeded
+	case StageParameters:
+		return done(info.RcScore != "")
+	case StageRamachandran:
+		_, err := existingFile(fmt.Sprintf("static/imgs/%d.png", info.ID))
+		return done(err == nil)
+	case StageStructureNum:
+		return done(info.StructureNum > 0 || info.RcScore != "")
+	case StageModelId:
+		modelIds := make(map[string]bool)
+		for _, id := range strings.Split(task.ModelId, ",") {
+			modelIds[id] = true
+		}
+		for _, protein := range proteins {
+			if !modelIds[fmt.Sprintf("%d", protein.ID)] {
+				return JobStatusPending
+			}
+		}
+		return JobStatusSucceeded
+	}
+	return JobStatusPending
+}
+
+// loadTaskStages 读取任务的所有阶段记录
+func loadTaskStages(taskId uint) map[string]models.TaskStage {
+	var records []models.TaskStage
+	if err := database.Database.Where("task_id = ?", taskId).Find(&records).Error; err != nil {
+		logger.Error("查询任务 %d 的阶段记录失败: %v", taskId, err)
+	}
+	result := make(map[string]models.TaskStage, len(records))
+	for _, record := range records {
+		result[stageKey(record.Stage, record.ProteinId)] = record
+	}
+	return result
+}
+
+// taskStageItems 返回任务所有阶段的状态，按蛋白质执行的阶段每个蛋白质一项
+func taskStageItems(task models.Task) []TaskStageItem {
+	records := loadTaskStages(task.ID)
+	proteins := taskProteins(task)
+
+	var items []TaskStageItem
+	add := func(stage pipelineStage, info models.ProteinInformation) {
+		if record, ok := records[stageKey(stage.Name, info.ID)]; ok {
+			items = append(items, newStageItem(stage, info.ID, record.Status, record.Attempts, record.StartedAt, record.FinishedAt, record.ErrorMessage))
+			return
+		}
+		if stage.Name == StageFold {
+			items = append(items, foldStageItem(stage, info))
+			return
+		}
+		items = append(items, newStageItem(stage, info.ID, derivedStageStatus(task, stage.Name, info, proteins), 0, nil, nil, ""))
+	}
+
+	for _, stage := range taskPipelineStages(task) {
+		if !stage.PerProtein {
+			add(stage, models.ProteinInformation{})
+			continue
+		}
+		for _, info := range proteins {
+			add(stage, info)
+		}
+	}
+	return items
+}
+
+// GetTaskProgress 返回任务流水线每个阶段的状态、耗时和错误
+func GetTaskProgress(userId uint, taskId uint) (*TaskProgress, error) {
+	var task models.Task
+	if err := database.Database.Where("id = ?", taskId).First(&task).Error; err != nil || !UserCanAccessTask(userId, taskId) {
+		return nil, fmt.Errorf("Task not found.")
+	}
+	if !isPipelineTask(task) {
+		return nil, fmt.Errorf("Only blast and fold tasks have pipeline stages.")
+	}
+
+	items := taskStageItems(task)
+	progress := &TaskProgress{TaskId: task.ID, Status: task.Status, Total: len(items), Stages: items}
+	for _, item := range items {
+		if item.Status == JobStatusSucceeded {
+			progress.Completed++
+		}
+	}
+	if progress.Total > 0 {
+		progress.Percent = float64(progress.Completed) * 100 / float64(progress.Total)
+	}
+	return progress, nil
+}
+
+// RerunStageRequest 重新运行任务中一个阶段的请求
+type RerunStageRequest struct {
+	TaskId    uint   `json:"taskId" binding:"required"`
+	Stage     string `json:"stage" binding:"required"`
+	ProteinId uint   `json:"proteinId"` // 按蛋白质执行的阶段必填
+}
+
+// RerunTaskStage 重新运行任务中的一个阶段，上游阶段需要已成功
+// 结构域搜索阶段会继续执行后续的结构域搜索阶段；fold 重新排队预测，完成后自动执行后续阶段
+func RerunTaskStage(userId uint, req RerunStageRequest) error {
+	var task models.Task
+	if err := database.Database.Where("id = ?", req.TaskId).First(&task).Error; err != nil || !UserHasTaskPermission(userId, req.TaskId, SharePermissionRerun) {
+		return fmt.Errorf("Task not found.")
+	}
+	if !isPipelineTask(task) {
+		return fmt.Errorf("Only blast and fold tasks have pipeline stages.")
+	}
+	stage, ok := findPipelineStage(req.Stage)
+	if !ok || (stage.BlastOnly && task.Type != 1) {
+		return fmt.Errorf("Invalid stage.")
+	}
+
+	var proteinInfo models.ProteinInformation
+	if stage.PerProtein {
+		for _, info := range taskProteins(task) {
+			if info.ID == req.ProteinId {
+				proteinInfo = info
+			}
+		}
+		if proteinInfo.ID == 0 {
+			return fmt.Errorf("Protein not found in task.")
+		}
+	}
+
+	// 检查阶段本身和上游阶段的状态
+	items := taskStageItems(task)
+	for _, item := range items {
+		if item.Stage == stage.Name && item.ProteinId == proteinInfo.ID && item.Status == JobStatusRunning {
+			startedAt := time.UnixMilli(0)
+			if item.StartedAt != nil {
+				startedAt = time.UnixMilli(*item.StartedAt)
+			}
+			if stage.Name == StageFold || time.Since(startedAt) < taskStageStaleAfter {
+				return fmt.Errorf("Stage is already running.")
+			}
+		}
+	}
+	for _, dependency := range stage.DependsOn {
+		dependencyStage, _ := findPipelineStage(dependency)
+		if dependencyStage.BlastOnly && task.Type != 1 {
+			continue
+		}
+		for _, item := range items {
+			// 按蛋白质执行的上游阶段只检查同一蛋白质；蛋白质存在说明任务级别的上游阶段已完成
+			if item.Stage != dependency || (stage.PerProtein && !dependencyStage.PerProtein) {
+				continue
+			}
+			if dependencyStage.PerProtein && stage.PerProtein && item.ProteinId != proteinInfo.ID {
+				continue
+			}
+			if item.Status != JobStatusSucceeded {
+				return fmt.Errorf("Stage %s has not succeeded.", dependency)
+			}
+		}
+	}
+
+	switch stage.Name {
+	case StageRpsblast, StageRpsbproc, StageDomains:
+		// 中间阶段使用上一次执行保留在工作目录中的输出
+		required := map[string]string{StageRpsbproc: "fasta.asn", StageDomains: "fasta.out"}
+		if name, ok := required[stage.Name]; ok {
+			if _, err := existingFile(fmt.Sprintf("%s/%s", blastWorkspace(task.ID), name)); err != nil {
+				return fmt.Errorf("Output of the previous stage is no longer available, rerun from %s.", StageRpsblast)
+			}
+		}
+		go runBlastStages(task, stage.Name)
+	case StageFold:
+		if task.StructurePredictionTool == nil {
+			return fmt.Errorf("Invalid type.")
+		}
+		// 已有该预测工具的模型时只能重新运行后续阶段
+		tool, ok := queueToolByType(*task.StructurePredictionTool)
+		if !ok {
+			return fmt.Errorf("Invalid type.")
+		}
+		if predictorModelExists(proteinInfo, tool.Key) {
+			return fmt.Errorf("Model already exists, rerun the later stages instead.")
+		}
+		// 重新排队，预测完成后会自动执行后续阶段
+		if !enqueueMissingPrediction(proteinInfo, *task.StructurePredictionTool, uint(task.UserId)) {
+			return fmt.Errorf("Failed to queue the prediction.")
+		}
+		assignQueueJobs(task)
+		RefreshTaskStatus(task)
+	case StageParameters, StageRamachandran, StageStructureNum:
+		if !proteinModelExists(proteinInfo.ID) {
+			return fmt.Errorf("Model not found.")
+		}
+		steps := proteinStageSteps(&proteinInfo, true)
+		setTaskStageStatus(task.ID, proteinInfo.ID, stage.Name, JobStatusPending, "")
+		go func() {
+			if runStage([]models.Task{task}, proteinInfo.ID, stage.Name, steps[stage.Name]) == nil && stage.Name == StageParameters {
+				PublishProteinReady(proteinInfo)
+			}
+		}()
+	case StageModelId:
+		var mainProteinInfo models.ProteinInformation
+		if err := database.Database.Where("sequence = ?", task.Sequence).Find(&mainProteinInfo).Error; err != nil || mainProteinInfo.ID == 0 {
+			return fmt.Errorf("Protein not found in task.")
+		}
+		go runStage([]models.Task{task}, 0, StageModelId, func() error {
+			return updateTaskModelId(task, mainProteinInfo.ID)
+		})
+	}
+	logger.Info("用户 %d 重新运行任务 %d 的%s阶段（蛋白质 %d）", userId, task.ID, stage.Name, proteinInfo.ID)
+	return nil
+}
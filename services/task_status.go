@@ -0,0 +1,93 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"strings"
+)
+
+// 任务状态
+const (
+	TaskStatusPending   = "pending"
+	TaskStatusRunning   = "running"
+	TaskStatusCompleted = "completed"
+	TaskStatusFailed    = "failed"
+	TaskStatusCancelled = "cancelled"
+)
+
+// taskSequences 返回任务的主序列和所有子序列
+func taskSequences(task models.Task) []string {
+	sequences := []string{task.Sequence}
+	if task.SubSequence != "" {
+		for _, sub := range strings.Split(task.SubSequence, "|") {
+			if sub != "" {
+				sequences = append(sequences, sub)
+			}
+		}
+	}
+	return sequences
+}
+
+// countQueueStatus 统计一组序列在所有预测队列中处于某状态的记录数
+func countQueueStatus(sequences []string, statuses ...string) int64 {
+	var total int64
+	for _, tool := range queueTools {
+		var count int64
+		if err := tool.query().Where("sequence IN ? AND status IN ?", sequences, statuses).Count(&count).Error; err != nil {
+			logger.Error("统计队列状态失败: %v", err)
+			continue
+		}
+		total += count
+	}
+	return total
+}
+
+// countStageStatus 统计任务中处于某状态的阶段记录数
+func countStageStatus(taskId uint, statuses ...string) int64 {
+	var count int64
+	if err := database.Database.Model(&models.TaskStage{}).Where("task_id = ? AND status IN ?", taskId, statuses).Count(&count).Error; err != nil {
+		logger.Error("统计任务阶段状态失败: %v", err)
+	}
+	return count
+}
+
+// RefreshTaskStatus 根据任务相关序列的队列记录和任务阶段记录重新计算任务状态
+func RefreshTaskStatus(task models.Task) string {
+	sequences := taskSequences(task)
+
+	status := TaskStatusCompleted
+	switch {
+	case countQueueStatus(sequences, jobActiveStatuses...) > 0 || countStageStatus(task.ID, JobStatusRunning) > 0:
+		status = TaskStatusRunning
+	case countQueueStatus(sequences, JobStatusPending) > 0 || countStageStatus(task.ID, JobStatusPending) > 0:
+		status = TaskStatusPending
+	case countQueueStatus(sequences, JobStatusFailed) > 0 || countStageStatus(task.ID, JobStatusFailed) > 0:
+		status = TaskStatusFailed
+	case countQueueStatus(sequences, JobStatusCancelled) > 0:
+		status = TaskStatusCancelled
+	}
+
+	if status != task.Status {
+		if err := database.Database.Model(&models.Task{}).Where("id = ?", task.ID).Update("status", status).Error; err != nil {
+			logger.Error("更新任务状态失败: %v", err)
+		}
+	}
+	return status
+}
+
+// refreshTasksForSequence 刷新包含该序列的所有任务的状态
+func refreshTasksForSequence(sequence string) {
+	var proteinInfo models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInfo).Error; err != nil || proteinInfo.ID == 0 {
+		return
+	}
+	tasks, err := FindTasksByProtein(proteinInfo)
+	if err != nil {
+		logger.Error("查找序列相关任务失败: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		RefreshTaskStatus(task)
+	}
+}
@@ -33,7 +33,20 @@ func GenerateToken(account *AccountClaims) string {
 
 func JwtVerify(c *gin.Context) {
 	// get token from header
+	verifyToken(c, c.GetHeader("token"))
+}
+
+// JwtVerifyQuery 用于 EventSource 等无法设置请求头的接口，允许通过查询参数传递 token
+// 只用于 /events，其他接口只接受请求头中的 token，避免 token 出现在 URL 和访问日志中
+func JwtVerifyQuery(c *gin.Context) {
 	token := c.GetHeader("token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	verifyToken(c, token)
+}
+
+func verifyToken(c *gin.Context, token string) {
 	if token == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "token not exist!"})
 		c.Abort()
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 回收站中任务的保留时间，超过后由调度器永久删除，可通过 TRASH_RETENTION_DAYS 配置
+var trashRetention = loadTrashRetention()
+
+func loadTrashRetention() time.Duration {
+	days := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			days = parsed
+		} else {
+			logger.Warn("TRASH_RETENTION_DAYS 配置无效: %s，使用默认值 %d 天", value, days)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// TrashItem 回收站中的任务
+type TrashItem struct {
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	Fasta     string `json:"fasta"`
+	Type      int64  `json:"type"`
+	CreatedAt int64  `json:"createdAt"`
+	DeletedAt int64  `json:"deletedAt"`
+	PurgeAt   int64  `json:"purgeAt"` // 预计永久删除时间
+}
+
+type TrashListResult struct {
+	List  []TrashItem `json:"list"`
+	Total int64       `json:"total"`
+}
+
+// DeleteTask 将用户的任务移入回收站
+func DeleteTask(userId uint, taskId uint) error {
+	result := database.Database.Where("id = ? AND user_id = ?", taskId, userId).Delete(&models.Task{})
+	if result.Error != nil {
+		return errors.New("Network error.")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Task not found.")
+	}
+	return nil
+}
+
+// ListTrash 分页查询用户回收站中的任务
+func ListTrash(userId uint, current, pageSize int) (TrashListResult, error) {
+	var tasks []models.Task
+	var total int64
+
+	db := database.Database.Unscoped().Model(&models.Task{}).
+		Where("user_id = ? AND deleted_at IS NOT NULL", userId)
+	db.Count(&total)
+	if err := db.Order("deleted_at DESC").Offset((current - 1) * pageSize).Limit(pageSize).Find(&tasks).Error; err != nil {
+		return TrashListResult{}, err
+	}
+
+	list := make([]TrashItem, 0, len(tasks))
+	for _, task := range tasks {
+		list = append(list, TrashItem{
+			ID:        task.ID,
+			Title:     task.Title,
+			Fasta:     task.Sequence,
+			Type:      task.Type,
+			CreatedAt: task.CreatedAt.UnixMilli(),
+			DeletedAt: task.DeletedAt.Time.UnixMilli(),
+			PurgeAt:   task.DeletedAt.Time.Add(trashRetention).UnixMilli(),
+		})
+	}
+	return TrashListResult{List: list, Total: total}, nil
+}
+
+// RestoreTask 从回收站恢复任务，原文件夹已删除时恢复到根目录
+func RestoreTask(userId uint, taskId uint) error {
+	var task models.Task
+	if err := database.Database.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", taskId, userId).First(&task).Error; err != nil {
+		return errors.New("Task not found.")
+	}
+
+	updates := map[string]interface{}{"deleted_at": nil}
+	if task.FolderId != 0 && !userOwnsFolder(userId, task.FolderId) {
+		updates["folder_id"] = 0
+	}
+	if err := database.Database.Unscoped().Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		return errors.New("Network error.")
+	}
+	return nil
+}
+
+// PurgeTask 立即永久删除回收站中的任务
+func PurgeTask(userId uint, taskId uint) error {
+	var task models.Task
+	if err := database.Database.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", taskId, userId).First(&task).Error; err != nil {
+		return errors.New("Task not found.")
+	}
+	if err := purgeTask(task); err != nil {
+		return errors.New("Network error.")
+	}
+	return nil
+}
+
+// PurgeExpiredTasks 永久删除超过保留时间的回收站任务
+func PurgeExpiredTasks() {
+	var tasks []models.Task
+	deadline := time.Now().Add(-trashRetention)
+	if err := database.Database.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", deadline).Find(&tasks).Error; err != nil {
+		logger.Error("查询过期回收站任务失败: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		if err := purgeTask(task); err != nil {
+			logger.Error("永久删除任务 %d 失败: %v", task.ID, err)
+			continue
+		}
+		logger.Info("任务 %d 已超过回收站保留时间，已永久删除", task.ID)
+	}
+}
+
+// purgeTask 永久删除任务及其笔记、分享、标签和阶段记录，并清理不再被任何任务引用的蛋白质模型
+func purgeTask(task models.Task) error {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.NoteRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.Note{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.Share{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("task_id = ?", task.ID).Delete(&models.TaskStage{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Task{}, task.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	removeTaskFromIndex(task.ID)
+	removeJobWorkspace(blastWorkspace(task.ID))
+	removeJobLog("blast", task.ID)
+
+	// 候选蛋白质：ModelId 中的模型以及任务序列对应的记录
+	candidates := make(map[uint]models.ProteinInformation)
+	var proteinInfos []models.ProteinInformation
+	database.Database.Where("id IN ? OR sequence IN ?", append(parseModelIds(task.ModelId), 0), taskSequences(task)).Find(&proteinInfos)
+	for _, info := range proteinInfos {
+		candidates[info.ID] = info
+	}
+
+	// 先处理子序列，使主序列在子序列删除后可以被判定为未引用
+	ordered := make([]models.ProteinInformation, 0, len(candidates))
+	for _, info := range candidates {
+		ordered = append(ordered, info)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].ParentId > ordered[j].ParentId
+	})
+	for _, info := range ordered {
+		if proteinReferenced(info) {
+			continue
+		}
+		if err := purgeProtein(info); err != nil {
+			logger.Error("删除未引用的蛋白质 %d 失败: %v", info.ID, err)
+		}
+	}
+	return nil
+}
+
+// proteinReferenced 判断蛋白质是否仍被任务（包括回收站中的任务）、子序列或未完成的预测队列引用
+func proteinReferenced(info models.ProteinInformation) bool {
+	var count int64
+	query, args := modelIdMatch(info.ID)
+	database.Database.Unscoped().Model(&models.Task{}).
+		Where(database.Database.Where(query, args...).Or("sequence = ?", info.Sequence).Or("sub_sequence LIKE ?", "%"+info.Sequence+"%")).
+		Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	database.Database.Model(&models.ProteinInformation{}).Where("parent_id = ?", info.ID).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	return countQueueStatus([]string{info.Sequence}, append([]string{JobStatusPending}, jobActiveStatuses...)...) > 0
+}
+
+// purgeProtein 永久删除蛋白质记录、注释以及模型和图片文件
+func purgeProtein(info models.ProteinInformation) error {
+	err := database.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("protein_id = ?", info.ID).Delete(&models.Annotation{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.ProteinInformation{}, info.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	paths := []string{
+		fmt.Sprintf("static/models/%d.pdb", info.ID),
+		fmt.Sprintf("static/imgs/%d.png", info.ID),
+		fmt.Sprintf("static/imgs/%d.jpg", info.ID),
+	}
+	// 各预测工具分别保存的模型
+	if toolModels, err := filepath.Glob(fmt.Sprintf("static/models/%d_*.pdb", info.ID)); err == nil {
+		paths = append(paths, toolModels...)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Warn("删除文件 %s 失败: %v", path, err)
+		}
+	}
+	logger.Info("已删除未引用的蛋白质 %d 及其模型文件", info.ID)
+	return nil
+}
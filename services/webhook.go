@@ -0,0 +1,391 @@
+package services
+
+import (
+	"Protein_Server/database"
+	"Protein_Server/logger"
+	"Protein_Server/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Webhook 事件类型
+const (
+	WebhookJobCompleted = "job.completed"
+	WebhookJobFailed    = "job.failed"
+	WebhookPing         = "ping"
+)
+
+var (
+	// 对外访问地址，用于拼接模型文件的完整URL，例如 https://protein.example.com
+	publicBaseURL = os.Getenv("PUBLIC_BASE_URL")
+	// 投递失败后的重试间隔，长度即最大重试次数
+	webhookRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute}
+	webhookClient      = &http.Client{Timeout: 10 * time.Second}
+)
+
+// WebhookModel 回调中的模型信息
+type WebhookModel struct {
+	ID       uint   `json:"id"`
+	Sequence string `json:"sequence"`
+	ModelUrl string `json:"modelUrl"`
+	ImageUrl string `json:"imageUrl"`
+}
+
+// WebhookParameters 回调中的计算参数
+type WebhookParameters struct {
+	RcScore             string `json:"rcScore"`
+	Hydrophobicity      string `json:"hydrophobicity"`
+	Instability         string `json:"instability"`
+	IsoelectricPoint    string `json:"isoelectricPoint"`
+	MolecularWeight     string `json:"molecularWeight"`
+	SolventAccesibility string `json:"solventAccesibility"`
+}
+
+// WebhookPayload 回调请求体
+type WebhookPayload struct {
+	Event       string             `json:"event"`
+	TaskId      uint               `json:"taskId"`
+	Title       string             `json:"title"`
+	Tool        string             `json:"tool"`
+	ProteinId   uint               `json:"proteinId"`
+	SequenceIds []uint             `json:"sequenceIds"`
+	Models      []WebhookModel     `json:"models"`
+	Parameters  *WebhookParameters `json:"parameters,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	Timestamp   int64              `json:"timestamp"`
+}
+
+type WebhookItem struct {
+	ID        uint     `json:"id"`
+	Url       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt int64    `json:"createdAt"`
+}
+
+type WebhookDeliveryItem struct {
+	ID         uint    `json:"id"`
+	Event      string  `json:"event"`
+	Payload    string  `json:"payload"`
+	Attempt    int     `json:"attempt"`
+	StatusCode int     `json:"statusCode"`
+	Success    bool    `json:"success"`
+	Error      string  `json:"error"`
+	Response   string  `json:"response"`
+	Duration   float64 `json:"duration"`
+	CreatedAt  int64   `json:"createdAt"`
+}
+
+type WebhookDeliveryListResult struct {
+	List  []WebhookDeliveryItem `json:"list"`
+	Total int64                 `json:"total"`
+}
+
+// CreateWebhook 注册回调地址，secret 为空时自动生成
+func CreateWebhook(userId uint, rawUrl, secret string, events []string) (WebhookItem, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return WebhookItem{}, fmt.Errorf("Invalid url.")
+	}
+	for _, event := range events {
+		if event != WebhookJobCompleted && event != WebhookJobFailed {
+			return WebhookItem{}, fmt.Errorf("Invalid event: %s", event)
+		}
+	}
+	if secret == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return WebhookItem{}, fmt.Errorf("Network error.")
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	webhook := models.Webhook{
+		UserId: userId,
+		Url:    rawUrl,
+		Secret: secret,
+		Events: strings.Join(events, ","),
+		Active: true,
+	}
+	if err := database.Database.Create(&webhook).Error; err != nil {
+		return WebhookItem{}, fmt.Errorf("Network error.")
+	}
+
+	item := toWebhookItem(webhook)
+	// 只在创建时返回一次 secret
+	item.Secret = secret
+	return item, nil
+}
+
+// ListWebhooks 查询用户注册的回调
+func ListWebhooks(userId uint) ([]WebhookItem, error) {
+	var webhooks []models.Webhook
+	if err := database.Database.Where("user_id = ?", userId).Order("created_at DESC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	list := make([]WebhookItem, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		list = append(list, toWebhookItem(webhook))
+	}
+	return list, nil
+}
+
+// DeleteWebhook 删除用户的回调
+func DeleteWebhook(userId, webhookId uint) error {
+	result := database.Database.Where("id = ? AND user_id = ?", webhookId, userId).Delete(&models.Webhook{})
+	if result.Error != nil {
+		return fmt.Errorf("Network error.")
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("Webhook not found.")
+	}
+	return nil
+}
+
+// ListWebhookDeliveries 分页查询回调的投递记录
+func ListWebhookDeliveries(userId, webhookId uint, current, pageSize int) (WebhookDeliveryListResult, error) {
+	var webhook models.Webhook
+	if err := database.Database.Where("id = ? AND user_id = ?", webhookId, userId).First(&webhook).Error; err != nil {
+		return WebhookDeliveryListResult{}, fmt.Errorf("Webhook not found.")
+	}
+
+	var deliveries []models.WebhookDelivery
+	var total int64
+	db := database.Database.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)
+	if err := db.Count(&total).Error; err != nil {
+		return WebhookDeliveryListResult{}, fmt.Errorf("Network error.")
+	}
+	if err := db.Order("created_at DESC").Offset((current - 1) * pageSize).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		return WebhookDeliveryListResult{}, fmt.Errorf("Network error.")
+	}
+
+	list := make([]WebhookDeliveryItem, 0, len(deliveries))
+	for _, d := range deliveries {
+		list = append(list, toWebhookDeliveryItem(d))
+	}
+	return WebhookDeliveryListResult{List: list, Total: total}, nil
+}
+
+// TestWebhook 同步发送一次 ping 事件，便于用本地 HTTP 接收端调试
+func TestWebhook(userId, webhookId uint) (WebhookDeliveryItem, error) {
+	var webhook models.Webhook
+	if err := database.Database.Where("id = ? AND user_id = ?", webhookId, userId).First(&webhook).Error; err != nil {
+		return WebhookDeliveryItem{}, fmt.Errorf("Webhook not found.")
+	}
+	body, err := json.Marshal(WebhookPayload{Event: WebhookPing, Timestamp: time.Now().UnixMilli()})
+	if err != nil {
+		return WebhookDeliveryItem{}, fmt.Errorf("Network error.")
+	}
+	d := deliverWebhook(webhook, WebhookPing, body, 1)
+	return toWebhookDeliveryItem(d), nil
+}
+
+// TriggerJobWebhooks 结构预测任务结束后，向相关任务拥有者注册的回调地址投递事件
+func TriggerJobWebhooks(sequence string, tool string, jobErr error) {
+	var proteinInfo models.ProteinInformation
+	if err := database.Database.Where("sequence = ?", sequence).Find(&proteinInfo).Error; err != nil || proteinInfo.ID == 0 {
+		return
+	}
+
+	tasks, err := FindTasksByProtein(proteinInfo)
+	if err != nil {
+		logger.Error("查找回调相关任务失败: %v", err)
+		return
+	}
+
+	event := WebhookJobCompleted
+	if jobErr != nil {
+		event = WebhookJobFailed
+	}
+
+	for _, task := range tasks {
+		var webhooks []models.Webhook
+		if err := database.Database.Where("user_id = ? AND active = ?", task.UserId, true).Find(&webhooks).Error; err != nil {
+			logger.Error("查询用户回调失败: %v", err)
+			continue
+		}
+		if len(webhooks) == 0 {
+			continue
+		}
+
+		body, err := json.Marshal(buildWebhookPayload(event, task, tool, proteinInfo, jobErr))
+		if err != nil {
+			logger.Error("序列化回调内容失败: %v", err)
+			continue
+		}
+		for _, webhook := range webhooks {
+			if !webhookSubscribed(webhook, event) {
+				continue
+			}
+			go deliverWebhookWithRetry(webhook, event, body)
+		}
+	}
+}
+
+// buildWebhookPayload 组装任务的回调内容
+func buildWebhookPayload(event string, task models.Task, tool string, proteinInfo models.ProteinInformation, jobErr error) WebhookPayload {
+	payload := WebhookPayload{
+		Event:       event,
+		TaskId:      task.ID,
+		Title:       task.Title,
+		Tool:        tool,
+		ProteinId:   proteinInfo.ID,
+		SequenceIds: []uint{},
+		Models:      []WebhookModel{},
+		Timestamp:   time.Now().UnixMilli(),
+	}
+	if jobErr != nil {
+		payload.Error = jobErr.Error()
+	}
+
+	ids := parseModelIds(task.ModelId)
+	payload.SequenceIds = append(payload.SequenceIds, ids...)
+
+	if len(ids) > 0 {
+		var proteinInfos []models.ProteinInformation
+		if err := database.Database.Where("id IN ?", ids).Find(&proteinInfos).Error; err == nil {
+			for _, info := range proteinInfos {
+				// 只返回已经生成模型文件的序列
+				if !proteinModelExists(info.ID) {
+					continue
+				}
+				payload.Models = append(payload.Models, WebhookModel{
+					ID:       info.ID,
+					Sequence: info.Sequence,
+					ModelUrl: fmt.Sprintf("%s/models/%d.pdb", publicBaseURL, info.ID),
+					ImageUrl: fmt.Sprintf("%s/imgs/%d.png", publicBaseURL, info.ID),
+				})
+			}
+		}
+	}
+
+	if jobErr == nil {
+		// 重新查询，获取后处理阶段计算出的参数
+		var latest models.ProteinInformation
+		if err := database.Database.Where("id = ?", proteinInfo.ID).First(&latest).Error; err == nil {
+			payload.Parameters = &WebhookParameters{
+				RcScore:             latest.RcScore,
+				Hydrophobicity:      latest.Hydrophobicity,
+				Instability:         latest.Instability,
+				IsoelectricPoint:    latest.IsoelectricPoint,
+				MolecularWeight:     latest.MolecularWeight,
+				SolventAccesibility: latest.SolventAccesibility,
+			}
+		}
+	}
+	return payload
+}
+
+// deliverWebhookWithRetry 投递回调，失败后按 webhookRetryDelays 重试
+func deliverWebhookWithRetry(webhook models.Webhook, event string, body []byte) {
+	for attempt := 1; ; attempt++ {
+		delivery := deliverWebhook(webhook, event, body, attempt)
+		if delivery.Success {
+			return
+		}
+		if attempt > len(webhookRetryDelays) {
+			logger.Error("回调 %d 投递失败，已达到最大重试次数: %s", webhook.ID, delivery.Error)
+			return
+		}
+		time.Sleep(webhookRetryDelays[attempt-1])
+	}
+}
+
+// deliverWebhook 发送一次签名的回调请求并记录投递日志
+func deliverWebhook(webhook models.Webhook, event string, body []byte, attempt int) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{
+		WebhookId: webhook.ID,
+		Event:     event,
+		Payload:   string(body),
+		Attempt:   attempt,
+	}
+
+	startTime := time.Now()
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Protein-Event", event)
+		req.Header.Set("X-Protein-Signature", "sha256="+signWebhookPayload(webhook.Secret, body))
+		resp, err := webhookClient.Do(req)
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			delivery.StatusCode = resp.StatusCode
+			delivery.Response = string(respBody)
+			delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+			if !delivery.Success {
+				delivery.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+			}
+		}
+	}
+	delivery.Duration = time.Since(startTime).Seconds()
+
+	if err := database.Database.Create(&delivery).Error; err != nil {
+		logger.Error("保存回调投递记录失败: %v", err)
+	}
+	return delivery
+}
+
+// signWebhookPayload 使用 HMAC-SHA256 对请求体签名
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookSubscribed(webhook models.Webhook, event string) bool {
+	if webhook.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(webhook.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+func toWebhookItem(webhook models.Webhook) WebhookItem {
+	events := []string{}
+	if webhook.Events != "" {
+		events = strings.Split(webhook.Events, ",")
+	}
+	return WebhookItem{
+		ID:        webhook.ID,
+		Url:       webhook.Url,
+		Events:    events,
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt.UnixMilli(),
+	}
+}
+
+func toWebhookDeliveryItem(d models.WebhookDelivery) WebhookDeliveryItem {
+	return WebhookDeliveryItem{
+		ID:         d.ID,
+		Event:      d.Event,
+		Payload:    d.Payload,
+		Attempt:    d.Attempt,
+		StatusCode: d.StatusCode,
+		Success:    d.Success,
+		Error:      d.Error,
+		Response:   d.Response,
+		Duration:   d.Duration,
+		CreatedAt:  d.CreatedAt.UnixMilli(),
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"Protein_Server/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver 记录收到的回调请求，按 statuses 依次返回状态码，用完后返回最后一个
+type webhookReceiver struct {
+	sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.Lock()
+	defer r.Unlock()
+	r.requests = append(r.requests, receivedWebhook{
+		event:     req.Header.Get("X-Protein-Event"),
+		signature: req.Header.Get("X-Protein-Signature"),
+		body:      body,
+	})
+	status := r.statuses[len(r.statuses)-1]
+	if len(r.requests) <= len(r.statuses) {
+		status = r.statuses[len(r.requests)-1]
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(http.StatusText(status)))
+}
+
+// expectedSignature 接收端按文档校验签名的方式计算
+func expectedSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestDeliverWebhookWithRetry(t *testing.T) {
+	delays := webhookRetryDelays
+	defer func() { webhookRetryDelays = delays }()
+	webhookRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantStatuses []int
+	}{
+		{"第一次成功", []int{http.StatusOK}, []int{http.StatusOK}},
+		{"5xx 后重试成功", []int{http.StatusServiceUnavailable, http.StatusNoContent}, []int{http.StatusServiceUnavailable, http.StatusNoContent}},
+		{"重试次数用完后停止", []int{http.StatusInternalServerError}, []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDatabase(t)
+			receiver := &webhookReceiver{statuses: tt.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+
+			webhook := models.Webhook{UserId: 1, Url: server.URL, Secret: "s3cret", Active: true}
+			if err := db.Create(&webhook).Error; err != nil {
+				t.Fatal(err)
+			}
+			body, _ := json.Marshal(WebhookPayload{Event: WebhookJobCompleted, TaskId: 7, Tool: "fake"})
+			deliverWebhookWithRetry(webhook, WebhookJobCompleted, body)
+
+			// 每次请求都带有相同的事件和签名
+			if len(receiver.requests) != len(tt.wantStatuses) {
+				t.Fatalf("received %d requests, want %d", len(receiver.requests), len(tt.wantStatuses))
+			}
+			for i, request := range receiver.requests {
+				if request.event != WebhookJobCompleted {
+					t.Errorf("request %d: X-Protein-Event = %q", i+1, request.event)
+				}
+				if request.signature != expectedSignature("s3cret", request.body) || string(request.body) != string(body) {
+					t.Errorf("request %d: X-Protein-Signature = %q, want %q", i+1, request.signature, expectedSignature("s3cret", body))
+				}
+			}
+
+			// 每次尝试记录一条投递日志
+			var deliveries []models.WebhookDelivery
+			if err := db.Where("webhook_id = ?", webhook.ID).Order("attempt").Find(&deliveries).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != len(tt.wantStatuses) {
+				t.Fatalf("recorded %d deliveries, want %d", len(deliveries), len(tt.wantStatuses))
+			}
+			for i, delivery := range deliveries {
+				wantSuccess := tt.wantStatuses[i] < 300
+				if delivery.Attempt != i+1 || delivery.StatusCode != tt.wantStatuses[i] || delivery.Success != wantSuccess {
+					t.Errorf("delivery %d = attempt %d status %d success %v, want attempt %d status %d success %v",
+						i+1, delivery.Attempt, delivery.StatusCode, delivery.Success, i+1, tt.wantStatuses[i], wantSuccess)
+				}
+				if delivery.Event != WebhookJobCompleted || delivery.Payload != string(body) {
+					t.Errorf("delivery %d: event %q payload %q", i+1, delivery.Event, delivery.Payload)
+				}
+				if wantSuccess != (delivery.Error == "") {
+					t.Errorf("delivery %d: error %q", i+1, delivery.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookPing(t *testing.T) {
+	openTestDatabase(t)
+	receiver := &webhookReceiver{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	// 没有指定 secret 时自动生成，只在创建时返回
+	item, err := CreateWebhook(1, server.URL, "", []string{WebhookJobCompleted})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if item.Secret == "" {
+		t.Fatal("CreateWebhook should return the generated secret")
+	}
+
+	delivery, err := TestWebhook(1, item.ID)
+	if err != nil {
+		t.Fatalf("TestWebhook: %v", err)
+	}
+	if !delivery.Success || delivery.StatusCode != http.StatusOK || delivery.Event != WebhookPing {
+		t.Errorf("delivery = %+v", delivery)
+	}
+	if len(receiver.requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(receiver.requests))
+	}
+	request := receiver.requests[0]
+	if request.signature != expectedSignature(item.Secret, request.body) {
+		t.Errorf("X-Protein-Signature = %q, want %q", request.signature, expectedSignature(item.Secret, request.body))
+	}
+
+	// 其他用户不能测试该回调
+	if _, err := TestWebhook(2, item.ID); err == nil {
+		t.Error("TestWebhook should reject other users")
+	}
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// 远程 worker 请求服务器的超时时间，上传模型时使用更长的超时时间
+const (
+	workerRequestTimeout = 30 * time.Second
+	workerUploadTimeout  = 10 * time.Minute
+	workerUploadAttempts = 3
+)
+
+// workerClient 远程 worker：从服务器租用任务，在本机执行预测后上传模型和日志
+type workerClient struct {
+	id           string
+	client       *resty.Client
+	tools        []queueTool
+	slots        map[string]chan struct{}
+	pollInterval time.Duration
+	wake         chan struct{}
+
+	mu           sync.Mutex
+	running      map[WorkerJobRef]context.CancelFunc
+	lost         map[WorkerJobRef]bool
+	leaseSeconds int
+	leaseChanged chan struct{} // 服务器的租约时长比当前的短时通知心跳重新计时
+	wg           sync.WaitGroup
+}
+
+// workerResponse 服务器接口的统一响应
+type workerResponse struct {
+	Code    int             `json:"code"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+	Error   string          `json:"error"`
+}
+
+// errWorkerRejected 服务器拒绝了请求（租约已失效等），重试没有意义
+var errWorkerRejected = errors.New("worker request rejected")
+
+// RunWorker 以远程 worker 模式运行，直到收到 SIGINT 或 SIGTERM
+// 读取 WORKER_SERVER_URL、WORKER_TOKEN、WORKER_ID（默认主机名）、WORKER_PREDICTORS（默认本机并行数大于 0 的预测工具）、WORKER_POLL_SECONDS
+func RunWorker() error {
+	serverURL := strings.TrimRight(os.Getenv("WORKER_SERVER_URL"), "/")
+	token := os.Getenv("WORKER_TOKEN")
+	if serverURL == "" || token == "" {
+		return fmt.Errorf("需要设置 WORKER_SERVER_URL 和 WORKER_TOKEN")
+	}
+	id := os.Getenv("WORKER_ID")
+	if id == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("读取主机名失败，请设置 WORKER_ID: %v", err)
+		}
+		id = host
+	}
+
+	tools, err := workerTools(os.Getenv("WORKER_PREDICTORS"))
+	if err != nil {
+		return err
+	}
+
+	w := &workerClient{
+		id: id,
+		client: resty.New().
+			SetBaseURL(serverURL).
+			SetHeader("X-Worker-Token", token),
+		tools:        tools,
+		slots:        make(map[string]chan struct{}, len(tools)),
+		pollInterval: 15 * time.Second,
+		wake:         make(chan struct{}, 1),
+		running:      make(map[WorkerJobRef]context.CancelFunc),
+		lost:         make(map[WorkerJobRef]bool),
+		leaseSeconds: int(jobLeaseDuration / time.Second),
+		leaseChanged: make(chan struct{}, 1),
+	}
+	if value, ok := envInt("WORKER_POLL_SECONDS"); ok && value > 0 {
+		w.pollInterval = time.Duration(value) * time.Second
+	}
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		w.slots[tool.Key] = make(chan struct{}, tool.predictor.Info().Workers)
+		names = append(names, fmt.Sprintf("%s×%d", tool.Key, tool.predictor.Info().Workers))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("worker %s 启动，服务器: %s，预测工具: %s", id, serverURL, strings.Join(names, ", "))
+	go w.heartbeat(ctx)
+	w.run(ctx)
+
+	// 停止租用新任务，等待执行中的任务把中断报告给服务器
+	logger.Info("worker %s 停止，等待执行中的任务结束...", id)
+	w.wg.Wait()
+	return nil
+}
+
+// workerTools 返回 worker 执行的预测工具，names 为逗号分隔的预测工具名称
+func workerTools(names string) ([]queueTool, error) {
+	var tools []queueTool
+	if names == "" {
+		for _, tool := range registeredQueueTools() {
+			if tool.predictor.Info().Workers > 0 {
+				tools = append(tools, tool)
+			}
+		}
+	} else {
+		for _, name := range strings.Split(names, ",") {
+			tool, ok := queueTools[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("未注册的预测工具: %s", name)
+			}
+			if tool.predictor.Info().Workers <= 0 {
+				return nil, fmt.Errorf("预测工具 %s 的并行数为 0", tool.Key)
+			}
+			tools = append(tools, tool)
+		}
+	}
+	if len(tools) == 0 {
+		return nil, fmt.Errorf("没有可执行的预测工具")
+	}
+	return tools, nil
+}
+
+// run 在有空闲处理槽位时租用任务，没有任务时等待轮询间隔
+func (w *workerClient) run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, tool := range w.tools {
+			w.leaseJobs(ctx, tool)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// leaseJobs 为一个预测工具租用任务，直到处理槽位已满或服务器没有待处理的记录
+func (w *workerClient) leaseJobs(ctx context.Context, tool queueTool) {
+	slots := w.slots[tool.Key]
+	for ctx.Err() == nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			return
+		}
+
+		var job *WorkerJob
+		err := w.post(ctx, "/worker/lease", WorkerLeaseRequest{WorkerId: w.id, Predictors: []string{tool.Key}}, &job)
+		if err != nil || job == nil {
+			if err != nil && ctx.Err() == nil {
+				logger.Error("worker 租用%s任务失败: %v", tool.Name, err)
+			}
+			<-slots
+			return
+		}
+
+		ref := WorkerJobRef{Tool: job.Tool, Id: job.Id}
+		jobCtx, cancel := context.WithCancel(ctx)
+		w.mu.Lock()
+		w.running[ref] = cancel
+		shorter := job.LeaseSeconds > 0 && job.LeaseSeconds < w.leaseSeconds
+		if shorter {
+			w.leaseSeconds = job.LeaseSeconds
+		}
+		w.mu.Unlock()
+		if shorter {
+			select {
+			case w.leaseChanged <- struct{}{}:
+			default:
+			}
+		}
+
+		w.wg.Add(1)
+		go func() {
+			defer func() {
+				cancel()
+				w.mu.Lock()
+				delete(w.running, ref)
+				delete(w.lost, ref)
+				w.mu.Unlock()
+				<-slots
+				w.wg.Done()
+				select {
+				case w.wake <- struct{}{}:
+				default:
+				}
+			}()
+			w.execute(jobCtx, tool, *job)
+		}()
+	}
+}
+
+// execute 在本机工作目录中执行预测，并把结果或失败报告给服务器
+func (w *workerClient) execute(ctx context.Context, tool queueTool, leased WorkerJob) {
+	ref := WorkerJobRef{Tool: leased.Tool, Id: leased.Id}
+	logger.Info("worker 开始执行%s任务 ID %d，序列长度: %d", tool.Name, leased.Id, len(leased.Sequence))
+	startTime := time.Now()
+
+	// 只保留服务器接受的大小，超过时保留末尾部分
+	log := newTailBuffer(workerLogLimit)
+	job := newPredictionJob(tool, leased.Id, leased.Sequence)
+	job.ctx = ctx
+	job.log = log
+	defer removeJobWorkspace(job.Workspace)
+
+	modelPath, err := w.runJob(tool, job)
+
+	w.mu.Lock()
+	lost := w.lost[ref]
+	w.mu.Unlock()
+	switch {
+	case lost:
+		// 服务器已取消或回收该记录，不再报告结果
+		logger.Info("worker 的%s任务 ID %d 已失去租约，停止执行", tool.Name, leased.Id)
+	case err == nil:
+		duration := time.Since(startTime)
+		logger.Info("worker 的%s任务 ID %d 执行完成，耗时: %.2f秒", tool.Name, leased.Id, duration.Seconds())
+		w.complete(tool, leased, modelPath, duration, log.Bytes())
+	case ctx.Err() != nil:
+		// worker 停止，让服务器立即重新排队
+		w.fail(tool, leased, fmt.Errorf("%w: worker %s 已停止", errJobTransient, w.id), log.String())
+	default:
+		logger.Error("worker 的%s任务 ID %d 执行失败: %v", tool.Name, leased.Id, err)
+		w.fail(tool, leased, err, log.String())
+	}
+}
+
+// runJob 准备工作目录并执行预测工具，返回生成的模型文件
+func (w *workerClient) runJob(tool queueTool, job PredictionJob) (string, error) {
+	if err := tool.predictor.Validate(job.Sequence); err != nil {
+		return "", err
+	}
+	if err := prepareJobWorkspace(job.Workspace); err != nil {
+		return "", err
+	}
+	if err := writeFastaFile(job.FastaPath, "query", job.Sequence); err != nil {
+		return "", fmt.Errorf("创建FASTA文件失败: %v", err)
+	}
+	if err := tool.predictor.Run(job); err != nil {
+		return "", err
+	}
+	return tool.predictor.Collect(job)
+}
+
+// complete 上传模型和日志，网络错误时重试
+func (w *workerClient) complete(tool queueTool, job WorkerJob, modelPath string, duration time.Duration, log []byte) {
+	var err error
+	for attempt := 1; attempt <= workerUploadAttempts; attempt++ {
+		var resp *resty.Response
+		ctx, cancel := context.WithTimeout(context.Background(), workerUploadTimeout)
+		resp, err = w.client.R().
+			SetContext(ctx).
+			SetFormData(map[string]string{
+				"workerId": w.id,
+				"tool":     job.Tool,
+				"id":       fmt.Sprintf("%d", job.Id),
+				"duration": fmt.Sprintf("%.2f", duration.Seconds()),
+			}).
+			SetFile("model", modelPath).
+			SetFileReader("log", "job.log", bytes.NewReader(log)).
+			Post("/worker/complete")
+		cancel()
+		if err == nil {
+			err = parseWorkerResponse(resp, nil)
+		}
+		if err == nil {
+			logger.Info("worker 已上传%s任务 ID %d 的模型", tool.Name, job.Id)
+			return
+		}
+		if errors.Is(err, errWorkerRejected) {
+			break
+		}
+		logger.Warn("worker 第 %d 次上传%s任务 ID %d 的模型失败: %v", attempt, tool.Name, job.Id, err)
+		time.Sleep(time.Duration(attempt) * 10 * time.Second)
+	}
+	logger.Error("worker 上传%s任务 ID %d 的模型失败: %v", tool.Name, job.Id, err)
+}
+
+// fail 向服务器报告执行失败
+func (w *workerClient) fail(tool queueTool, job WorkerJob, jobErr error, log string) {
+	transient := errors.Is(jobErr, errJobTransient)
+	message := jobErr.Error()
+	if transient {
+		// 服务器按 transient 重新包装为可重试错误
+		message = strings.TrimPrefix(message, errJobTransient.Error()+": ")
+	}
+	req := WorkerFailRequest{
+		WorkerId:  w.id,
+		Tool:      job.Tool,
+		Id:        job.Id,
+		Error:     message,
+		Transient: transient,
+		Log:       log,
+	}
+	if err := w.post(context.Background(), "/worker/fail", req, nil); err != nil {
+		logger.Error("worker 报告%s任务 ID %d 失败时出错: %v", tool.Name, job.Id, err)
+	}
+}
+
+// heartbeat 定期续期执行中任务的租约，服务器返回已失去租约的任务时终止执行
+func (w *workerClient) heartbeat(ctx context.Context) {
+	for {
+		// 服务器的租约时长可能比本机配置的短，每次按最新的租约时长等待
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.leaseChanged:
+			continue
+		case <-time.After(w.heartbeatInterval()):
+		}
+
+		w.mu.Lock()
+		jobs := make([]WorkerJobRef, 0, len(w.running))
+		for ref := range w.running {
+			jobs = append(jobs, ref)
+		}
+		w.mu.Unlock()
+		if len(jobs) > 0 {
+			var response WorkerHeartbeatResponse
+			if err := w.post(ctx, "/worker/heartbeat", WorkerHeartbeatRequest{WorkerId: w.id, Jobs: jobs}, &response); err != nil {
+				logger.Error("worker 发送心跳失败: %v", err)
+			} else {
+				w.mu.Lock()
+				for _, ref := range response.Lost {
+					if cancel, ok := w.running[ref]; ok {
+						w.lost[ref] = true
+						cancel()
+					}
+				}
+				w.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (w *workerClient) heartbeatInterval() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Duration(w.leaseSeconds) * time.Second / 3
+}
+
+// post 以 JSON 请求服务器接口，result 不为 nil 时解析响应中的 data
+func (w *workerClient) post(ctx context.Context, path string, body interface{}, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, workerRequestTimeout)
+	defer cancel()
+	resp, err := w.client.R().SetContext(ctx).SetBody(body).Post(path)
+	if err != nil {
+		return err
+	}
+	return parseWorkerResponse(resp, result)
+}
+
+func parseWorkerResponse(resp *resty.Response, result interface{}) error {
+	var response workerResponse
+	if err := json.Unmarshal(resp.Body(), &response); err != nil {
+		return fmt.Errorf("服务器返回 %s: %v", resp.Status(), err)
+	}
+	if resp.StatusCode() == 401 {
+		return fmt.Errorf("%w: %s", errWorkerRejected, response.Error)
+	}
+	if response.Code != 200 {
+		if response.Code >= 400 && response.Code < 500 {
+			return fmt.Errorf("%w: %s", errWorkerRejected, response.Message)
+		}
+		return fmt.Errorf("服务器返回错误: %s", response.Message)
+	}
+	if result != nil && len(response.Data) > 0 {
+		return json.Unmarshal(response.Data, result)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"Protein_Server/logger"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// workerToken 远程 worker 访问 /worker 接口使用的共享令牌，未设置时不接受远程 worker
+var workerToken = os.Getenv("WORKER_TOKEN")
+
+// 远程 worker 上传的日志大小上限
+const workerLogLimit = 10 << 20
+
+// ErrWorkerLeaseLost 远程 worker 的记录已被取消、超时被回收或由其他执行者接管
+var ErrWorkerLeaseLost = errors.New("Lease lost.")
+
+// WorkerVerify 校验请求头 X-Worker-Token 中的 worker 令牌
+func WorkerVerify(c *gin.Context) {
+	token := c.GetHeader("X-Worker-Token")
+	if workerToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(workerToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "worker token error!"})
+		c.Abort()
+		return
+	}
+}
+
+// workerLeaseOwner 远程 worker 写入租约的标识
+func workerLeaseOwner(workerId string) string {
+	return "worker:" + workerId
+}
+
+// WorkerLeaseRequest 远程 worker 租用任务的请求
+type WorkerLeaseRequest struct {
+	WorkerId   string   `json:"workerId" binding:"required"`
+	Predictors []string `json:"predictors" binding:"required"` // worker 可以执行的预测工具，按顺序尝试
+}
+
+// WorkerJob 租给远程 worker 的任务
+type WorkerJob struct {
+	Tool         string `json:"tool"`
+	Id           uint   `json:"id"`
+	Sequence     string `json:"sequence"`
+	LeaseSeconds int    `json:"leaseSeconds"` // worker 需要在租约到期前发送心跳
+}
+
+// WorkerJobRef 远程 worker 正在执行的任务
+type WorkerJobRef struct {
+	Tool string `json:"tool"`
+	Id   uint   `json:"id"`
+}
+
+// WorkerHeartbeatRequest 远程 worker 续期租约的请求
+type WorkerHeartbeatRequest struct {
+	WorkerId string         `json:"workerId" binding:"required"`
+	Jobs     []WorkerJobRef `json:"jobs"`
+}
+
+// WorkerHeartbeatResponse 续期结果，Lost 中的任务已被取消或回收，worker 应停止执行
+type WorkerHeartbeatResponse struct {
+	Lost []WorkerJobRef `json:"lost"`
+}
+
+// WorkerCompleteRequest 远程 worker 上传模型的请求（multipart 表单字段）
+type WorkerCompleteRequest struct {
+	WorkerId string  `form:"workerId" binding:"required"`
+	Tool     string  `form:"tool" binding:"required"`
+	Id       uint    `form:"id" binding:"required"`
+	Duration float64 `form:"duration"` // 预测耗时（秒）
+}
+
+// WorkerFailRequest 远程 worker 报告执行失败的请求
+type WorkerFailRequest struct {
+	WorkerId  string `json:"workerId" binding:"required"`
+	Tool      string `json:"tool" binding:"required"`
+	Id        uint   `json:"id" binding:"required"`
+	Error     string `json:"error"`
+	Transient bool   `json:"transient"` // 可重试的错误，未达到最大尝试次数时重新排队
+	Log       string `json:"log"`
+}
+
+// LeaseWorkerJob 按公平调度顺序为远程 worker 认领一条记录并进入执行阶段，没有可执行的记录时返回 nil
+func LeaseWorkerJob(req WorkerLeaseRequest) (*WorkerJob, error) {
+	owner := workerLeaseOwner(req.WorkerId)
+	for _, key := range req.Predictors {
+		tool, ok := queueTools[key]
+		if !ok {
+			return nil, fmt.Errorf("Unknown predictor %s.", key)
+		}
+		id, ok := claimNextJob(tool, owner)
+		if !ok {
+			continue
+		}
+
+		var job claimedJob
+		if err := tool.query().Select("id, sequence, attempts").Where("id = ?", id).Scan(&job).Error; err != nil || job.ID == 0 {
+			logger.Error("读取%s任务 %d 失败: %v", tool.Name, id, err)
+			continue
+		}
+		if err := advanceQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, JobStatusRunning); err != nil {
+			continue
+		}
+		logger.Info("%s任务 ID %d 已租给 worker %s", tool.Name, job.ID, req.WorkerId)
+		return &WorkerJob{
+			Tool:         tool.Key,
+			Id:           job.ID,
+			Sequence:     job.Sequence,
+			LeaseSeconds: int(jobLeaseDuration / time.Second),
+		}, nil
+	}
+	return nil, nil
+}
+
+// WorkerHeartbeat 续期远程 worker 正在执行的记录的租约，返回已失去租约的记录
+func WorkerHeartbeat(req WorkerHeartbeatRequest) WorkerHeartbeatResponse {
+	owner := workerLeaseOwner(req.WorkerId)
+	updates := ownerLeaseUpdates(owner, time.Now())
+	response := WorkerHeartbeatResponse{Lost: []WorkerJobRef{}}
+	for _, ref := range req.Jobs {
+		tool, ok := queueTools[ref.Tool]
+		if !ok {
+			response.Lost = append(response.Lost, ref)
+			continue
+		}
+		result := tool.query().
+			Where("id = ? AND lease_owner = ? AND status IN ?", ref.Id, owner, jobActiveStatuses).
+			Updates(updates)
+		if result.Error != nil {
+			logger.Error("续期%s任务 %d 租约失败: %v", tool.Name, ref.Id, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			response.Lost = append(response.Lost, ref)
+		}
+	}
+	return response
+}
+
+// workerLeasedJob 读取远程 worker 持有租约的执行中记录
+func workerLeasedJob(workerId string, toolKey string, id uint) (queueTool, claimedJob, error) {
+	var job claimedJob
+	tool, ok := queueTools[toolKey]
+	if !ok {
+		return tool, job, ErrWorkerLeaseLost
+	}
+	if err := tool.query().Select("id, sequence, attempts").
+		Where("id = ? AND lease_owner = ? AND status IN ?", id, workerLeaseOwner(workerId), jobActiveStatuses).
+		Scan(&job).Error; err != nil {
+		return tool, job, err
+	}
+	if job.ID == 0 {
+		return tool, job, ErrWorkerLeaseLost
+	}
+	return tool, job, nil
+}
+
+// CompleteWorkerJob 保存远程 worker 上传的模型和日志，接管租约后在本实例异步完成后处理
+func CompleteWorkerJob(req WorkerCompleteRequest, model io.Reader, log io.Reader) error {
+	tool, job, err := workerLeasedJob(req.WorkerId, req.Tool, req.Id)
+	if err != nil {
+		return err
+	}
+	if log != nil {
+		if err := saveJobLog(tool.Key, job.ID, io.LimitReader(log, workerLogLimit)); err != nil {
+			logger.Error("保存%s任务 %d 的日志失败: %v", tool.Name, job.ID, err)
+		}
+	}
+
+	// 模型保存在本实例的工作目录中，实例中断时由恢复流程继续后处理
+	workspace := jobWorkspace(tool.Key, job.ID)
+	if err := prepareJobWorkspace(workspace); err != nil {
+		return err
+	}
+	modelPath := uploadedModelPath(tool, job.ID)
+	if err := writeUploadedModel(modelPath, model); err != nil {
+		removeJobWorkspace(workspace)
+		return err
+	}
+
+	// 只有仍持有租约的 worker 可以提交结果
+	updates := leaseUpdates(time.Now())
+	updates["status"] = JobStatusPostprocessing
+	result := tool.query().
+		Where("id = ? AND lease_owner = ? AND status IN ?", job.ID, workerLeaseOwner(req.WorkerId), jobActiveStatuses).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		removeJobWorkspace(workspace)
+		return ErrWorkerLeaseLost
+	}
+	refreshTasksForSequence(job.Sequence)
+	PublishQueueStatus(tool.Name, job.ID, job.Sequence, JobStatusPostprocessing)
+	logger.Info("worker %s 已上传%s任务 ID %d 的模型，耗时: %.2f秒", req.WorkerId, tool.Name, job.ID, req.Duration)
+
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+			if err != nil {
+				logger.Error("%s任务 ID %d 处理失败: %v", tool.Name, job.ID, err)
+			}
+			completeQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, job.Attempts, err)
+		}()
+		duration := time.Duration(req.Duration * float64(time.Second))
+		if err = finishPrediction(tool, job.Sequence, modelPath, workspace, duration); err != nil {
+			err = fmt.Errorf("处理结果失败: %w", err)
+		}
+	}()
+	return nil
+}
+
+// FailWorkerJob 记录远程 worker 报告的失败，可重试的错误按重试策略重新排队
+func FailWorkerJob(req WorkerFailRequest) error {
+	tool, job, err := workerLeasedJob(req.WorkerId, req.Tool, req.Id)
+	if err != nil {
+		return err
+	}
+	if req.Log != "" {
+		if err := saveJobLog(tool.Key, job.ID, io.LimitReader(strings.NewReader(req.Log), workerLogLimit)); err != nil {
+			logger.Error("保存%s任务 %d 的日志失败: %v", tool.Name, job.ID, err)
+		}
+	}
+
+	message := req.Error
+	if message == "" {
+		message = "worker 执行失败"
+	}
+	jobErr := errors.New(message)
+	if req.Transient {
+		jobErr = fmt.Errorf("%w: %s", errJobTransient, message)
+	}
+	logger.Error("worker %s 执行%s任务 ID %d 失败: %v", req.WorkerId, tool.Name, job.ID, jobErr)
+	completeQueueJob(tool.newModel(), tool.Name, job.ID, job.Sequence, job.Attempts, jobErr)
+	return nil
+}
+
+// uploadedModelPath 远程 worker 上传的模型在本实例工作目录中的路径
+func uploadedModelPath(tool queueTool, id uint) string {
+	return filepath.Join(jobWorkspace(tool.Key, id), "uploaded.pdb")
+}
+
+func writeUploadedModel(path string, model io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建模型文件失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, model); err != nil {
+		return fmt.Errorf("保存模型文件失败: %v", err)
+	}
+	return nil
+}
+
+// uploadedModelExists 判断远程 worker 上传的模型是否已保存但未完成后处理
+func uploadedModelExists(tool queueTool, id uint) bool {
+	_, err := existingFile(uploadedModelPath(tool, id))
+	return err == nil
+}